@@ -18,20 +18,25 @@ WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
 package cmd
 
 import (
-	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/machinebox/graphql"
+	"github.com/relizaio/reliza-cli/pkg/sealedsecret"
 	"github.com/spf13/cobra"
 )
 
 var (
-	artDigest  string
-	sealedCert string
+	artDigest    string
+	sealedCert   string
+	sealCertFile string
+	sealScope    string
+	sealName     string
+	sealKey      string
+	sealManifest bool
 )
 
 func init() {
@@ -56,6 +61,15 @@ func init() {
 	setInstSecretCertCmd.PersistentFlags().StringVar(&instanceURI, "instanceuri", "", "URI of instance for which to export from (optional)")
 	setInstSecretCertCmd.PersistentFlags().StringVar(&sealedCert, "cert", "", "Sealed certificate used by the instance (required)")
 	cdCmd.AddCommand(setInstSecretCertCmd)
+
+	sealCmd.PersistentFlags().StringVar(&sealCertFile, "cert", "", "Path to PEM certificate of sealed secrets controller of the instance (required)")
+	sealCmd.PersistentFlags().StringVar(&namespace, "namespace", "", "Namespace the value is sealed for (required unless scope is cluster-wide)")
+	sealCmd.PersistentFlags().StringVar(&sealScope, "scope", sealedsecret.ScopeNamespaceWide, "Scope of sealed value: strict, namespace-wide or cluster-wide")
+	sealCmd.PersistentFlags().StringVar(&sealName, "name", "", "Name of the secret (required for strict scope and manifest)")
+	sealCmd.PersistentFlags().StringVar(&infile, "infile", "", "File with plain value to seal (optional, if not supplied - value is read from stdin)")
+	sealCmd.PersistentFlags().BoolVar(&sealManifest, "manifest", false, "(Optional) Output SealedSecret manifest instead of sealed value only")
+	sealCmd.PersistentFlags().StringVar(&sealKey, "key", sealedsecret.SecretKey, "(Optional) Key of sealed value in encryptedData of manifest")
+	cdCmd.AddCommand(sealCmd)
 }
 
 var cdCmd = &cobra.Command{
@@ -81,7 +95,6 @@ var artifactGetSecrets = &cobra.Command{
 			namespace = "default"
 		}
 
-		client := graphql.NewClient(relizaHubUri + "/graphql")
 		req := graphql.NewRequest(`
 			query ($instanceUuid: ID, $instanceUri: String, $artDigest: String!, $namespace: String) {
 				artifactDownloadSecrets(instanceUuid: $instanceUuid, instanceUri: $instanceUri, artDigest: $artDigest, namespace: $namespace) {
@@ -95,19 +108,7 @@ var artifactGetSecrets = &cobra.Command{
 		req.Var("instanceUri", instanceURI)
 		req.Var("artDigest", artDigest)
 		req.Var("namespace", namespace)
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "Reliza CLI")
-		req.Header.Set("Accept-Encoding", "gzip, deflate")
-		if len(apiKeyId) > 0 && len(apiKey) > 0 {
-			auth := base64.StdEncoding.EncodeToString([]byte(apiKeyId + ":" + apiKey))
-			req.Header.Add("Authorization", "Basic "+auth)
-		}
-		session, _ := getSession()
-		if session != nil {
-			req.Header.Set("X-CSRF-Token", session.Token)
-			req.Header.Set("Cookie", "JSESSIONID="+session.JSessionId)
-		}
-		if err := client.Run(context.Background(), req, &respData); err != nil {
+		if err := getHubClient().Run(cliContext, req, &respData); err != nil {
 			printGqlError(err)
 			os.Exit(1)
 		}
@@ -128,7 +129,6 @@ var isInstHasSecretCertCmd = &cobra.Command{
 	This command checks whether this property is configured for the particular instance.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var respData IsHasCertRHResp
-		client := graphql.NewClient(relizaHubUri + "/graphql")
 		req := graphql.NewRequest(`
 			query ($instanceUuid: ID, $instanceUri: String) {
 				isInstanceHasSealedSecretCert(instanceUuid: $instanceUuid, instanceUri: $instanceUri)
@@ -136,21 +136,7 @@ var isInstHasSecretCertCmd = &cobra.Command{
 		`)
 		req.Var("instanceUuid", instance)
 		req.Var("instanceUri", instanceURI)
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "Reliza CLI")
-		req.Header.Set("Accept-Encoding", "gzip, deflate")
-
-		if len(apiKeyId) > 0 && len(apiKey) > 0 {
-			auth := base64.StdEncoding.EncodeToString([]byte(apiKeyId + ":" + apiKey))
-			req.Header.Add("Authorization", "Basic "+auth)
-		}
-		session, _ := getSession()
-		if session != nil {
-			req.Header.Set("X-CSRF-Token", session.Token)
-			req.Header.Set("Cookie", "JSESSIONID="+session.JSessionId)
-		}
-
-		if err := client.Run(context.Background(), req, &respData); err != nil {
+		if err := getHubClient().Run(cliContext, req, &respData); err != nil {
 			printGqlError(err)
 			os.Exit(1)
 		}
@@ -168,7 +154,6 @@ var setInstSecretCertCmd = &cobra.Command{
 	Only supports instance own API Key.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var respData SetCertRHResp
-		client := graphql.NewClient(relizaHubUri + "/graphql")
 		req := graphql.NewRequest(`
 			mutation ($instanceUuid: ID, $instanceUri: String, $sealedCert: String!) {
 				setInstanceSealedSecretCert(instanceUuid: $instanceUuid, instanceUri: $instanceUri,
@@ -178,21 +163,7 @@ var setInstSecretCertCmd = &cobra.Command{
 		req.Var("instanceUuid", instance)
 		req.Var("instanceUri", instanceURI)
 		req.Var("sealedCert", sealedCert)
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "Reliza CLI")
-		req.Header.Set("Accept-Encoding", "gzip, deflate")
-
-		if len(apiKeyId) > 0 && len(apiKey) > 0 {
-			auth := base64.StdEncoding.EncodeToString([]byte(apiKeyId + ":" + apiKey))
-			req.Header.Add("Authorization", "Basic "+auth)
-		}
-
-		session, _ := getSession()
-		if session != nil {
-			req.Header.Set("X-CSRF-Token", session.Token)
-			req.Header.Set("Cookie", "JSESSIONID="+session.JSessionId)
-		}
-		if err := client.Run(context.Background(), req, &respData); err != nil {
+		if err := getHubClient().Run(cliContext, req, &respData); err != nil {
 			printGqlError(err)
 			os.Exit(1)
 		}
@@ -202,6 +173,51 @@ var setInstSecretCertCmd = &cobra.Command{
 	},
 }
 
+var sealCmd = &cobra.Command{
+	Use:   "seal",
+	Short: "Use to seal a plain value with sealed cert of the instance",
+	Long: `Encrypts plain value read from infile or stdin in Bitnami Sealed Secrets format
+	using sealed certificate of the instance, same as kubeseal would do.
+	Value is used as is, including trailing newline if there is one.
+	Outputs base64 encoded sealed value or SealedSecret manifest.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(sealCertFile) == 0 {
+			fmt.Println("Error: cert must be specified")
+			os.Exit(1)
+		}
+		if sealManifest && len(sealName) == 0 {
+			fmt.Println("Error: name must be specified to produce manifest")
+			os.Exit(1)
+		}
+		pubKey, err := sealedsecret.ReadPublicKeyFile(sealCertFile)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		var plain []byte
+		if len(infile) > 0 {
+			plain, err = os.ReadFile(infile)
+		} else {
+			plain, err = io.ReadAll(os.Stdin)
+		}
+		if err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		sealed, err := sealedsecret.Seal(pubKey, plain, sealScope, namespace, sealName)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		if !sealManifest {
+			fmt.Println(sealed)
+			return
+		}
+		produceSecretYaml(os.Stdout, SecretTemplateResolver{Name: sealName, Namespace: namespace, Secret: sealed,
+			Scope: sealScope, Key: sealKey})
+	},
+}
+
 type ProjectAuthResp struct {
 	Responsewrapper ProjectAuthRespMaps `json:"artifactDownloadSecrets"`
 }
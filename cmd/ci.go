@@ -0,0 +1,139 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/relizaio/reliza-cli/pkg/ci"
+	"github.com/spf13/cobra"
+)
+
+const ciProviderAuto = "auto"
+const ciProviderNone = "none"
+
+var ciProvider string
+
+func init() {
+	ciProviderUsage := "CI provider used to fill source code and build metadata not set via flags: " + ciProviderAuto + " (detect from environment), " + ciProviderNone + " (disable) or one of " + strings.Join(ci.Providers(), ", ")
+	for _, c := range []*cobra.Command{addreleaseCmd, addArtifactCmd, getVersionCmd, prDataCmd, ciDetectCmd} {
+		c.PersistentFlags().StringVar(&ciProvider, "ci-provider", ciProviderAuto, ciProviderUsage)
+	}
+	ciCmd.AddCommand(ciDetectCmd)
+	rootCmd.AddCommand(ciCmd)
+}
+
+var ciCmd = &cobra.Command{
+	Use:   "ci",
+	Short: "Set of commands to inspect CI environment",
+	Long:  `Set of commands to inspect CI environment`,
+}
+
+var ciDetectCmd = &cobra.Command{
+	Use:   "detect",
+	Short: "Prints metadata inferred from CI environment",
+	Long: `Detects CI provider from environment variables and prints source code and build metadata
+			which would be used by addrelease, addartifact, getversion and prdata commands when flags are not set`,
+	Run: func(cmd *cobra.Command, args []string) {
+		info, ok := resolveCiInfo()
+		if !ok {
+			fmt.Println("Error: CI provider not detected, supported providers are " + strings.Join(ci.Providers(), ", "))
+			os.Exit(1)
+		}
+		jsonInfo, _ := json.Marshal(info)
+		printOutput(string(jsonInfo))
+	},
+}
+
+// resolveCiInfo returns info of the provider set via --ci-provider flag or detected from environment
+func resolveCiInfo() (ci.Info, bool) {
+	switch strings.ToLower(ciProvider) {
+	case ciProviderNone:
+		return ci.Info{}, false
+	case ciProviderAuto, "":
+		return ci.Detect(os.Getenv)
+	default:
+		info, err := ci.FromProvider(ciProvider, os.Getenv)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(2)
+		}
+		return info, true
+	}
+}
+
+/*
+This function fills source code and build metadata flags which are not set explicitly with values inferred from CI environment.
+Values present in the manifest body take precedence over CI environment, build metadata is repeated for every artifact set via flags.
+Commit of CI is not used when commit list is supplied, so that source code entry is built from its top commit.
+*/
+func applyCiDefaults(manifestBody map[string]interface{}) {
+	info, ok := resolveCiInfo()
+	if !ok {
+		return
+	}
+	if debug == "true" {
+		fmt.Println("Using metadata from CI provider", info.Provider)
+	}
+	if len(commits) > 0 || len(gitRange) > 0 || sinceLastRelease {
+		info = info.WithoutCommit()
+	} else if info.Commit == "" && info.PullRequestNumber != "" && commit == "" {
+		fmt.Fprintln(os.Stderr, "Warning: commit of pull request could not be inferred from CI environment, set it with --commit")
+	}
+
+	if manifestBody["branch"] == nil {
+		setIfEmpty(&branch, info.Branch)
+	}
+	if manifestBody["sourceCodeEntry"] == nil {
+		setIfEmpty(&commit, info.Commit)
+		setIfEmpty(&commitMessage, info.CommitMessage)
+		setIfEmpty(&vcsUri, info.VcsUri)
+		setIfEmpty(&vcsType, info.VcsType)
+		setIfEmpty(&vcsTag, info.VcsTag)
+	}
+	setIfEmpty(&number, info.PullRequestNumber)
+	setIfEmpty(&targetBranch, info.TargetBranch)
+
+	if len(artId) > 0 {
+		fillArtifactFlag(&artBuildId, info.BuildId)
+		fillArtifactFlag(&artBuildUri, info.BuildUri)
+		fillArtifactFlag(&artCiMeta, info.CiMeta)
+		fillArtifactFlag(&dateStart, info.DateStart)
+	}
+}
+
+func setIfEmpty(flagVal *string, value string) {
+	if len(*flagVal) == 0 {
+		*flagVal = value
+	}
+}
+
+// fillArtifactFlag repeats value for every artifact if artifact flag is not set
+func fillArtifactFlag(flagVals *[]string, value string) {
+	if len(*flagVals) > 0 || len(value) == 0 {
+		return
+	}
+	filled := make([]string, len(artId))
+	for i := range filled {
+		filled[i] = value
+	}
+	*flagVals = filled
+}
@@ -0,0 +1,143 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/relizaio/reliza-cli/pkg/vcs"
+)
+
+var gitRange string
+var sinceLastRelease bool
+var repoPath string
+
+func init() {
+	addreleaseCmd.PersistentFlags().StringVar(&gitRange, "git-range", "", "Read commits of this revision range from local git repository, i.e. LAST..HEAD (optional, instead of --commits)")
+	addreleaseCmd.PersistentFlags().BoolVar(&sinceLastRelease, "since-last-release", false, "Read commits since the commit of the latest release of this branch on Reliza Hub from local git repository (optional, instead of --commits)")
+	addreleaseCmd.PersistentFlags().StringVar(&repoPath, "repo-path", ".", "Path to local git repository used with --git-range or --since-last-release")
+	getVersionCmd.PersistentFlags().StringVar(&gitRange, "git-range", "", "Read commits of this revision range from local git repository, i.e. LAST..HEAD (optional, instead of --commits)")
+	getVersionCmd.PersistentFlags().BoolVar(&sinceLastRelease, "since-last-release", false, "Read commits since the commit of the latest release of this branch on Reliza Hub from local git repository (optional, instead of --commits)")
+	getVersionCmd.PersistentFlags().StringVar(&repoPath, "repo-path", ".", "Path to local git repository used with --git-range or --since-last-release")
+	prDataCmd.PersistentFlags().StringVar(&gitRange, "git-range", "", "Read commits of this revision range from local git repository, i.e. LAST..HEAD (optional, instead of --commits)")
+	prDataCmd.PersistentFlags().BoolVar(&sinceLastRelease, "since-last-release", false, "Read commits since the commit of the latest release of this branch on Reliza Hub from local git repository (optional, instead of --commits)")
+	prDataCmd.PersistentFlags().StringVar(&repoPath, "repo-path", ".", "Path to local git repository used with --git-range or --since-last-release")
+}
+
+/*
+This function resolves commits from either --commits, --git-range or --since-last-release flags
+and adds them to the body. If commit flag is not set, top commit is used as source code entry.
+When commits are read from local repository, vcs uri, type and tag are also taken from it unless set via flags.
+*/
+func addCommitsToBody(body map[string]interface{}) {
+	var commitList []vcs.Commit
+	var err error
+	fromRepo := len(gitRange) > 0 || sinceLastRelease
+	if len(commits) > 0 && fromRepo {
+		fmt.Println("Error: --commits can not be used together with --git-range or --since-last-release")
+		os.Exit(2)
+	} else if len(commits) > 0 {
+		commitList, err = vcs.DecodeCommits(commits)
+	} else if fromRepo {
+		commitList, err = readCommitsFromRepo()
+	} else {
+		return
+	}
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	if len(commitList) == 0 {
+		return
+	}
+
+	body["commits"] = commitList
+
+	// if commit is not present but we are here, use first line as commit
+	if len(commit) < 1 {
+		topCommit := commitList[0]
+		commitMap := map[string]string{"commit": topCommit.Commit, "dateActual": topCommit.DateActual, "commitMessage": topCommit.CommitMessage}
+		if len(topCommit.CommitAuthor) > 0 {
+			commitMap["commitAuthor"] = topCommit.CommitAuthor
+			commitMap["commitEmail"] = topCommit.CommitEmail
+		}
+		if vcsTag != "" {
+			commitMap["vcsTag"] = vcsTag
+		}
+		if vcsUri != "" {
+			commitMap["uri"] = vcsUri
+		}
+		if vcsType != "" {
+			commitMap["type"] = vcsType
+		}
+		body["sourceCodeEntry"] = commitMap
+	}
+}
+
+// readCommitsFromRepo reads commits from local git repository and fills vcs flags that are not set
+func readCommitsFromRepo() ([]vcs.Commit, error) {
+	repo, err := vcs.OpenRepo(cliContext, repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var commitList []vcs.Commit
+	if len(gitRange) > 0 {
+		commitList, err = repo.Commits(cliContext, gitRange)
+	} else if lastCommit := lastReleaseCommit(); len(lastCommit) > 0 && repo.HasCommit(cliContext, lastCommit) {
+		if debug == "true" {
+			fmt.Println("Reading commits since last release commit", lastCommit)
+		}
+		commitList, err = repo.Commits(cliContext, lastCommit+"..HEAD")
+	} else {
+		// without previous release known locally only current commit is sent
+		var head vcs.Commit
+		head, err = repo.HeadCommit(cliContext)
+		commitList = []vcs.Commit{head}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vcsUri) < 1 {
+		vcsUri = repo.RemoteURI(cliContext)
+	}
+	if len(vcsType) < 1 {
+		vcsType = "git"
+	}
+	if len(vcsTag) < 1 && len(commitList) > 0 {
+		if tags, err := repo.TagsAt(cliContext, commitList[0].Commit); err == nil && len(tags) > 0 {
+			vcsTag = tags[0]
+		}
+	}
+	return commitList, nil
+}
+
+// lastReleaseCommit returns commit of the latest release of the branch on Reliza Hub, or empty string if none
+func lastReleaseCommit() string {
+	jsonResponse := getLatestReleaseFunc(debug, relizaHubUri, project, "", branch, "", "", "", apiKeyId, apiKey, "", "", "")
+	var release struct {
+		SourceCodeEntryDetails struct {
+			Commit string `json:"commit"`
+		} `json:"sourceCodeEntryDetails"`
+	}
+	json.Unmarshal(jsonResponse, &release)
+	return release.SourceCodeEntryDetails.Commit
+}
@@ -18,15 +18,10 @@ WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
 package cmd
 
 import (
-	"context"
-	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"os"
-	"strconv"
-	"strings"
 
-	"github.com/machinebox/graphql"
+	"github.com/relizaio/reliza-cli/pkg/hub"
 	"github.com/spf13/cobra"
 )
 
@@ -60,82 +55,23 @@ var instPropsSecretsCmd = &cobra.Command{
 	},
 }
 
-func retrieveInstancePropsSecrets(props []string, secrs []string) SecretPropsRHResp {
-	var respData SecretPropsRHResp
-
-	if resolveProps {
-		if len(instance) <= 0 && len(instanceURI) <= 0 && !strings.HasPrefix(apiKeyId, "INSTANCE__") && !strings.HasPrefix(apiKeyId, "CLUSTER__") {
-			//throw error and exit
-			fmt.Println("instance or instanceURI not specified!")
-			os.Exit(1)
-		}
-
-		if len(revision) < 1 {
-			revision = "-1"
-		}
-
-		if len(namespace) <= 1 {
-			namespace = "default"
-		}
-
-		client := graphql.NewClient(relizaHubUri + "/graphql")
-		req := graphql.NewRequest(`
-			query ($instanceUuid: ID, $instanceUri: String, $revision: Int!, $namespace: String!, $properties: [String], $secrets: [String], $bundle: ID, $bundleSpecificProps: Boolean) {
-				getInstancePropSecrets(instanceUuid: $instanceUuid, instanceUri: $instanceUri, revision: $revision, namespace: $namespace, properties: $properties, secrets: $secrets, bundle: $bundle, bundleSpecificProps: $bundleSpecificProps) {
-					properties {
-						key
-						value
-					}
-					secrets {
-						key
-						value
-						lastUpdated
-					}
-				}
-			}
-		`)
-
-		req.Var("instanceUuid", instance)
-		req.Var("instanceUri", instanceURI)
-		intRevision, _ := strconv.Atoi(revision)
-		req.Var("revision", intRevision)
-		req.Var("namespace", namespace)
-		req.Var("properties", props)
-		req.Var("secrets", secrs)
-		req.Var("bundle", bundle)
-		req.Var("bundleSpecificProps", bundleSpecificProps)
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("User-Agent", "Reliza CLI")
-		req.Header.Set("Accept-Encoding", "gzip, deflate")
-
-		if len(apiKeyId) > 0 && len(apiKey) > 0 {
-			auth := base64.StdEncoding.EncodeToString([]byte(apiKeyId + ":" + apiKey))
-			req.Header.Add("Authorization", "Basic "+auth)
-		}
-		session, _ := getSession()
-		if session != nil {
-			req.Header.Set("X-CSRF-Token", session.Token)
-			req.Header.Set("Cookie", "JSESSIONID="+session.JSessionId)
-		}
-
-		if err := client.Run(context.Background(), req, &respData); err != nil {
-			printGqlError(err)
-			os.Exit(1)
-		}
-	}
-
-	return respData
-
-	// jsonResp, _ := json.Marshal(respData["getInstancePropSecrets"])
-	// fmt.Println(string(jsonResp))
-	// return respData["getInstancePropSecrets"].(map[string]interface{})
-}
-
 func retrieveInstancePropsSecretsVerbose(props []string, secrs []string) {
-	resolveProps = true
-	respData := retrieveInstancePropsSecrets(props, secrs)
+	respData, err := getHubClient().InstancePropsSecrets(cliContext, hub.InstancePropsSecretsInput{
+		Instance:            instance,
+		InstanceURI:         instanceURI,
+		Revision:            revision,
+		Namespace:           namespace,
+		Bundle:              bundle,
+		BundleSpecificProps: bundleSpecificProps,
+		Properties:          props,
+		Secrets:             secrs,
+	})
+	if err != nil {
+		printGqlError(err)
+		os.Exit(1)
+	}
 	jsonResp, _ := json.Marshal(respData.Responsewrapper)
-	fmt.Println(string(jsonResp[:]))
+	printOutput(string(jsonResp))
 }
 
 type IsHasCertRHResp struct {
@@ -145,12 +81,3 @@ type IsHasCertRHResp struct {
 type SetCertRHResp struct {
 	Responsewrapper bool `json:"setInstanceSealedSecretCert"`
 }
-
-type SecretPropsRHResp struct {
-	Responsewrapper SecretPropsRHRespMaps `json:"getInstancePropSecrets"`
-}
-
-type SecretPropsRHRespMaps struct {
-	Secrets    []ResolvedSecret   `json:"secrets"`
-	Properties []ResolvedProperty `json:"properties"`
-}
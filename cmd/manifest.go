@@ -0,0 +1,72 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/relizaio/reliza-cli/pkg/manifest"
+	"github.com/spf13/cobra"
+)
+
+var manifestFile string
+
+func init() {
+	addreleaseCmd.PersistentFlags().StringVar(&manifestFile, "manifest", "", "Path to release manifest in yaml or json format, flags take precedence over manifest values and flag artifacts are added to manifest artifacts (optional)")
+	addArtifactCmd.PersistentFlags().StringVar(&manifestFile, "manifest", "", "Path to release manifest in yaml or json format, flags take precedence over manifest values and flag artifacts are added to manifest artifacts (optional)")
+	rootCmd.AddCommand(manifestSchemaCmd)
+}
+
+var manifestSchemaCmd = &cobra.Command{
+	Use:   "manifestschema",
+	Short: "Prints json schema of release manifest",
+	Long:  `Prints json schema of release manifest used by --manifest flag of addrelease and addartifact commands`,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(string(manifest.Schema()))
+	},
+}
+
+// manifestBody reads --manifest file if set and returns either release or add artifact input constructed from it
+func manifestBody(forArtifact bool) map[string]interface{} {
+	if manifestFile == "" {
+		return map[string]interface{}{}
+	}
+	m, err := manifest.ReadFile(manifestFile)
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(2)
+	}
+	var body map[string]interface{}
+	if forArtifact {
+		body, err = m.AddArtifactBody()
+	} else {
+		body, err = m.ReleaseBody()
+	}
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(2)
+	}
+	return body
+}
+
+// manifestArtifacts returns artifacts already present in the body from manifest
+func manifestArtifacts(body map[string]interface{}) []map[string]interface{} {
+	artifacts, _ := body["artifacts"].([]map[string]interface{})
+	return artifacts
+}
@@ -1,78 +1,75 @@
 package cmd
 
 /**
-This set of functions resolves plain secrets for injection from sealed secrets. It is only meant to work inside reliza-cd context.
+This set of functions resolves plain secrets for injection from sealed secrets, which is meant for reliza-cd context,
+and produces SealedSecret manifests for cd seal.
 */
 
 import (
-	"bytes"
-	"fmt"
+	"context"
 	"io"
-	"os"
-	"os/exec"
-	"strconv"
-	"strings"
+	"sync"
 	"text/template"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/relizaio/reliza-cli/pkg/sealedsecret"
 )
 
-const (
-	ShellToUse = "sh"
-	KubectlApp = "tools/kubectl"
-)
-
-func shellout(command string) (string, string, error) {
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	cmd := exec.Command(ShellToUse, "-c", command)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
-
-	if err != nil {
-		fmt.Println("stdout: ", stdout.String(), "stderr: ", stderr.String(), "error: ", err.Error())
-	}
-
-	return stdout.String(), stderr.String(), err
-}
-
-func createNamespaceIfMissing(namespace string) {
-	nsListOut, _, _ := shellout(KubectlApp + " get ns " + namespace + " | wc -l")
-	nsListOut = strings.Replace(nsListOut, "\n", "", -1)
-	nsListOutInt, err := strconv.Atoi(nsListOut)
-	if err != nil {
-		fmt.Println(err)
-	} else if nsListOutInt < 2 {
-		shellout(KubectlApp + " create ns " + namespace)
+// newPlainSecretResolver returns resolver of PLAINSECRET placeholders, which unseals secrets with sealed-secrets
+// controller of the cluster from kubeconfig (in-cluster config inside reliza-cd), connection is made on first use;
+// ctx is the command context, so Ctrl+C aborts waiting while cleanup still runs with its own context
+func newPlainSecretResolver(kubeconfig string, timeout time.Duration) func(ctx context.Context, sealedSecret string, namespace string) (string, error) {
+	var once sync.Once
+	var resolver *sealedsecret.Resolver
+	var resolverErr error
+	return func(ctx context.Context, sealedSecret string, namespace string) (string, error) {
+		once.Do(func() {
+			resolver, resolverErr = sealedsecret.NewResolverFromConfig(kubeconfig, sealedsecret.WithTimeout(timeout))
+		})
+		if resolverErr != nil {
+			return "", resolverErr
+		}
+		if err := resolver.EnsureNamespace(ctx, namespace); err != nil {
+			return "", err
+		}
+		return resolver.Unseal(ctx, sealedSecret, namespace)
 	}
 }
 
-func produceSecretYaml(w io.Writer, secretName string, sealedSecret string, namespace string) {
+// produceSecretYaml writes SealedSecret manifest, scope annotation is omitted for strict scope and
+// namespace is omitted for cluster-wide secret sealed without one
+func produceSecretYaml(w io.Writer, secTmplRes SecretTemplateResolver) {
 	secretTmpl :=
 		`apiVersion: bitnami.com/v1alpha1
 kind: SealedSecret
 metadata:
   name: {{.Name}}
+{{- if .Namespace}}
   namespace: {{.Namespace}}
+{{- end}}
+{{- if ne .Scope "strict"}}
   annotations:
-    sealedsecrets.bitnami.com/namespace-wide: "true"
+    sealedsecrets.bitnami.com/{{.Scope}}: "true"
+{{- end}}
   labels:
     reliza.io/type: cdresource
     reliza.io/name: {{.Name}}
 spec:
   encryptedData:
-    secret: {{.Secret}}
+    {{.Key}}: {{.Secret}}
   template:
     metadata:
       labels:
         reliza.io/name: {{.Name}}
-        reliza.io/type: cdresource`
+        reliza.io/type: cdresource
+`
 
-	var secTmplRes SecretTemplateResolver
-	secTmplRes.Name = secretName
-	secTmplRes.Namespace = namespace
-	secTmplRes.Secret = sealedSecret
+	if len(secTmplRes.Scope) == 0 {
+		secTmplRes.Scope = sealedsecret.ScopeNamespaceWide
+	}
+	if len(secTmplRes.Key) == 0 {
+		secTmplRes.Key = sealedsecret.SecretKey
+	}
 
 	tmpl, err := template.New("secrettmpl").Parse(secretTmpl)
 	if err != nil {
@@ -85,32 +82,10 @@ spec:
 	}
 }
 
-func createSecretFile(filePath string) *os.File {
-	ecrSecretFile, err := os.Create(filePath)
-	if err != nil {
-		fmt.Println(err)
-	}
-	return ecrSecretFile
-}
-
-func resolvePlainSecret(sealedSecret string, namespace string) string {
-	// generate unique name for secret
-	secretName := "plainsecret-" + uuid.New().String()
-	secretPath := "workspace/" + secretName + ".yaml"
-	secretFile := createSecretFile(secretPath)
-	produceSecretYaml(secretFile, secretName, sealedSecret, namespace)
-	shellout(KubectlApp + " apply -f " + secretPath)
-	secretWaitCmd := "while ! " + KubectlApp + " get secret " + secretName + " -n " + namespace + "; do sleep 1; done"
-	shellout(secretWaitCmd)
-	plainSecret, _, _ := shellout(KubectlApp + " get secret " + secretName + " -o jsonpath={.data.secret} -n " + namespace + " | base64 -d")
-	// cleanup
-	shellout(KubectlApp + " delete sealedsecret " + secretName + " -n " + namespace)
-	os.Remove(secretPath)
-	return plainSecret
-}
-
 type SecretTemplateResolver struct {
 	Name      string
 	Namespace string
 	Secret    string
+	Scope     string // strict, namespace-wide (default) or cluster-wide
+	Key       string // key of encrypted data, defaults to secret
 }
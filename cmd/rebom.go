@@ -18,12 +18,11 @@ WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/machinebox/graphql"
+	"github.com/relizaio/reliza-cli/pkg/bom"
 	"github.com/spf13/cobra"
 )
 
@@ -108,46 +107,15 @@ func attachToRebomFunc() {
 		body["digest"] = artDigest
 	}
 
-	client := resty.New()
-	session, _ := getSession()
-	if session != nil {
-		client.SetHeader("X-CSRF-Token", session.Token)
-		client.SetHeader("Cookie", "JSESSIONID="+session.JSessionId)
-	}
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("User-Agent", "Reliza Go Client").
-		SetHeader("Accept-Encoding", "gzip, deflate").
-		SetHeader("Accept-Encoding", "gzip, deflate").
-		SetFile("file", infile).
-		SetFormData(body).
-		SetBasicAuth(apiKeyId, apiKey).
-		Post(relizaHubUri + "/api/programmatic/v1/sbom/upload")
+	resp, err := getHubClient().Upload(cliContext, "/api/programmatic/v1/sbom/upload", infile, body)
 
 	printResponse(err, resp)
 }
 
-func ReadBomJsonFromFile(filePath string) map[string]interface{} {
-	// open infile
-	// Make sure infile is a file and not a directory
-	fileInfo, err := os.Stat(filePath)
+func readBomJsonFromFile(filePath string) map[string]interface{} {
+	bomJSON, err := bom.ReadJsonFromFile(filePath)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	} else if fileInfo.IsDir() {
-		fmt.Println("Error: infile must be a path to a file, not a directory!")
-		os.Exit(1)
-	}
-	// Read infile if not directory:
-	fileContentByteSlice, _ := os.ReadFile(filePath)
-	// fileContent := string(fileContentByteSlice)
-
-	// Parse file content into json
-	var bomJSON map[string]interface{}
-	parseError := json.Unmarshal(fileContentByteSlice, &bomJSON)
-	if parseError != nil {
-		fmt.Println("Error unmarshalling json bom file")
-		fmt.Println(parseError)
+		fmt.Println("Error: ", err)
 		os.Exit(1)
 	}
 	return bomJSON
@@ -156,7 +124,7 @@ func ReadBomJsonFromFile(filePath string) map[string]interface{} {
 func addBomToRebomFunc() {
 	var bomInput BomInput
 	bomInput.Meta = "sent from reliza cli"
-	bomInput.Bom = ReadBomJsonFromFile(infile)
+	bomInput.Bom = readBomJsonFromFile(infile)
 
 	// fmt.Println(bomInput)
 
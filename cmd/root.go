@@ -17,17 +17,20 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/machinebox/graphql"
 	"github.com/mitchellh/go-homedir"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+	"github.com/relizaio/reliza-cli/pkg/output"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
@@ -72,6 +75,10 @@ var featureBranchVersioning string
 var filePath string
 var fsBomPath string
 var hash string
+var hubRetries int
+var hubTimeout time.Duration
+var outputFormat string
+var outputQuery string
 var imageFilePath string
 var imageString string
 var imageStyle string
@@ -93,7 +100,7 @@ var outfile string
 var tagSourceFile string
 var definitionReferenceFile string
 var provenance bool  // add provenance (default), or do not add provenance
-var parseMode string // "simple" || "extended" || "strict" mode
+var parseMode string // "simple" || "extended" || "strict" || "ast" || "compose" mode
 var releaseId string
 var releaseVersion string
 var releaseNs string
@@ -280,9 +287,19 @@ var rootCmd = &cobra.Command{
 	Long:  `CLI client for programmatic actions on Reliza Hub.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		initConfig(cmd)
+		if err := (output.Printer{Format: outputFormat}).Validate(); err != nil {
+			fmt.Println("Error: ", err)
+			os.Exit(1)
+		}
+		if cmd.Context() != nil {
+			cliContext = cmd.Context()
+		}
 	},
 }
 
+// cliContext is cancelled on SIGINT or SIGTERM, so that pending requests to Reliza Hub are aborted
+var cliContext = context.Background()
+
 var printversionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Prints current version of the CLI",
@@ -337,7 +354,18 @@ var addreleaseCmd = &cobra.Command{
 			fmt.Println("Using Reliza Hub at", relizaHubUri)
 		}
 
-		body := map[string]interface{}{"branch": branch, "version": version}
+		body := manifestBody(false)
+		applyCiDefaults(body)
+		if len(branch) > 0 {
+			body["branch"] = branch
+		}
+		if len(version) > 0 {
+			body["version"] = version
+		}
+		if body["branch"] == nil || body["version"] == nil {
+			fmt.Println("Error: branch and version must be set either with --branch and --version flags or in the manifest")
+			os.Exit(2)
+		}
 		if len(status) > 0 {
 			body["status"] = strings.ToUpper(status)
 		}
@@ -348,173 +376,7 @@ var addreleaseCmd = &cobra.Command{
 			body["project"] = project
 		}
 		if len(artId) > 0 {
-			artifacts := make([]map[string]interface{}, len(artId))
-			for i, aid := range artId {
-				artifacts[i] = map[string]interface{}{"identifier": aid}
-			}
-
-			// now do some length validations and add elements
-			if len(artBuildId) > 0 && len(artBuildId) != len(artId) {
-				fmt.Println("number of --artbuildid flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artBuildId) > 0 {
-				for i, abid := range artBuildId {
-					artifacts[i]["buildId"] = abid
-				}
-			}
-
-			if len(artBuildUri) > 0 && len(artBuildUri) != len(artId) {
-				fmt.Println("number of --artbuildUri flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artBuildUri) > 0 {
-				for i, aburi := range artBuildUri {
-					artifacts[i]["buildUri"] = aburi
-				}
-			}
-
-			if len(artCiMeta) > 0 && len(artCiMeta) != len(artId) {
-				fmt.Println("number of --artcimeta flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artCiMeta) > 0 {
-				for i, acm := range artCiMeta {
-					artifacts[i]["cicdMeta"] = acm
-				}
-			}
-
-			if len(artType) > 0 && len(artType) != len(artId) {
-				fmt.Println("number of --arttype flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artType) > 0 {
-				for i, at := range artType {
-					artifacts[i]["type"] = at
-				}
-			}
-
-			if len(artDigests) > 0 && len(artDigests) != len(artId) {
-				fmt.Println("number of --artdigests flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artDigests) > 0 {
-				for i, ad := range artDigests {
-					adSpl := strings.Split(ad, ",")
-					artifacts[i]["digests"] = adSpl
-				}
-			}
-
-			if len(dateStart) > 0 && len(dateStart) != len(artId) {
-				fmt.Println("number of --datestart flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(dateStart) > 0 {
-				for i, ds := range dateStart {
-					artifacts[i]["dateFrom"] = ds
-				}
-			}
-
-			if len(dateEnd) > 0 && len(dateEnd) != len(artId) {
-				fmt.Println("number of --dateEnd flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(dateEnd) > 0 {
-				for i, de := range dateEnd {
-					artifacts[i]["dateTo"] = de
-				}
-			}
-
-			if len(artVersion) > 0 && len(artVersion) != len(artId) {
-				fmt.Println("number of --artversion flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artVersion) > 0 {
-				for i, av := range artVersion {
-					artifacts[i]["artifactVersion"] = av
-				}
-			}
-
-			if len(artPublisher) > 0 && len(artPublisher) != len(artId) {
-				fmt.Println("number of --artpublisher flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artPublisher) > 0 {
-				for i, ap := range artPublisher {
-					artifacts[i]["publisher"] = ap
-				}
-			}
-
-			if len(artPackage) > 0 && len(artPackage) != len(artId) {
-				fmt.Println("number of --artpackage flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artPackage) > 0 {
-				for i, ap := range artPackage {
-					artifacts[i]["packageType"] = strings.ToUpper(ap)
-				}
-			}
-
-			if len(artGroup) > 0 && len(artGroup) != len(artId) {
-				fmt.Println("number of --artgroup flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artGroup) > 0 {
-				for i, ag := range artGroup {
-					artifacts[i]["group"] = ag
-				}
-			}
-
-			if len(artBomFilePaths) > 0 && len(artBomFilePaths) != len(artId) {
-				fmt.Println("number of --artboms flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artBomFilePaths) > 0 {
-				for i, bomPath := range artBomFilePaths {
-					bomInputs := strings.Split(bomPath, ",")
-					var boms []RawBomInput
-
-					for _, bomInput := range bomInputs {
-						typeAndBom := strings.Split(bomInput, ":")
-
-						if len(typeAndBom) != 2 {
-							fmt.Println("Each bom should have a type")
-							os.Exit(2)
-						}
-						bomType := strings.ToUpper(typeAndBom[0])
-
-						if bomType != "CONTAINER" && bomType != "FILE" {
-							fmt.Println("Incorrect type: only APPLICATION and CONTAINER type boms are supported for artifacts!")
-							os.Exit(2)
-						}
-						boms = append(boms, RawBomInput{
-							RawBom:  ReadBomJsonFromFile(typeAndBom[1]),
-							BomType: bomType,
-						})
-					}
-					artifacts[i]["bomInputs"] = boms
-				}
-			}
-
-			if len(tagKeyArr) > 0 && len(tagKeyArr) != len(artId) {
-				fmt.Println("number of --tagkey flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(tagValArr) > 0 && len(tagValArr) != len(artId) {
-				fmt.Println("number of --tagval flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(tagKeyArr) > 0 && len(tagValArr) < 1 {
-				fmt.Println("number of --tagval and --tagkey flags must be the same and must match number of --artid flags")
-				os.Exit(2)
-			} else if len(tagKeyArr) > 0 {
-				for i, key := range tagKeyArr {
-					tagKeys := strings.Split(key, ",")
-					tagVals := strings.Split(tagValArr[i], ",")
-					if len(tagKeys) != len(tagVals) {
-						fmt.Println("number of keys and values per each --tagval and --tagkey flag must be the same")
-						os.Exit(2)
-					}
-
-					k := make([]TagRecord, 0)
-					for j := range tagKeys {
-						tr := TagRecord{
-							Key:   tagKeys[j],
-							Value: tagVals[j],
-						}
-						k = append(k, tr)
-					}
-					artifacts[i]["tags"] = k
-				}
-			}
-
-			body["artifacts"] = artifacts
+			body["artifacts"] = append(manifestArtifacts(body), artifactsFromFlags(true)...)
 		}
 
 		if commit != "" {
@@ -528,54 +390,10 @@ var addreleaseCmd = &cobra.Command{
 			body["sourceCodeEntry"] = commitMap
 		}
 
-		if len(commits) > 0 {
-			// fmt.Println(commits)
-			plainCommits, err := base64.StdEncoding.DecodeString(commits)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			indCommits := strings.Split(string(plainCommits), "\n")
-			commitsInBody := make([]map[string]interface{}, len(indCommits)-1)
-			for i := range indCommits {
-				if len(indCommits[i]) > 0 {
-					singleCommitEl := map[string]interface{}{}
-					commitParts := strings.Split(indCommits[i], "|||")
-					singleCommitEl["commit"] = commitParts[0]
-					singleCommitEl["dateActual"] = commitParts[1]
-					singleCommitEl["commitMessage"] = commitParts[2]
-					if len(commitParts) > 3 {
-						singleCommitEl["commitAuthor"] = commitParts[3]
-						singleCommitEl["commitEmail"] = commitParts[4]
-					}
-					commitsInBody[i] = singleCommitEl
-
-					// if commit is not present but we are here, use first line as commit
-					if len(commit) < 1 && i == 0 {
-						commitMap := map[string]string{}
-						if len(commitParts) > 3 {
-							commitMap = map[string]string{"commit": commitParts[0], "dateActual": commitParts[1], "commitMessage": commitParts[2], "commitAuthor": commitParts[3], "commitEmail": commitParts[4]}
-						} else {
-							commitMap = map[string]string{"commit": commitParts[0], "dateActual": commitParts[1], "commitMessage": commitParts[2]}
-						}
-						if vcsTag != "" {
-							commitMap["vcsTag"] = vcsTag
-						}
-						if vcsUri != "" {
-							commitMap["uri"] = vcsUri
-						}
-						if vcsType != "" {
-							commitMap["type"] = vcsType
-						}
-						body["sourceCodeEntry"] = commitMap
-					}
-				}
-			}
-			body["commits"] = commitsInBody
-		}
+		addCommitsToBody(body)
 
 		if fsBomPath != "" {
-			body["fsBom"] = RawBomInput{RawBom: ReadBomJsonFromFile(fsBomPath), BomType: "APPLICATION"}
+			body["fsBom"] = RawBomInput{RawBom: readBomJsonFromFile(fsBomPath), BomType: "APPLICATION"}
 		}
 
 		// 		fmt.Println(body)
@@ -604,7 +422,8 @@ var addArtifactCmd = &cobra.Command{
 			fmt.Println("Using Reliza Hub at", relizaHubUri)
 		}
 
-		body := map[string]interface{}{}
+		body := manifestBody(true)
+		applyCiDefaults(body)
 		if len(releaseId) > 0 {
 			body["release"] = releaseId
 		}
@@ -616,154 +435,190 @@ var addArtifactCmd = &cobra.Command{
 		}
 
 		if len(artId) > 0 {
-			// use artifacts, construct artifact array
-			artifacts := make([]map[string]interface{}, len(artId))
-			for i, aid := range artId {
-				artifacts[i] = map[string]interface{}{"identifier": aid}
-			}
+			body["artifacts"] = append(manifestArtifacts(body), artifactsFromFlags(false)...)
+		}
 
-			// now do some length validations and add elements
-			if len(artBuildId) > 0 && len(artBuildId) != len(artId) {
-				fmt.Println("number of --artbuildid flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artBuildId) > 0 {
-				for i, abid := range artBuildId {
-					artifacts[i]["buildId"] = abid
-				}
+		req := graphql.NewRequest(`
+			mutation ($AddArtifactInput: AddArtifactInput) {
+				addArtifact(release: $AddArtifactInput) {` + RELEASE_GQL_DATA + `}
 			}
+		`)
+		req.Var("AddArtifactInput", body)
+		fmt.Println(sendRequest(req, "addArtifact"))
+	},
+}
 
-			if len(artBuildUri) > 0 && len(artBuildUri) != len(artId) {
-				fmt.Println("number of --artbuildUri flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artBuildUri) > 0 {
-				for i, aburi := range artBuildUri {
-					artifacts[i]["buildUri"] = aburi
-				}
-			}
+// artifactsFromFlags constructs artifact inputs from parallel artifact flags, boms are only supported on addrelease
+func artifactsFromFlags(withBoms bool) []map[string]interface{} {
+	artifacts := make([]map[string]interface{}, len(artId))
+	for i, aid := range artId {
+		artifacts[i] = map[string]interface{}{"identifier": aid}
+	}
 
-			if len(artCiMeta) > 0 && len(artCiMeta) != len(artId) {
-				fmt.Println("number of --artcimeta flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artCiMeta) > 0 {
-				for i, acm := range artCiMeta {
-					artifacts[i]["cicdMeta"] = acm
-				}
-			}
+	// now do some length validations and add elements
+	if len(artBuildId) > 0 && len(artBuildId) != len(artId) {
+		fmt.Println("number of --artbuildid flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artBuildId) > 0 {
+		for i, abid := range artBuildId {
+			artifacts[i]["buildId"] = abid
+		}
+	}
 
-			if len(artType) > 0 && len(artType) != len(artId) {
-				fmt.Println("number of --arttype flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artType) > 0 {
-				for i, at := range artType {
-					artifacts[i]["type"] = at
-				}
-			}
+	if len(artBuildUri) > 0 && len(artBuildUri) != len(artId) {
+		fmt.Println("number of --artbuildUri flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artBuildUri) > 0 {
+		for i, aburi := range artBuildUri {
+			artifacts[i]["buildUri"] = aburi
+		}
+	}
 
-			if len(artDigests) > 0 && len(artDigests) != len(artId) {
-				fmt.Println("number of --artdigests flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artDigests) > 0 {
-				for i, ad := range artDigests {
-					adSpl := strings.Split(ad, ",")
-					artifacts[i]["digests"] = adSpl
-				}
-			}
+	if len(artCiMeta) > 0 && len(artCiMeta) != len(artId) {
+		fmt.Println("number of --artcimeta flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artCiMeta) > 0 {
+		for i, acm := range artCiMeta {
+			artifacts[i]["cicdMeta"] = acm
+		}
+	}
 
-			if len(dateStart) > 0 && len(dateStart) != len(artId) {
-				fmt.Println("number of --datestart flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(dateStart) > 0 {
-				for i, ds := range dateStart {
-					artifacts[i]["dateFrom"] = ds
-				}
-			}
+	if len(artType) > 0 && len(artType) != len(artId) {
+		fmt.Println("number of --arttype flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artType) > 0 {
+		for i, at := range artType {
+			artifacts[i]["type"] = at
+		}
+	}
 
-			if len(dateEnd) > 0 && len(dateEnd) != len(artId) {
-				fmt.Println("number of --dateEnd flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(dateEnd) > 0 {
-				for i, de := range dateEnd {
-					artifacts[i]["dateTo"] = de
-				}
-			}
+	if len(artDigests) > 0 && len(artDigests) != len(artId) {
+		fmt.Println("number of --artdigests flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artDigests) > 0 {
+		for i, ad := range artDigests {
+			adSpl := strings.Split(ad, ",")
+			artifacts[i]["digests"] = adSpl
+		}
+	}
 
-			if len(artVersion) > 0 && len(artVersion) != len(artId) {
-				fmt.Println("number of --artversion flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artVersion) > 0 {
-				for i, av := range artVersion {
-					artifacts[i]["artifactVersion"] = av
-				}
-			}
+	if len(dateStart) > 0 && len(dateStart) != len(artId) {
+		fmt.Println("number of --datestart flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(dateStart) > 0 {
+		for i, ds := range dateStart {
+			artifacts[i]["dateFrom"] = ds
+		}
+	}
 
-			if len(artPublisher) > 0 && len(artPublisher) != len(artId) {
-				fmt.Println("number of --artpublisher flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artPublisher) > 0 {
-				for i, ap := range artPublisher {
-					artifacts[i]["publisher"] = ap
-				}
-			}
+	if len(dateEnd) > 0 && len(dateEnd) != len(artId) {
+		fmt.Println("number of --dateEnd flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(dateEnd) > 0 {
+		for i, de := range dateEnd {
+			artifacts[i]["dateTo"] = de
+		}
+	}
 
-			if len(artPackage) > 0 && len(artPackage) != len(artId) {
-				fmt.Println("number of --artpackage flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artPackage) > 0 {
-				for i, ap := range artPackage {
-					artifacts[i]["packageType"] = strings.ToUpper(ap)
-				}
-			}
+	if len(artVersion) > 0 && len(artVersion) != len(artId) {
+		fmt.Println("number of --artversion flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artVersion) > 0 {
+		for i, av := range artVersion {
+			artifacts[i]["artifactVersion"] = av
+		}
+	}
 
-			if len(artGroup) > 0 && len(artGroup) != len(artId) {
-				fmt.Println("number of --artgroup flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(artGroup) > 0 {
-				for i, ag := range artGroup {
-					artifacts[i]["group"] = ag
-				}
-			}
+	if len(artPublisher) > 0 && len(artPublisher) != len(artId) {
+		fmt.Println("number of --artpublisher flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artPublisher) > 0 {
+		for i, ap := range artPublisher {
+			artifacts[i]["publisher"] = ap
+		}
+	}
 
-			if len(tagKeyArr) > 0 && len(tagKeyArr) != len(artId) {
-				fmt.Println("number of --tagkey flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(tagValArr) > 0 && len(tagValArr) != len(artId) {
-				fmt.Println("number of --tagval flags must be either zero or match number of --artid flags")
-				os.Exit(2)
-			} else if len(tagKeyArr) > 0 && len(tagValArr) < 1 {
-				fmt.Println("number of --tagval and --tagkey flags must be the same and must match number of --artid flags")
-				os.Exit(2)
-			} else if len(tagKeyArr) > 0 {
-				for i, key := range tagKeyArr {
-					tagKeys := strings.Split(key, ",")
-					tagVals := strings.Split(tagValArr[i], ",")
-					if len(tagKeys) != len(tagVals) {
-						fmt.Println("number of keys and values per each --tagval and --tagkey flag must be the same")
+	if len(artPackage) > 0 && len(artPackage) != len(artId) {
+		fmt.Println("number of --artpackage flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artPackage) > 0 {
+		for i, ap := range artPackage {
+			artifacts[i]["packageType"] = strings.ToUpper(ap)
+		}
+	}
+
+	if len(artGroup) > 0 && len(artGroup) != len(artId) {
+		fmt.Println("number of --artgroup flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(artGroup) > 0 {
+		for i, ag := range artGroup {
+			artifacts[i]["group"] = ag
+		}
+	}
+
+	if withBoms {
+		if len(artBomFilePaths) > 0 && len(artBomFilePaths) != len(artId) {
+			fmt.Println("number of --artboms flags must be either zero or match number of --artid flags")
+			os.Exit(2)
+		} else if len(artBomFilePaths) > 0 {
+			for i, bomPath := range artBomFilePaths {
+				bomInputs := strings.Split(bomPath, ",")
+				var boms []RawBomInput
+
+				for _, bomInput := range bomInputs {
+					typeAndBom := strings.Split(bomInput, ":")
+
+					if len(typeAndBom) != 2 {
+						fmt.Println("Each bom should have a type")
 						os.Exit(2)
 					}
+					bomType := strings.ToUpper(typeAndBom[0])
 
-					k := make([]TagRecord, 0)
-					for j := range tagKeys {
-						tr := TagRecord{
-							Key:   tagKeys[j],
-							Value: tagVals[j],
-						}
-						k = append(k, tr)
+					if bomType != "CONTAINER" && bomType != "FILE" {
+						fmt.Println("Incorrect type: only APPLICATION and CONTAINER type boms are supported for artifacts!")
+						os.Exit(2)
 					}
-					artifacts[i]["tags"] = k
+					boms = append(boms, RawBomInput{
+						RawBom:  readBomJsonFromFile(typeAndBom[1]),
+						BomType: bomType,
+					})
 				}
+				artifacts[i]["bomInputs"] = boms
 			}
-
-			body["artifacts"] = artifacts
 		}
+	}
 
-		req := graphql.NewRequest(`
-			mutation ($AddArtifactInput: AddArtifactInput) {
-				addArtifact(release: $AddArtifactInput) {` + RELEASE_GQL_DATA + `}
+	if len(tagKeyArr) > 0 && len(tagKeyArr) != len(artId) {
+		fmt.Println("number of --tagkey flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(tagValArr) > 0 && len(tagValArr) != len(artId) {
+		fmt.Println("number of --tagval flags must be either zero or match number of --artid flags")
+		os.Exit(2)
+	} else if len(tagKeyArr) > 0 && len(tagValArr) < 1 {
+		fmt.Println("number of --tagval and --tagkey flags must be the same and must match number of --artid flags")
+		os.Exit(2)
+	} else if len(tagKeyArr) > 0 {
+		for i, key := range tagKeyArr {
+			tagKeys := strings.Split(key, ",")
+			tagVals := strings.Split(tagValArr[i], ",")
+			if len(tagKeys) != len(tagVals) {
+				fmt.Println("number of keys and values per each --tagval and --tagkey flag must be the same")
+				os.Exit(2)
 			}
-		`)
-		req.Var("AddArtifactInput", body)
-		fmt.Println(sendRequest(req, "addArtifact"))
-	},
+
+			k := make([]TagRecord, 0)
+			for j := range tagKeys {
+				tr := TagRecord{
+					Key:   tagKeys[j],
+					Value: tagVals[j],
+				}
+				k = append(k, tr)
+			}
+			artifacts[i]["tags"] = k
+		}
+	}
+
+	return artifacts
 }
 
 var approveReleaseCmd = &cobra.Command{
@@ -840,7 +695,7 @@ var isApprovalNeededCmd = &cobra.Command{
 			}
 		`)
 		req.Var("IsApprovalNeededInput", body)
-		fmt.Println(sendRequest(req, "isApprovalNeeded"))
+		printOutput(sendRequest(req, "isApprovalNeeded"))
 	},
 }
 
@@ -872,22 +727,7 @@ var downloadableArtifactCmd = &cobra.Command{
 		if len(artifactType) > 0 {
 			body["artifactType"] = artifactType
 		}
-		client := resty.New()
-		session, _ := getSession()
-		if session != nil {
-			client.SetHeader("X-CSRF-Token", session.Token)
-			client.SetHeader("Cookie", "JSESSIONID="+session.JSessionId)
-		}
-		resp, err := client.R().
-			SetHeader("Content-Type", "application/json").
-			SetHeader("User-Agent", "Reliza Go Client").
-			SetHeader("Accept-Encoding", "gzip, deflate").
-			SetHeader("Accept-Encoding", "gzip, deflate").
-			SetFile("file", filePath).
-			SetFormData(body).
-			SetBasicAuth(apiKeyId, apiKey).
-			Post(relizaHubUri + "/api/programmatic/v1/artifact/upload")
-
+		resp, err := getHubClient().Upload(cliContext, "/api/programmatic/v1/artifact/upload", filePath, body)
 		printResponse(err, resp)
 
 	},
@@ -1066,6 +906,12 @@ var getVersionCmd = &cobra.Command{
 			fmt.Println("Using Reliza Hub at", relizaHubUri)
 		}
 
+		applyCiDefaults(nil)
+		if len(branch) == 0 {
+			fmt.Println("Error: branch must be set with --branch flag or inferred from CI environment")
+			os.Exit(2)
+		}
+
 		body := map[string]interface{}{"branch": branch}
 		if len(project) > 0 {
 			body["project"] = project
@@ -1098,50 +944,7 @@ var getVersionCmd = &cobra.Command{
 			body["status"] = "draft"
 		}
 
-		if len(commits) > 0 {
-			plainCommits, err := base64.StdEncoding.DecodeString(commits)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			indCommits := strings.Split(string(plainCommits), "\n")
-			commitsInBody := make([]map[string]interface{}, len(indCommits)-1)
-			for i := range indCommits {
-				if len(indCommits[i]) > 0 {
-					singleCommitEl := map[string]interface{}{}
-					commitParts := strings.Split(indCommits[i], "|||")
-					singleCommitEl["commit"] = commitParts[0]
-					singleCommitEl["dateActual"] = commitParts[1]
-					singleCommitEl["commitMessage"] = commitParts[2]
-					if len(commitParts) > 3 {
-						singleCommitEl["commitAuthor"] = commitParts[3]
-						singleCommitEl["commitEmail"] = commitParts[4]
-					}
-					commitsInBody[i] = singleCommitEl
-
-					// if commit is not present but we are here, use first line as commit
-					if len(commit) < 1 && i == 0 {
-						commitMap := map[string]string{}
-						if len(commitParts) > 3 {
-							commitMap = map[string]string{"commit": commitParts[0], "dateActual": commitParts[1], "commitMessage": commitParts[2], "commitAuthor": commitParts[3], "commitEmail": commitParts[4]}
-						} else {
-							commitMap = map[string]string{"commit": commitParts[0], "dateActual": commitParts[1], "commitMessage": commitParts[2]}
-						}
-						if vcsTag != "" {
-							commitMap["vcsTag"] = vcsTag
-						}
-						if vcsUri != "" {
-							commitMap["uri"] = vcsUri
-						}
-						if vcsType != "" {
-							commitMap["type"] = vcsType
-						}
-						body["sourceCodeEntry"] = commitMap
-					}
-				}
-			}
-			body["commits"] = commitsInBody
-		}
+		addCommitsToBody(body)
 
 		body["onlyVersion"] = onlyVersion
 
@@ -1176,7 +979,7 @@ var checkReleaseByHashCmd = &cobra.Command{
 		if resp == "null" {
 			resp = "{}"
 		}
-		fmt.Println(resp)
+		printOutput(resp)
 	},
 }
 
@@ -1186,7 +989,10 @@ var getLatestReleaseCmd = &cobra.Command{
 	Long: `This CLI command would connect to Reliza Hub and would obtain latest release for specified Project and Branch
 			or specified Product and Feature Set.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		getLatestReleaseFunc(debug, relizaHubUri, project, product, branch, environment, tagKey, tagVal, apiKeyId, apiKey, instance, namespace, status)
+		jsonResponse := getLatestReleaseFunc(debug, relizaHubUri, project, product, branch, environment, tagKey, tagVal, apiKeyId, apiKey, instance, namespace, status)
+		if string(jsonResponse) != "null" {
+			printOutput(string(jsonResponse))
+		}
 	},
 }
 
@@ -1207,7 +1013,7 @@ var getMyReleaseCmd = &cobra.Command{
 			}
 		`)
 		req.Var("namespace", namespace)
-		fmt.Println(sendRequest(req, "getMyRelease"))
+		printOutput(sendRequest(req, "getMyRelease"))
 	},
 }
 
@@ -1229,8 +1035,12 @@ var exportInstCmd = &cobra.Command{
 	Short: "Outputs the Cyclone DX spec of your instance",
 	Long:  `Outputs the Cyclone DX spec of your instance`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cycloneBytes := getInstanceRevisionCycloneDxExportV1(apiKeyId, apiKey, instance, revision, instanceURI, namespace)
-		fmt.Println(string(cycloneBytes))
+		cycloneBytes, err := newHubClient(apiKeyId, apiKey).InstanceRevisionCycloneDx(cliContext, instance, revision, instanceURI, namespace)
+		if err != nil {
+			printGqlError(err)
+			os.Exit(1)
+		}
+		printOutput(string(cycloneBytes))
 	},
 }
 
@@ -1239,8 +1049,12 @@ var exportBundleCmd = &cobra.Command{
 	Short: "Outputs the Cyclone DX spec of your bundle",
 	Long:  `Outputs the Cyclone DX spec of your bundle`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cycloneBytes := getBundleVersionCycloneDxExportV1(apiKeyId, apiKey, bundle, environment, version)
-		fmt.Println(string(cycloneBytes))
+		cycloneBytes, err := newHubClient(apiKeyId, apiKey).BundleVersionCycloneDx(cliContext, bundle, environment, version)
+		if err != nil {
+			printGqlError(err)
+			os.Exit(1)
+		}
+		printOutput(string(cycloneBytes))
 	},
 }
 
@@ -1290,6 +1104,7 @@ var prDataCmd = &cobra.Command{
 			fmt.Println("Using Reliza Hub at", relizaHubUri)
 		}
 
+		applyCiDefaults(nil)
 		body := map[string]interface{}{"branch": branch}
 
 		if len(state) > 0 {
@@ -1331,51 +1146,7 @@ var prDataCmd = &cobra.Command{
 			body["sourceCodeEntry"] = commitMap
 		}
 
-		if len(commits) > 0 {
-			// fmt.Println(commits)
-			plainCommits, err := base64.StdEncoding.DecodeString(commits)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
-			indCommits := strings.Split(string(plainCommits), "\n")
-			commitsInBody := make([]map[string]interface{}, len(indCommits)-1)
-			for i := range indCommits {
-				if len(indCommits[i]) > 0 {
-					singleCommitEl := map[string]interface{}{}
-					commitParts := strings.Split(indCommits[i], "|||")
-					singleCommitEl["commit"] = commitParts[0]
-					singleCommitEl["dateActual"] = commitParts[1]
-					singleCommitEl["commitMessage"] = commitParts[2]
-					if len(commitParts) > 3 {
-						singleCommitEl["commitAuthor"] = commitParts[3]
-						singleCommitEl["commitEmail"] = commitParts[4]
-					}
-					commitsInBody[i] = singleCommitEl
-
-					// if commit is not present but we are here, use first line as commit
-					if len(commit) < 1 && i == 0 {
-						commitMap := map[string]string{}
-						if len(commitParts) > 3 {
-							commitMap = map[string]string{"commit": commitParts[0], "dateActual": commitParts[1], "commitMessage": commitParts[2], "commitAuthor": commitParts[3], "commitEmail": commitParts[4]}
-						} else {
-							commitMap = map[string]string{"commit": commitParts[0], "dateActual": commitParts[1], "commitMessage": commitParts[2]}
-						}
-						if vcsTag != "" {
-							commitMap["vcsTag"] = vcsTag
-						}
-						if vcsUri != "" {
-							commitMap["uri"] = vcsUri
-						}
-						if vcsType != "" {
-							commitMap["type"] = vcsType
-						}
-						body["sourceCodeEntry"] = commitMap
-					}
-				}
-			}
-			body["commits"] = commitsInBody
-		}
+		addCommitsToBody(body)
 
 		if debug == "true" {
 			fmt.Println(body)
@@ -1393,12 +1164,29 @@ var prDataCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go exitOnInterrupt(ctx, stop)
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// interruptGracePeriod is how long a command may take to return after SIGINT or SIGTERM cancelled cliContext
+const interruptGracePeriod = 10 * time.Second
+
+// exitOnInterrupt restores default signal handling once the first signal cancelled ctx, so that a second
+// signal terminates the process, and exits if the command does not return within interruptGracePeriod,
+// since not every command can be cancelled, i.e. while reading stdin
+func exitOnInterrupt(ctx context.Context, stop context.CancelFunc) {
+	<-ctx.Done()
+	stop()
+	time.Sleep(interruptGracePeriod)
+	fmt.Println("Error: ", "interrupted")
+	os.Exit(130)
+}
+
 func init() {
 
 	// Here you will define your flags and configuration settings.
@@ -1410,12 +1198,14 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&apiKey, "apikey", "k", "", "API Key Secret")
 	rootCmd.PersistentFlags().StringVarP(&apiKeyId, "apikeyid", "i", "", "API Key ID")
 	rootCmd.PersistentFlags().StringVarP(&debug, "debug", "d", "false", "If set to true, print debug details")
+	rootCmd.PersistentFlags().DurationVar(&hubTimeout, "timeout", hub.DefaultTimeout, "Timeout for every single request attempt to Reliza Hub, i.e. 30s or 2m; 0 disables timeout")
+	rootCmd.PersistentFlags().IntVar(&hubRetries, "retries", hub.DefaultRetries, "Number of retries with exponential backoff on connection errors and 5xx responses from Reliza Hub")
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", output.FormatJSON, "Output format for query commands: "+strings.Join(output.Formats, ", "))
+	rootCmd.PersistentFlags().StringVar(&outputQuery, "query", "", "Field selector applied to query command output, i.e. '.artifactDetails[0].digests[0]' or Go template '{{ .version }}' (optional)")
 
 	// flags for addrelease command
 	addreleaseCmd.PersistentFlags().StringVarP(&branch, "branch", "b", "", "Name of VCS Branch used")
 	addreleaseCmd.PersistentFlags().StringVarP(&version, "version", "v", "", "Release version")
-	addreleaseCmd.MarkPersistentFlagRequired("version")
-	addreleaseCmd.MarkPersistentFlagRequired("branch")
 	addreleaseCmd.PersistentFlags().StringVar(&endpoint, "endpoint", "", "Test endpoint for this release")
 	addreleaseCmd.PersistentFlags().StringVar(&project, "project", "", "Project UUID for this release if org-wide key is used")
 	addreleaseCmd.PersistentFlags().StringVar(&vcsUri, "vcsuri", "", "URI of VCS repository")
@@ -1521,7 +1311,6 @@ func init() {
 
 	// flags for get version command
 	getVersionCmd.PersistentFlags().StringVarP(&branch, "branch", "b", "", "Name of VCS Branch used")
-	getVersionCmd.MarkPersistentFlagRequired("branch")
 	getVersionCmd.PersistentFlags().StringVar(&project, "project", "", "Project UUID for this release if org-wide key is used")
 	getVersionCmd.PersistentFlags().StringVar(&action, "action", "", "Bump action name: bump | bumppatch | bumpminor | bumpmajor | bumpdate")
 	getVersionCmd.PersistentFlags().StringVar(&metadata, "metadata", "", "Version metadata")
@@ -1621,27 +1410,8 @@ func sendRequest(req *graphql.Request, endpoint string) string {
 }
 
 func sendRequestWithUri(req *graphql.Request, endpoint string, uri string) string {
-	session, _ := getSession()
-	// if err != nil {
-	// 	fmt.Printf("Error making API request: %s\n", err)
-	// 	os.Exit(1)
-	// }
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Reliza Go Client")
-	req.Header.Set("Accept-Encoding", "gzip, deflate")
-	if session != nil {
-		req.Header.Set("X-CSRF-Token", session.Token)
-		req.Header.Set("Cookie", "JSESSIONID="+session.JSessionId)
-	}
-	if len(apiKeyId) > 0 && len(apiKey) > 0 {
-		auth := base64.StdEncoding.EncodeToString([]byte(apiKeyId + ":" + apiKey))
-		req.Header.Add("Authorization", "Basic "+auth)
-	}
-
 	var respData map[string]interface{}
-	client := graphql.NewClient(uri)
-	if err := client.Run(context.Background(), req, &respData); err != nil {
+	if err := getHubClient().RunWithUri(cliContext, uri, req, &respData); err != nil {
 		printGqlError(err)
 		os.Exit(1)
 	}
@@ -1650,6 +1420,26 @@ func sendRequestWithUri(req *graphql.Request, endpoint string, uri string) strin
 	return string(jsonResponse)
 }
 
+// printOutput prints json response of query command according to --output and --query flags
+func printOutput(jsonResponse string) {
+	printer := output.Printer{Format: outputFormat, Query: outputQuery}
+	out, err := printer.Render([]byte(jsonResponse))
+	if err != nil {
+		fmt.Println("Error: ", err)
+		os.Exit(1)
+	}
+	fmt.Println(out)
+}
+
+// getHubClient constructs Reliza Hub client based on global flags
+func getHubClient() *hub.Client {
+	return newHubClient(apiKeyId, apiKey)
+}
+
+func newHubClient(apiKeyId string, apiKey string) *hub.Client {
+	return hub.NewClient(relizaHubUri, apiKeyId, apiKey, hub.WithTimeout(hubTimeout), hub.WithRetries(hubRetries))
+}
+
 func printResponse(err error, resp *resty.Response) {
 	if debug == "true" {
 		// Explore response object
@@ -1740,61 +1530,3 @@ func printGqlError(err error) {
 	splitError := strings.Split(err.Error(), ":")
 	fmt.Println("Error: ", splitError[len(splitError)-1])
 }
-
-func getSession() (*RequestSession, error) {
-	client := resty.New()
-	var result map[string]string
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetHeader("User-Agent", "Reliza Go Client").
-		SetHeader("Accept-Encoding", "gzip, deflate").
-		SetResult(&result).
-		Get(relizaHubUri + "/api/manual/v1/fetchCsrf")
-
-	if err != nil {
-		return nil, err
-	}
-	// Extract cookies
-	session, err := getJSessionIDCookieAndToken(resp)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return session, err
-}
-
-func getJSessionIDCookieAndToken(resp *resty.Response) (*RequestSession, error) {
-	// Extract cookies
-	cookies := resp.Cookies()
-	var jsessionid string
-	for _, cookie := range cookies {
-		if cookie.Name == "JSESSIONID" {
-			jsessionid = cookie.Value
-			break
-		}
-	}
-
-	if jsessionid == "" {
-		return nil, fmt.Errorf("JSESSIONID cookie not found")
-	}
-
-	// Assume the token is returned in the response body as a JSON object
-	var result map[string]interface{}
-	err := json.Unmarshal(resp.Body(), &result)
-	if err != nil {
-		return nil, fmt.Errorf("error unmarshalling JSON: %s", err)
-	}
-
-	token, ok := result["token"].(string)
-	if !ok {
-		return nil, fmt.Errorf("token not found in the response body")
-	}
-
-	return &RequestSession{JSessionId: jsessionid, Token: token}, nil
-}
-
-type RequestSession struct {
-	JSessionId string
-	Token      string
-}
@@ -4,11 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
-	"io"
-
-	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/helm"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/yaml"
 )
@@ -42,7 +39,7 @@ var helmvalues = &cobra.Command{
 			fmt.Println("Error: only 1 argument expected")
 			os.Exit(1)
 		}
-		merged, err := mergeValues(valueFiles, chartpath)
+		merged, err := helm.MergeValues(valueFiles, chartpath)
 		if err != nil {
 			fmt.Println(err)
 			os.Exit(1)
@@ -71,54 +68,3 @@ var helmvalues = &cobra.Command{
 
 	},
 }
-
-// mergeValues merges values from files specified via -f/--values
-func mergeValues(valueFiles []string, directory string) (map[string]interface{}, error) {
-	base := map[string]interface{}{}
-
-	// User specified a values files via -f/--values
-	for _, filePath := range valueFiles {
-		currentMap := map[string]interface{}{}
-		filePath = filepath.Join(directory, filePath)
-		bytes, err := readFile(filePath)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := yaml.Unmarshal(bytes, &currentMap); err != nil {
-			return nil, errors.Wrapf(err, "failed to parse %s", filePath)
-		}
-		// Merge with the previous map
-		base = mergeMaps(base, currentMap)
-	}
-
-	return base, nil
-}
-
-func mergeMaps(a, b map[string]interface{}) map[string]interface{} {
-	out := make(map[string]interface{}, len(a))
-	for k, v := range a {
-		out[k] = v
-	}
-	for k, v := range b {
-		if v, ok := v.(map[string]interface{}); ok {
-			if bv, ok := out[k]; ok {
-				if bv, ok := bv.(map[string]interface{}); ok {
-					out[k] = mergeMaps(bv, v)
-					continue
-				}
-			}
-		}
-		out[k] = v
-	}
-	return out
-}
-
-// readFile load a file from stdin, the local directory, or a remote file with a url.
-func readFile(filePath string) ([]byte, error) {
-	if strings.TrimSpace(filePath) == "-" {
-		return io.ReadAll(os.Stdin)
-	}
-	return os.ReadFile(filePath)
-
-}
@@ -2,30 +2,71 @@
 package imports
 
 import (
+	_ "archive/tar"
 	_ "bufio"
 	_ "bytes"
+	_ "compress/gzip"
 	_ "context"
+	_ "crypto/aes"
+	_ "crypto/cipher"
+	_ "crypto/rand"
+	_ "crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/x509"
+	_ "embed"
+	_ "encoding/base32"
 	_ "encoding/base64"
+	_ "encoding/binary"
+	_ "encoding/hex"
 	_ "encoding/json"
+	_ "encoding/pem"
+	_ "encoding/xml"
+	_ "errors"
 	_ "fmt"
 	_ "github.com/go-resty/resty/v2"
 	_ "github.com/google/uuid"
 	_ "github.com/machinebox/graphql"
 	_ "github.com/mitchellh/go-homedir"
 	_ "github.com/pkg/errors"
+	_ "github.com/santhosh-tekuri/jsonschema/v6"
 	_ "github.com/spf13/cobra"
 	_ "github.com/spf13/pflag"
 	_ "github.com/spf13/viper"
+	_ "go.yaml.in/yaml/v3"
 	_ "io"
+	_ "k8s.io/api/core/v1"
+	_ "k8s.io/apimachinery/pkg/api/errors"
+	_ "k8s.io/apimachinery/pkg/apis/meta/v1"
+	_ "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	_ "k8s.io/apimachinery/pkg/runtime/schema"
+	_ "k8s.io/apimachinery/pkg/util/wait"
+	_ "k8s.io/client-go/dynamic"
+	_ "k8s.io/client-go/kubernetes"
+	_ "k8s.io/client-go/rest"
+	_ "k8s.io/client-go/tools/clientcmd"
+	_ "math"
+	_ "net/http"
+	_ "net/http/httptrace"
+	_ "net/url"
 	_ "os"
 	_ "os/exec"
+	_ "os/signal"
+	_ "path"
 	_ "path/filepath"
+	_ "reflect"
 	_ "regexp"
+	_ "runtime"
 	_ "sigs.k8s.io/yaml"
 	_ "sort"
 	_ "strconv"
 	_ "strings"
-	_ "testing"
+	_ "sync"
+	_ "sync/atomic"
+	_ "syscall"
+	_ "text/tabwriter"
 	_ "text/template"
 	_ "time"
+	_ "unicode"
+	_ "unicode/utf8"
 )
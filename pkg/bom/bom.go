@@ -0,0 +1,48 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package bom contains helpers to read Software Bill of Materials files
+package bom
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// ReadJsonFromFile reads json bom from the file and returns it as generic map
+func ReadJsonFromFile(filePath string) (map[string]interface{}, error) {
+	// Make sure infile is a file and not a directory
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	} else if fileInfo.IsDir() {
+		return nil, errors.Errorf("bom path must be a path to a file, not a directory: %s", filePath)
+	}
+	fileContentByteSlice, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse file content into json
+	var bomJSON map[string]interface{}
+	if err := json.Unmarshal(fileContentByteSlice, &bomJSON); err != nil {
+		return nil, errors.Wrapf(err, "error unmarshalling json bom file %s", filePath)
+	}
+	return bomJSON, nil
+}
@@ -0,0 +1,282 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package ci detects CI provider from environment variables and infers
+// source code and build metadata from it.
+package ci
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/vcs"
+)
+
+// Provider names accepted by FromProvider
+const (
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Jenkins   = "jenkins"
+	Azure     = "azure"
+	Bitbucket = "bitbucket"
+	CircleCI  = "circleci"
+)
+
+// Info is metadata inferred from CI environment, fields which could not be inferred are empty
+type Info struct {
+	Provider          string `json:"provider"`
+	Branch            string `json:"branch,omitempty"`
+	Commit            string `json:"commit,omitempty"`
+	CommitMessage     string `json:"commitMessage,omitempty"`
+	VcsUri            string `json:"vcsUri,omitempty"`
+	VcsType           string `json:"vcsType,omitempty"`
+	VcsTag            string `json:"vcsTag,omitempty"`
+	BuildId           string `json:"buildId,omitempty"`
+	BuildUri          string `json:"buildUri,omitempty"`
+	CiMeta            string `json:"ciMeta,omitempty"`
+	DateStart         string `json:"dateStart,omitempty"`
+	PullRequestNumber string `json:"pullRequestNumber,omitempty"`
+	TargetBranch      string `json:"targetBranch,omitempty"`
+}
+
+type provider struct {
+	name   string
+	detect func(getenv func(string) string) bool
+	info   func(getenv func(string) string) Info
+}
+
+// providers in the order of detection
+var providers = []provider{
+	{GitHub, func(getenv func(string) string) bool { return getenv("GITHUB_ACTIONS") == "true" }, githubInfo},
+	{GitLab, func(getenv func(string) string) bool { return getenv("GITLAB_CI") == "true" }, gitlabInfo},
+	{Azure, func(getenv func(string) string) bool { return strings.EqualFold(getenv("TF_BUILD"), "true") }, azureInfo},
+	{Bitbucket, func(getenv func(string) string) bool { return getenv("BITBUCKET_BUILD_NUMBER") != "" }, bitbucketInfo},
+	{CircleCI, func(getenv func(string) string) bool { return getenv("CIRCLECI") == "true" }, circleciInfo},
+	{Jenkins, func(getenv func(string) string) bool { return getenv("JENKINS_URL") != "" }, jenkinsInfo},
+}
+
+// Providers returns names of all supported providers
+func Providers() []string {
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Detect returns info of the first provider recognized in the environment, ok is false if none is recognized
+func Detect(getenv func(string) string) (info Info, ok bool) {
+	for _, p := range providers {
+		if p.detect(getenv) {
+			return finalize(p, getenv), true
+		}
+	}
+	return Info{}, false
+}
+
+// FromProvider infers info assuming the named provider, regardless of whether it was detected
+func FromProvider(name string, getenv func(string) string) (Info, error) {
+	for _, p := range providers {
+		if p.name == strings.ToLower(name) {
+			return finalize(p, getenv), nil
+		}
+	}
+	return Info{}, errors.Errorf("unsupported ci provider %s, must be one of %s", name, strings.Join(Providers(), ", "))
+}
+
+func finalize(p provider, getenv func(string) string) Info {
+	info := p.info(getenv)
+	info.Provider = p.name
+	if info.VcsUri != "" {
+		info.VcsUri = vcs.NormalizeRemoteURI(info.VcsUri)
+		info.VcsType = "git"
+	}
+	return info
+}
+
+// WithoutCommit returns info without commit and commit message. It is used when commit list is supplied, since
+// source code entry is then built from the top commit of the list, while commit of CI may differ from it, i.e. be
+// synthetic merge commit of pull request, and lacks date and author.
+func (info Info) WithoutCommit() Info {
+	info.Commit = ""
+	info.CommitMessage = ""
+	return info
+}
+
+// firstSet returns first non-empty value
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// refName splits git ref such as refs/heads/main or refs/tags/v1 into branch or tag
+func refName(ref string) (branch string, tag string) {
+	if strings.HasPrefix(ref, "refs/tags/") {
+		return "", strings.TrimPrefix(ref, "refs/tags/")
+	}
+	return strings.TrimPrefix(ref, "refs/heads/"), ""
+}
+
+func lastPathSegment(uri string) string {
+	uri = strings.TrimSuffix(uri, "/")
+	return uri[strings.LastIndex(uri, "/")+1:]
+}
+
+func githubInfo(getenv func(string) string) Info {
+	info := Info{
+		Commit:  getenv("GITHUB_SHA"),
+		BuildId: getenv("GITHUB_RUN_ID"),
+		CiMeta:  "GitHub Actions",
+	}
+	server := firstSet(getenv("GITHUB_SERVER_URL"), "https://github.com")
+	if repo := getenv("GITHUB_REPOSITORY"); repo != "" {
+		info.VcsUri = server + "/" + repo
+		if info.BuildId != "" {
+			info.BuildUri = server + "/" + repo + "/actions/runs/" + info.BuildId
+		}
+	}
+	if getenv("GITHUB_REF_TYPE") == "tag" {
+		info.VcsTag = getenv("GITHUB_REF_NAME")
+	} else {
+		info.Branch = firstSet(getenv("GITHUB_HEAD_REF"), getenv("GITHUB_REF_NAME"))
+	}
+	// pull request refs look like refs/pull/42/merge
+	if ref := getenv("GITHUB_REF"); strings.HasPrefix(ref, "refs/pull/") {
+		info.PullRequestNumber = strings.Split(strings.TrimPrefix(ref, "refs/pull/"), "/")[0]
+		info.TargetBranch = getenv("GITHUB_BASE_REF")
+		// GITHUB_SHA is synthetic merge commit here, pushed commit is only known from the event payload
+		info.Commit = githubPullRequestHeadSha(getenv("GITHUB_EVENT_PATH"))
+	}
+	return info
+}
+
+// githubPullRequestHeadSha returns pull_request.head.sha of the event payload file, or empty string if it can not be read
+func githubPullRequestHeadSha(eventPath string) string {
+	if eventPath == "" {
+		return ""
+	}
+	content, err := os.ReadFile(eventPath)
+	if err != nil {
+		return ""
+	}
+	var event struct {
+		PullRequest struct {
+			Head struct {
+				Sha string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(content, &event); err != nil {
+		return ""
+	}
+	return event.PullRequest.Head.Sha
+}
+
+func gitlabInfo(getenv func(string) string) Info {
+	info := Info{
+		Commit:            getenv("CI_COMMIT_SHA"),
+		CommitMessage:     getenv("CI_COMMIT_TITLE"),
+		VcsUri:            getenv("CI_PROJECT_URL"),
+		VcsTag:            getenv("CI_COMMIT_TAG"),
+		BuildId:           getenv("CI_JOB_ID"),
+		BuildUri:          getenv("CI_JOB_URL"),
+		CiMeta:            "GitLab CI",
+		DateStart:         getenv("CI_JOB_STARTED_AT"),
+		PullRequestNumber: getenv("CI_MERGE_REQUEST_IID"),
+		TargetBranch:      getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME"),
+	}
+	if info.VcsTag == "" {
+		info.Branch = firstSet(getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"), getenv("CI_COMMIT_BRANCH"), getenv("CI_COMMIT_REF_NAME"))
+	}
+	return info
+}
+
+func azureInfo(getenv func(string) string) Info {
+	info := Info{
+		Commit:            getenv("BUILD_SOURCEVERSION"),
+		CommitMessage:     getenv("BUILD_SOURCEVERSIONMESSAGE"),
+		VcsUri:            getenv("BUILD_REPOSITORY_URI"),
+		BuildId:           getenv("BUILD_BUILDID"),
+		CiMeta:            "Azure Pipelines",
+		PullRequestNumber: firstSet(getenv("SYSTEM_PULLREQUEST_PULLREQUESTNUMBER"), getenv("SYSTEM_PULLREQUEST_PULLREQUESTID")),
+	}
+	info.Branch, info.VcsTag = refName(firstSet(getenv("SYSTEM_PULLREQUEST_SOURCEBRANCH"), getenv("BUILD_SOURCEBRANCH")))
+	if info.PullRequestNumber != "" {
+		info.TargetBranch, _ = refName(getenv("SYSTEM_PULLREQUEST_TARGETBRANCH"))
+	}
+	if collection := getenv("SYSTEM_COLLECTIONURI"); collection != "" && info.BuildId != "" {
+		info.BuildUri = strings.TrimSuffix(collection, "/") + "/" + getenv("SYSTEM_TEAMPROJECT") + "/_build/results?buildId=" + info.BuildId
+	}
+	return info
+}
+
+func bitbucketInfo(getenv func(string) string) Info {
+	info := Info{
+		Commit:            getenv("BITBUCKET_COMMIT"),
+		Branch:            getenv("BITBUCKET_BRANCH"),
+		VcsTag:            getenv("BITBUCKET_TAG"),
+		VcsUri:            getenv("BITBUCKET_GIT_HTTP_ORIGIN"),
+		BuildId:           getenv("BITBUCKET_BUILD_NUMBER"),
+		CiMeta:            "Bitbucket Pipelines",
+		PullRequestNumber: getenv("BITBUCKET_PR_ID"),
+		TargetBranch:      getenv("BITBUCKET_PR_DESTINATION_BRANCH"),
+	}
+	if repo := getenv("BITBUCKET_REPO_FULL_NAME"); repo != "" {
+		info.BuildUri = "https://bitbucket.org/" + repo + "/pipelines/results/" + info.BuildId
+	}
+	return info
+}
+
+func circleciInfo(getenv func(string) string) Info {
+	info := Info{
+		Commit:   getenv("CIRCLE_SHA1"),
+		Branch:   getenv("CIRCLE_BRANCH"),
+		VcsTag:   getenv("CIRCLE_TAG"),
+		VcsUri:   getenv("CIRCLE_REPOSITORY_URL"),
+		BuildId:  getenv("CIRCLE_BUILD_NUM"),
+		BuildUri: getenv("CIRCLE_BUILD_URL"),
+		CiMeta:   "CircleCI",
+	}
+	if pr := getenv("CIRCLE_PULL_REQUEST"); pr != "" {
+		info.PullRequestNumber = lastPathSegment(pr)
+	}
+	return info
+}
+
+func jenkinsInfo(getenv func(string) string) Info {
+	info := Info{
+		Commit:            getenv("GIT_COMMIT"),
+		VcsUri:            getenv("GIT_URL"),
+		VcsTag:            getenv("TAG_NAME"),
+		BuildId:           getenv("BUILD_NUMBER"),
+		BuildUri:          getenv("BUILD_URL"),
+		CiMeta:            "Jenkins",
+		PullRequestNumber: getenv("CHANGE_ID"),
+		TargetBranch:      getenv("CHANGE_TARGET"),
+	}
+	if info.VcsTag == "" {
+		// GIT_BRANCH of git plugin is prefixed with remote name, i.e. origin/main
+		info.Branch = firstSet(getenv("CHANGE_BRANCH"), getenv("BRANCH_NAME"), strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/"))
+	}
+	return info
+}
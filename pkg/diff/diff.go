@@ -0,0 +1,300 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package diff produces unified diffs of text files line by line.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ContextLines is the number of unchanged lines shown around each change
+const ContextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	// indexes of the line in a and b, for inserts aIndex is the position in a where insert happens and vice versa
+	aIndex int
+	bIndex int
+}
+
+// SplitLines splits text into lines, trailing newline does not produce an empty line
+func SplitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Unified returns unified diff between a and b, or empty string if they are equal line by line
+// (missing newline at the end of file is not considered a change).
+// fromName and toName are used in the --- and +++ header lines.
+func Unified(fromName string, toName string, a string, b string) string {
+	aLines := SplitLines(a)
+	bLines := SplitLines(b)
+	ops := myers(aLines, bLines)
+	if len(hunks(ops)) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	out.WriteString("--- " + fromName + "\n")
+	out.WriteString("+++ " + toName + "\n")
+	for _, h := range hunks(ops) {
+		writeHunk(&out, ops[h[0]:h[1]], aLines, bLines)
+	}
+	return out.String()
+}
+
+// myers computes shortest edit script between a and b. It uses linear space variant of the algorithm, which
+// finds the middle of the edit path by searching from both ends and recurses into both halves, so memory does
+// not grow with the number of differences.
+func myers(a []string, b []string) []op {
+	d := differ{a: a, b: b}
+	if haveCommonLine(a, b) {
+		d.diff(0, len(a), 0, len(b))
+	} else {
+		// search would visit every diagonal without finding anything, i.e. when outfile is missing
+		d.replace(0, len(a), 0, len(b))
+	}
+	return d.ops
+}
+
+func haveCommonLine(a []string, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+	lines := make(map[string]bool, len(a))
+	for _, line := range a {
+		lines[line] = true
+	}
+	for _, line := range b {
+		if lines[line] {
+			return true
+		}
+	}
+	return false
+}
+
+type differ struct {
+	a   []string
+	b   []string
+	ops []op
+}
+
+// diff appends ops turning a[aLo:aHi] into b[bLo:bHi]
+func (d *differ) diff(aLo int, aHi int, bLo int, bHi int) {
+	// common prefix and suffix are equal lines, whatever the edit path
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.ops = append(d.ops, op{kind: opEqual, aIndex: aLo, bIndex: bLo})
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aLo < aHi-suffix && bLo < bHi-suffix && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aHi -= suffix
+	bHi -= suffix
+
+	if aLo == aHi || bLo == bHi {
+		d.replace(aLo, aHi, bLo, bHi)
+	} else if x, y, ok := d.bisect(aLo, aHi, bLo, bHi); ok {
+		d.diff(aLo, x, bLo, y)
+		d.diff(x, aHi, y, bHi)
+	} else {
+		d.replace(aLo, aHi, bLo, bHi)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.ops = append(d.ops, op{kind: opEqual, aIndex: aHi + i, bIndex: bHi + i})
+	}
+}
+
+// replace appends ops deleting a[aLo:aHi] and inserting b[bLo:bHi]
+func (d *differ) replace(aLo int, aHi int, bLo int, bHi int) {
+	for x := aLo; x < aHi; x++ {
+		d.ops = append(d.ops, op{kind: opDelete, aIndex: x, bIndex: bLo})
+	}
+	for y := bLo; y < bHi; y++ {
+		d.ops = append(d.ops, op{kind: opInsert, aIndex: aHi, bIndex: y})
+	}
+}
+
+// bisect returns point where forward and reverse searches for the shortest edit path of a[aLo:aHi] and
+// b[bLo:bHi] meet, both halves of the path are then found independently
+func (d *differ) bisect(aLo int, aHi int, bLo int, bHi int) (int, int, bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	vForward := make([]int, 2*maxD+2)
+	vReverse := make([]int, 2*maxD+2)
+	for i := range vForward {
+		vForward[i] = -1
+		vReverse[i] = -1
+	}
+	vForward[offset+1] = 0
+	vReverse[offset+1] = 0
+	delta := n - m
+	// with odd delta paths meet while searching forward, otherwise while searching in reverse
+	front := delta%2 != 0
+	kForwardStart, kForwardEnd, kReverseStart, kReverseEnd := 0, 0, 0, 0
+	for step := 0; step < maxD; step++ {
+		for k := -step + kForwardStart; k <= step-kForwardEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && vForward[offset+k-1] < vForward[offset+k+1]) {
+				x = vForward[offset+k+1]
+			} else {
+				x = vForward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			vForward[offset+k] = x
+			if x > n {
+				kForwardEnd += 2
+			} else if y > m {
+				kForwardStart += 2
+			} else if front {
+				reverseK := offset + delta - k
+				if reverseK >= 0 && reverseK < len(vReverse) && vReverse[reverseK] != -1 && x >= n-vReverse[reverseK] {
+					return aLo + x, bLo + y, true
+				}
+			}
+		}
+		for k := -step + kReverseStart; k <= step-kReverseEnd; k += 2 {
+			var x int
+			if k == -step || (k != step && vReverse[offset+k-1] < vReverse[offset+k+1]) {
+				x = vReverse[offset+k+1]
+			} else {
+				x = vReverse[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[aHi-x-1] == d.b[bHi-y-1] {
+				x++
+				y++
+			}
+			vReverse[offset+k] = x
+			if x > n {
+				kReverseEnd += 2
+			} else if y > m {
+				kReverseStart += 2
+			} else if !front {
+				forwardK := offset + delta - k
+				if forwardK >= 0 && forwardK < len(vForward) && vForward[forwardK] != -1 {
+					forwardX := vForward[forwardK]
+					forwardY := offset + forwardX - forwardK
+					if forwardX >= n-x {
+						return aLo + forwardX, bLo + forwardY, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// hunks groups ops into ranges [start, end) containing changes with surrounding context
+func hunks(ops []op) [][2]int {
+	var result [][2]int
+	for i := 0; i < len(ops); {
+		if ops[i].kind == opEqual {
+			i++
+			continue
+		}
+		start := i - ContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != opEqual {
+				end++
+				continue
+			}
+			// count run of equal lines, hunk continues if the run is short enough to merge with next change
+			run := 0
+			for end+run < len(ops) && ops[end+run].kind == opEqual {
+				run++
+			}
+			if end+run < len(ops) && run <= 2*ContextLines {
+				end += run
+				continue
+			}
+			if run > ContextLines {
+				run = ContextLines
+			}
+			end += run
+			break
+		}
+		if len(result) > 0 && start < result[len(result)-1][1] {
+			start = result[len(result)-1][1]
+		}
+		result = append(result, [2]int{start, end})
+		i = end
+	}
+	return result
+}
+
+func writeHunk(out *strings.Builder, ops []op, a []string, b []string) {
+	aStart, bStart := ops[0].aIndex, ops[0].bIndex
+	aCount, bCount := 0, 0
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			aCount++
+			bCount++
+		case opDelete:
+			aCount++
+		case opInsert:
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			out.WriteString(" " + a[o.aIndex] + "\n")
+		case opDelete:
+			out.WriteString("-" + a[o.aIndex] + "\n")
+		case opInsert:
+			out.WriteString("+" + b[o.bIndex] + "\n")
+		}
+	}
+}
+
+// hunkRange formats start,count pair, start is 1-based unless the range is empty
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
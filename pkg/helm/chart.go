@@ -0,0 +1,228 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package helm renders helm charts without helm binary, it supports chart directories and packaged
+// subcharts, values coalescing with globals, dependency conditions and a sprig compatible subset of template functions.
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Metadata is the subset of Chart.yaml used for rendering
+type Metadata struct {
+	ApiVersion   string       `json:"apiVersion"`
+	Name         string       `json:"name"`
+	Version      string       `json:"version"`
+	AppVersion   string       `json:"appVersion,omitempty"`
+	Description  string       `json:"description,omitempty"`
+	Type         string       `json:"type,omitempty"`
+	KubeVersion  string       `json:"kubeVersion,omitempty"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+// Dependency is a chart dependency declared in Chart.yaml, it must be present in charts directory
+type Dependency struct {
+	Name      string `json:"name"`
+	Alias     string `json:"alias,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// Chart is a loaded chart with its templates, other files and subcharts
+type Chart struct {
+	Metadata  Metadata
+	Values    map[string]interface{}
+	Templates map[string]string // path relative to chart, i.e. templates/deployment.yaml
+	Files     map[string][]byte // non template files, available as .Files
+	Charts    []*Chart
+	// Alias is the name under which subchart is used by its parent, defaults to chart name
+	Alias string
+}
+
+// Name returns name under which chart is used by its parent
+func (c *Chart) Name() string {
+	if len(c.Alias) > 0 {
+		return c.Alias
+	}
+	return c.Metadata.Name
+}
+
+// LoadChart loads chart from directory or packaged .tgz chart
+func LoadChart(chartPath string) (*Chart, error) {
+	fileInfo, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if !fileInfo.IsDir() {
+		content, err := os.ReadFile(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		return loadArchive(content)
+	}
+	files := map[string][]byte{}
+	err = filepath.WalkDir(chartPath, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(chartPath, p)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = content
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loadFiles(files)
+}
+
+func loadArchive(content []byte) (*Chart, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read chart archive")
+	}
+	defer gz.Close()
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read chart archive")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// archive contains chart directory as the top level entry
+		name := path.Clean(header.Name)
+		if i := strings.Index(name, "/"); i > -1 {
+			name = name[i+1:]
+		}
+		fileContent, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[name] = fileContent
+	}
+	return loadFiles(files)
+}
+
+// loadFiles builds chart from files keyed by slash separated path relative to chart root
+func loadFiles(files map[string][]byte) (*Chart, error) {
+	chart := &Chart{Values: map[string]interface{}{}, Templates: map[string]string{}, Files: map[string][]byte{}}
+	chartYaml, ok := files["Chart.yaml"]
+	if !ok {
+		return nil, errors.New("Chart.yaml not found")
+	}
+	if err := yaml.Unmarshal(chartYaml, &chart.Metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to parse Chart.yaml")
+	}
+	if len(chart.Metadata.Name) < 1 {
+		return nil, errors.New("chart name is missing in Chart.yaml")
+	}
+	if valuesYaml, ok := files["values.yaml"]; ok {
+		if err := yaml.Unmarshal(valuesYaml, &chart.Values); err != nil {
+			return nil, errors.Wrap(err, "failed to parse values.yaml")
+		}
+		if chart.Values == nil {
+			chart.Values = map[string]interface{}{}
+		}
+	}
+
+	subchartFiles := map[string]map[string][]byte{}
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := files[name]
+		switch {
+		case strings.HasPrefix(name, "templates/"):
+			chart.Templates[name] = string(content)
+		case strings.HasPrefix(name, "charts/"):
+			rest := strings.TrimPrefix(name, "charts/")
+			if !strings.Contains(rest, "/") {
+				if strings.HasSuffix(rest, ".tgz") {
+					subchart, err := loadArchive(content)
+					if err != nil {
+						return nil, errors.Wrapf(err, "failed to load subchart %s", name)
+					}
+					chart.Charts = append(chart.Charts, subchart)
+				}
+				continue
+			}
+			dir, subpath, _ := strings.Cut(rest, "/")
+			if subchartFiles[dir] == nil {
+				subchartFiles[dir] = map[string][]byte{}
+			}
+			subchartFiles[dir][subpath] = content
+		case name == "Chart.yaml" || name == "values.yaml" || name == "Chart.lock" || name == "requirements.yaml":
+		default:
+			chart.Files[name] = content
+		}
+	}
+	var dirs []string
+	for dir := range subchartFiles {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		subchart, err := loadFiles(subchartFiles[dir])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load subchart %s", dir)
+		}
+		chart.Charts = append(chart.Charts, subchart)
+	}
+	chart.applyAliases()
+	return chart, nil
+}
+
+// applyAliases sets aliases of subcharts according to dependencies, a dependency may only be aliased once
+func (c *Chart) applyAliases() {
+	for _, dep := range c.Metadata.Dependencies {
+		if len(dep.Alias) < 1 {
+			continue
+		}
+		for _, sub := range c.Charts {
+			if sub.Metadata.Name == dep.Name && len(sub.Alias) < 1 {
+				sub.Alias = dep.Alias
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,1012 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package helm
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+/*
+funcMap returns sprig compatible subset of template functions together with helm specific ones
+(toYaml, fromYaml, toJson, fromJson, required, lookup), include and tpl are added by Render.
+Functions which depend on randomness, network or cluster state are either omitted or return empty values,
+so that rendering is reproducible.
+*/
+func funcMap() template.FuncMap {
+	return template.FuncMap{
+		// strings
+		"trim":       strings.TrimSpace,
+		"trimAll":    func(cutset string, s string) string { return strings.Trim(s, cutset) },
+		"trimPrefix": func(prefix string, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix string, s string) string { return strings.TrimSuffix(s, suffix) },
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"title":      title,
+		"untitle":    untitle,
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"substr":     substr,
+		"nospace":    func(s string) string { return strings.Join(strings.Fields(s), "") },
+		"trunc":      trunc,
+		"abbrev":     abbrev,
+		"contains":   func(substr string, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix string, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix string, s string) bool { return strings.HasSuffix(s, suffix) },
+		"quote":      quote,
+		"squote":     squote,
+		"cat":        cat,
+		"indent":     indent,
+		"nindent":    func(spaces int, s string) string { return "\n" + indent(spaces, s) },
+		"replace":    func(old string, new string, s string) string { return strings.ReplaceAll(s, old, new) },
+		"snakecase":  snakecase,
+		"kebabcase":  func(s string) string { return strings.ReplaceAll(snakecase(s), "_", "-") },
+		"camelcase":  camelcase,
+		"split":      split,
+		"splitList":  func(sep string, s string) []string { return strings.Split(s, sep) },
+		"splitn":     splitn,
+		"join":       join,
+		"toString":   toString,
+		"toStrings":  toStrings,
+		"plural": func(one string, many string, count int) string {
+			if count == 1 {
+				return one
+			}
+			return many
+		},
+		"wrapWith": func(length int, sep string, s string) string { return wrap(length, sep, s) },
+		"wrap":     func(length int, s string) string { return wrap(length, "\n", s) },
+
+		// regular expressions
+		"regexMatch":                 func(regex string, s string) (bool, error) { return regexp.MatchString(regex, s) },
+		"mustRegexMatch":             func(regex string, s string) (bool, error) { return regexp.MatchString(regex, s) },
+		"regexFind":                  regexFind,
+		"regexFindAll":               regexFindAll,
+		"regexReplaceAll":            regexReplaceAll,
+		"regexReplaceAllLiteral":     regexReplaceAllLiteral,
+		"regexSplit":                 regexSplit,
+		"mustRegexReplaceAll":        regexReplaceAll,
+		"mustRegexReplaceAllLiteral": regexReplaceAllLiteral,
+
+		// encoding and hashes
+		"b64enc":    func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":    b64dec,
+		"b32enc":    func(s string) string { return base32.StdEncoding.EncodeToString([]byte(s)) },
+		"b32dec":    b32dec,
+		"sha256sum": func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"sha1sum":   func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+
+		// defaults and flow control
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary": func(whenTrue interface{}, whenFalse interface{}, condition bool) interface{} {
+			if condition {
+				return whenTrue
+			}
+			return whenFalse
+		},
+		"required": required,
+		"fail":     func(msg string) (string, error) { return "", errors.New(msg) },
+
+		// serialization
+		"toYaml":        toYaml,
+		"mustToYaml":    mustToYaml,
+		"fromYaml":      fromYaml,
+		"fromYamlArray": fromYamlArray,
+		"toJson":        toJson,
+		"mustToJson":    mustToJson,
+		"toPrettyJson":  toPrettyJson,
+		"toRawJson":     toJson,
+		"fromJson":      fromJson,
+		"fromJsonArray": fromJsonArray,
+
+		// type conversion and inspection
+		"int":       func(v interface{}) int { return int(toInt64(v)) },
+		"int64":     toInt64,
+		"float64":   toFloat64,
+		"atoi":      func(s string) int { i, _ := strconv.Atoi(s); return i },
+		"toDecimal": func(v interface{}) int64 { i, _ := strconv.ParseInt(toString(v), 8, 64); return i },
+		"kindOf":    func(v interface{}) string { return reflect.ValueOf(v).Kind().String() },
+		"kindIs":    func(kind string, v interface{}) bool { return reflect.ValueOf(v).Kind().String() == kind },
+		"typeOf":    func(v interface{}) string { return fmt.Sprintf("%T", v) },
+		"typeIs":    func(typ string, v interface{}) bool { return fmt.Sprintf("%T", v) == typ },
+		"deepEqual": reflect.DeepEqual,
+
+		// math
+		"add": func(i ...interface{}) int64 {
+			var sum int64
+			for _, v := range i {
+				sum += toInt64(v)
+			}
+			return sum
+		},
+		"add1": func(i interface{}) int64 { return toInt64(i) + 1 },
+		"sub":  func(a interface{}, b interface{}) int64 { return toInt64(a) - toInt64(b) },
+		"mul": func(a interface{}, v ...interface{}) int64 {
+			product := toInt64(a)
+			for _, b := range v {
+				product *= toInt64(b)
+			}
+			return product
+		},
+		"div":   func(a interface{}, b interface{}) int64 { return toInt64(a) / toInt64(b) },
+		"mod":   func(a interface{}, b interface{}) int64 { return toInt64(a) % toInt64(b) },
+		"max":   maxInt,
+		"min":   minInt,
+		"floor": func(a interface{}) float64 { return math.Floor(toFloat64(a)) },
+		"ceil":  func(a interface{}) float64 { return math.Ceil(toFloat64(a)) },
+		"round": round,
+
+		// lists
+		"list":       func(v ...interface{}) []interface{} { return v },
+		"first":      first,
+		"last":       last,
+		"rest":       rest,
+		"initial":    initial,
+		"append":     push,
+		"push":       push,
+		"mustAppend": push,
+		"mustPush":   push,
+		"prepend":    prepend,
+		"concat":     concat,
+		"uniq":       uniq,
+		"has":        has,
+		"mustHas":    has,
+		"without":    without,
+		"compact":    compact,
+		"reverse":    reverse,
+		"sortAlpha":  sortAlpha,
+		"until":      func(count int) []int { return untilStep(0, count, 1) },
+		"untilStep":  untilStep,
+		"slice":      slice,
+
+		// dictionaries
+		"dict": dict,
+		"get": func(d map[string]interface{}, key string) interface{} {
+			if v, ok := d[key]; ok {
+				return v
+			}
+			return ""
+		},
+		"set": func(d map[string]interface{}, key string, value interface{}) map[string]interface{} {
+			d[key] = value
+			return d
+		},
+		"unset":          func(d map[string]interface{}, key string) map[string]interface{} { delete(d, key); return d },
+		"hasKey":         func(d map[string]interface{}, key string) bool { _, ok := d[key]; return ok },
+		"keys":           keys,
+		"values":         values,
+		"pluck":          pluck,
+		"pick":           pick,
+		"omit":           omit,
+		"merge":          merge,
+		"mustMerge":      merge,
+		"mergeOverwrite": mergeOverwrite,
+		"deepCopy":       deepCopy,
+		"mustDeepCopy":   deepCopy,
+		"dig":            dig,
+
+		// semantic versions
+		"semverCompare": semverCompare,
+		"semver":        parseSemverFunc,
+
+		// dates, only formatting of current time is supported
+		"now":  time.Now,
+		"date": func(format string, date interface{}) string { return toTime(date).Format(format) },
+
+		// cluster lookups are not available without cluster access
+		"lookup": func(apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+			return map[string]interface{}{}, nil
+		},
+	}
+}
+
+func title(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(prev) {
+			prev = r
+			return unicode.ToTitle(r)
+		}
+		prev = r
+		return r
+	}, s)
+}
+
+func untitle(s string) string {
+	prev := ' '
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(prev) {
+			prev = r
+			return unicode.ToLower(r)
+		}
+		prev = r
+		return r
+	}, s)
+}
+
+func substr(start int, end int, s string) string {
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 || end > len(s) {
+		end = len(s)
+	}
+	if start > end {
+		return ""
+	}
+	return s[start:end]
+}
+
+func trunc(c int, s string) string {
+	if c < 0 && len(s)+c > 0 {
+		return s[len(s)+c:]
+	}
+	if c >= 0 && len(s) > c {
+		return s[:c]
+	}
+	return s
+}
+
+func abbrev(width int, s string) string {
+	if width < 4 || len(s) <= width {
+		return s
+	}
+	return s[:width-3] + "..."
+}
+
+func quote(str ...interface{}) string {
+	out := make([]string, 0, len(str))
+	for _, s := range str {
+		if s != nil {
+			out = append(out, fmt.Sprintf("%q", toString(s)))
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func squote(str ...interface{}) string {
+	out := make([]string, 0, len(str))
+	for _, s := range str {
+		if s != nil {
+			out = append(out, "'"+toString(s)+"'")
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+func cat(v ...interface{}) string {
+	var parts []string
+	for _, s := range v {
+		if s != nil {
+			parts = append(parts, toString(s))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func snakecase(s string) string {
+	var out strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				out.WriteRune('_')
+			}
+			out.WriteRune(unicode.ToLower(r))
+		} else if r == '-' || r == ' ' {
+			out.WriteRune('_')
+		} else {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+func camelcase(s string) string {
+	parts := strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' || r == ' ' })
+	for i, p := range parts {
+		parts[i] = strings.ToUpper(p[:1]) + p[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func split(sep string, s string) map[string]string {
+	res := map[string]string{}
+	for i, v := range strings.Split(s, sep) {
+		res["_"+strconv.Itoa(i)] = v
+	}
+	return res
+}
+
+func splitn(sep string, n int, s string) map[string]string {
+	res := map[string]string{}
+	for i, v := range strings.SplitN(s, sep, n) {
+		res["_"+strconv.Itoa(i)] = v
+	}
+	return res
+}
+
+func join(sep string, v interface{}) string {
+	return strings.Join(toStrings(v), sep)
+}
+
+func wrap(length int, sep string, s string) string {
+	var lines []string
+	var current string
+	for _, word := range strings.Fields(s) {
+		if len(current) > 0 && len(current)+1+len(word) > length {
+			lines = append(lines, current)
+			current = word
+		} else if len(current) > 0 {
+			current += " " + word
+		} else {
+			current = word
+		}
+	}
+	if len(current) > 0 {
+		lines = append(lines, current)
+	}
+	return strings.Join(lines, sep)
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func toStrings(v interface{}) []string {
+	if v == nil {
+		return []string{}
+	}
+	if s, ok := v.([]string); ok {
+		return s
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return []string{toString(v)}
+	}
+	out := make([]string, 0, val.Len())
+	for i := 0; i < val.Len(); i++ {
+		if item := val.Index(i).Interface(); item != nil {
+			out = append(out, toString(item))
+		}
+	}
+	return out
+}
+
+func regexFind(regex string, s string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return r.FindString(s), nil
+}
+
+func regexFindAll(regex string, s string, n int) ([]string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return r.FindAllString(s, n), nil
+}
+
+func regexReplaceAll(regex string, s string, repl string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return r.ReplaceAllString(s, repl), nil
+}
+
+func regexReplaceAllLiteral(regex string, s string, repl string) (string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return "", err
+	}
+	return r.ReplaceAllLiteralString(s, repl), nil
+}
+
+func regexSplit(regex string, s string, n int) ([]string, error) {
+	r, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	return r.Split(s, n), nil
+}
+
+func b64dec(s string) string {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(decoded)
+}
+
+func b32dec(s string) string {
+	decoded, err := base32.StdEncoding.DecodeString(s)
+	if err != nil {
+		return err.Error()
+	}
+	return string(decoded)
+}
+
+// empty follows sprig: nil, zero values and empty collections are empty
+func empty(given interface{}) bool {
+	g := reflect.ValueOf(given)
+	if !g.IsValid() {
+		return true
+	}
+	switch g.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+		return g.Len() == 0
+	case reflect.Bool:
+		return !g.Bool()
+	case reflect.Complex64, reflect.Complex128:
+		return g.Complex() == 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return g.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return g.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return g.Float() == 0
+	case reflect.Struct:
+		return false
+	default:
+		return g.IsNil()
+	}
+}
+
+func defaultValue(d interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || empty(given[0]) {
+		return d
+	}
+	return given[0]
+}
+
+func coalesce(v ...interface{}) interface{} {
+	for _, val := range v {
+		if !empty(val) {
+			return val
+		}
+	}
+	return nil
+}
+
+func required(warn string, val interface{}) (interface{}, error) {
+	if val == nil {
+		return val, errors.New(warn)
+	} else if s, ok := val.(string); ok && s == "" {
+		return val, errors.New(warn)
+	}
+	return val, nil
+}
+
+func mustToYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func fromYaml(s string) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(s), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+	return m
+}
+
+func fromYamlArray(s string) []interface{} {
+	var a []interface{}
+	if err := yaml.Unmarshal([]byte(s), &a); err != nil {
+		a = []interface{}{err.Error()}
+	}
+	return a
+}
+
+func toJson(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func mustToJson(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+func toPrettyJson(v interface{}) string {
+	data, _ := json.MarshalIndent(v, "", "  ")
+	return string(data)
+}
+
+func fromJson(s string) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		m["Error"] = err.Error()
+	}
+	return m
+}
+
+func fromJsonArray(s string) []interface{} {
+	var a []interface{}
+	if err := json.Unmarshal([]byte(s), &a); err != nil {
+		a = []interface{}{err.Error()}
+	}
+	return a
+}
+
+func toInt64(v interface{}) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		if i, err := strconv.ParseInt(v, 0, 64); err == nil {
+			return i
+		}
+		f, _ := strconv.ParseFloat(v, 64)
+		return int64(f)
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(val.Float())
+	}
+	return 0
+}
+
+func toFloat64(v interface{}) float64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	case bool:
+		if v {
+			return 1
+		}
+		return 0
+	}
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		return val.Float()
+	}
+	return 0
+}
+
+func maxInt(a interface{}, i ...interface{}) int64 {
+	result := toInt64(a)
+	for _, b := range i {
+		if v := toInt64(b); v > result {
+			result = v
+		}
+	}
+	return result
+}
+
+func minInt(a interface{}, i ...interface{}) int64 {
+	result := toInt64(a)
+	for _, b := range i {
+		if v := toInt64(b); v < result {
+			result = v
+		}
+	}
+	return result
+}
+
+func round(a interface{}, p int, rOpt ...float64) float64 {
+	roundOn := .5
+	if len(rOpt) > 0 {
+		roundOn = rOpt[0]
+	}
+	pow := math.Pow(10, float64(p))
+	digit := pow * toFloat64(a)
+	_, div := math.Modf(digit)
+	if div >= roundOn {
+		return math.Ceil(digit) / pow
+	}
+	return math.Floor(digit) / pow
+}
+
+// toList converts any slice or array into []interface{}
+func toList(list interface{}) ([]interface{}, error) {
+	if list == nil {
+		return []interface{}{}, nil
+	}
+	if l, ok := list.([]interface{}); ok {
+		return l, nil
+	}
+	val := reflect.ValueOf(list)
+	if val.Kind() != reflect.Slice && val.Kind() != reflect.Array {
+		return nil, errors.Errorf("cannot use type %T as list", list)
+	}
+	out := make([]interface{}, val.Len())
+	for i := range out {
+		out[i] = val.Index(i).Interface()
+	}
+	return out, nil
+}
+
+func first(list interface{}) (interface{}, error) {
+	l, err := toList(list)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[0], nil
+}
+
+func last(list interface{}) (interface{}, error) {
+	l, err := toList(list)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[len(l)-1], nil
+}
+
+func rest(list interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[1:], nil
+}
+
+func initial(list interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[:len(l)-1], nil
+}
+
+func push(list interface{}, v interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]interface{}{}, l...), v), nil
+}
+
+func prepend(list interface{}, v interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	return append([]interface{}{v}, l...), nil
+}
+
+func concat(lists ...interface{}) ([]interface{}, error) {
+	var res []interface{}
+	for _, list := range lists {
+		l, err := toList(list)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l...)
+	}
+	return res, nil
+}
+
+func has(needle interface{}, haystack interface{}) (bool, error) {
+	l, err := toList(haystack)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range l {
+		if reflect.DeepEqual(v, needle) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func uniq(list interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	var res []interface{}
+	for _, v := range l {
+		if found, _ := has(v, res); !found {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func without(list interface{}, omit ...interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	var res []interface{}
+	for _, v := range l {
+		if found, _ := has(v, omit); !found {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func compact(list interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	var res []interface{}
+	for _, v := range l {
+		if !empty(v) {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func reverse(list interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]interface{}, len(l))
+	for i, v := range l {
+		res[len(l)-1-i] = v
+	}
+	return res, nil
+}
+
+func sortAlpha(list interface{}) []string {
+	s := toStrings(list)
+	sorted := append([]string{}, s...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func untilStep(start int, stop int, step int) []int {
+	var v []int
+	if step == 0 || (stop < start && step > 0) || (stop > start && step < 0) {
+		return v
+	}
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		v = append(v, i)
+	}
+	return v
+}
+
+func slice(list interface{}, indices ...interface{}) (interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	start, end := 0, len(l)
+	if len(indices) > 0 {
+		start = int(toInt64(indices[0]))
+	}
+	if len(indices) > 1 {
+		end = int(toInt64(indices[1]))
+	}
+	if start < 0 || end > len(l) || start > end {
+		return nil, errors.Errorf("slice indexes out of range [%d:%d]", start, end)
+	}
+	return l[start:end], nil
+}
+
+func dict(v ...interface{}) map[string]interface{} {
+	d := map[string]interface{}{}
+	for i := 0; i < len(v); i += 2 {
+		key := toString(v[i])
+		if i+1 >= len(v) {
+			d[key] = ""
+			continue
+		}
+		d[key] = v[i+1]
+	}
+	return d
+}
+
+func keys(dicts ...map[string]interface{}) []string {
+	var k []string
+	for _, d := range dicts {
+		for key := range d {
+			k = append(k, key)
+		}
+	}
+	return k
+}
+
+func values(d map[string]interface{}) []interface{} {
+	var v []interface{}
+	for _, value := range d {
+		v = append(v, value)
+	}
+	return v
+}
+
+func pluck(key string, d ...map[string]interface{}) []interface{} {
+	var res []interface{}
+	for _, dict := range d {
+		if v, ok := dict[key]; ok {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+func pick(d map[string]interface{}, keys ...string) map[string]interface{} {
+	res := map[string]interface{}{}
+	for _, k := range keys {
+		if v, ok := d[k]; ok {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+func omit(d map[string]interface{}, keys ...string) map[string]interface{} {
+	res := map[string]interface{}{}
+	omitted := map[string]bool{}
+	for _, k := range keys {
+		omitted[k] = true
+	}
+	for k, v := range d {
+		if !omitted[k] {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// merge merges src dictionaries into dst, existing keys of dst win
+func merge(dst map[string]interface{}, srcs ...map[string]interface{}) map[string]interface{} {
+	for _, src := range srcs {
+		for k, v := range src {
+			dstValue, exists := dst[k]
+			if !exists {
+				dst[k] = v
+				continue
+			}
+			dstMap, ok1 := dstValue.(map[string]interface{})
+			srcMap, ok2 := v.(map[string]interface{})
+			if ok1 && ok2 {
+				merge(dstMap, srcMap)
+			}
+		}
+	}
+	return dst
+}
+
+// mergeOverwrite merges src dictionaries into dst, keys of src win
+func mergeOverwrite(dst map[string]interface{}, srcs ...map[string]interface{}) map[string]interface{} {
+	for _, src := range srcs {
+		for k, v := range src {
+			dstMap, ok1 := dst[k].(map[string]interface{})
+			srcMap, ok2 := v.(map[string]interface{})
+			if ok1 && ok2 {
+				mergeOverwrite(dstMap, srcMap)
+			} else {
+				dst[k] = v
+			}
+		}
+	}
+	return dst
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			c[k] = deepCopy(val)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, val := range v {
+			c[i] = deepCopy(val)
+		}
+		return c
+	}
+	return v
+}
+
+// dig returns value at path of keys, the last argument before the dictionary is the default value
+func dig(ps ...interface{}) (interface{}, error) {
+	if len(ps) < 3 {
+		return nil, errors.New("dig needs at least three arguments")
+	}
+	d, ok := ps[len(ps)-1].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("last argument of dig must be a dictionary")
+	}
+	def := ps[len(ps)-2]
+	var current interface{} = d
+	for _, key := range ps[:len(ps)-2] {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return def, nil
+		}
+		if current, ok = m[toString(key)]; !ok {
+			return def, nil
+		}
+	}
+	return current, nil
+}
+
+func toTime(date interface{}) time.Time {
+	switch date := date.(type) {
+	case time.Time:
+		return date
+	case *time.Time:
+		return *date
+	case int64:
+		return time.Unix(date, 0)
+	case int:
+		return time.Unix(int64(date), 0)
+	}
+	return time.Now()
+}
@@ -0,0 +1,361 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package helm
+
+import (
+	"encoding/base64"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// maximum depth of nested include calls, guards against recursive templates
+const maxIncludeDepth = 1000
+
+// ReleaseOptions are used to fill .Release and .Capabilities objects
+type ReleaseOptions struct {
+	Name        string
+	Namespace   string
+	Revision    int
+	IsUpgrade   bool
+	KubeVersion string   // i.e. v1.30.0, defaults to DefaultKubeVersion
+	APIVersions []string // additional api versions reported by .Capabilities.APIVersions.Has
+}
+
+// DefaultKubeVersion is reported by .Capabilities.KubeVersion when ReleaseOptions.KubeVersion is not set
+const DefaultKubeVersion = "v1.30.0"
+
+// KubeVersion is .Capabilities.KubeVersion
+type KubeVersion struct {
+	Version    string
+	Major      string
+	Minor      string
+	GitVersion string
+}
+
+func (kv KubeVersion) String() string {
+	return kv.Version
+}
+
+// VersionSet is .Capabilities.APIVersions
+type VersionSet []string
+
+// Has returns true if api version (i.e. apps/v1) or api version with kind (i.e. apps/v1/Deployment) is available
+func (vs VersionSet) Has(apiVersion string) bool {
+	for _, v := range vs {
+		if v == apiVersion {
+			return true
+		}
+	}
+	return false
+}
+
+// Capabilities is .Capabilities object of templates
+type Capabilities struct {
+	KubeVersion KubeVersion
+	APIVersions VersionSet
+	HelmVersion map[string]string
+}
+
+var defaultAPIVersions = []string{"v1", "apps/v1", "batch/v1", "autoscaling/v2", "networking.k8s.io/v1", "policy/v1",
+	"rbac.authorization.k8s.io/v1", "storage.k8s.io/v1", "apiextensions.k8s.io/v1", "admissionregistration.k8s.io/v1",
+	"coordination.k8s.io/v1", "scheduling.k8s.io/v1", "discovery.k8s.io/v1"}
+
+// Files is .Files object of templates, keys are paths relative to chart root
+type Files map[string][]byte
+
+// Get returns file content as string, or empty string if file does not exist
+func (f Files) Get(name string) string {
+	return string(f[name])
+}
+
+// GetBytes returns file content
+func (f Files) GetBytes(name string) []byte {
+	return f[name]
+}
+
+// Glob returns files matching pattern
+func (f Files) Glob(pattern string) Files {
+	matched := Files{}
+	for name, content := range f {
+		if ok, _ := path.Match(pattern, name); ok {
+			matched[name] = content
+		}
+	}
+	return matched
+}
+
+// Lines returns lines of file
+func (f Files) Lines(name string) []string {
+	if len(f[name]) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(f[name]), "\n"), "\n")
+}
+
+// AsConfig returns files as yaml map suitable for ConfigMap data, keys are base names of files
+func (f Files) AsConfig() string {
+	m := map[string]string{}
+	for name, content := range f {
+		m[path.Base(name)] = string(content)
+	}
+	return toYaml(m)
+}
+
+// AsSecrets returns files as yaml map of base64 encoded values suitable for Secret data
+func (f Files) AsSecrets() string {
+	m := map[string]string{}
+	for name, content := range f {
+		m[path.Base(name)] = base64.StdEncoding.EncodeToString(content)
+	}
+	return toYaml(m)
+}
+
+// renderedChart is a chart, or subchart, prepared for rendering
+type renderedChart struct {
+	chart  *Chart
+	prefix string // template name prefix, i.e. parent/charts/sub
+	values map[string]interface{}
+}
+
+// Render renders templates of chart and enabled subcharts with values, which are coalesced with chart defaults.
+// Result is keyed by template name, i.e. mychart/templates/deployment.yaml, partials, NOTES.txt and empty
+// results are omitted.
+func Render(chart *Chart, values map[string]interface{}, options ReleaseOptions) (map[string]string, error) {
+	if len(options.Name) < 1 {
+		options.Name = chart.Metadata.Name
+	}
+	if len(options.Namespace) < 1 {
+		options.Namespace = "default"
+	}
+	if options.Revision < 1 {
+		options.Revision = 1
+	}
+	kubeVersion := options.KubeVersion
+	if len(kubeVersion) < 1 {
+		kubeVersion = DefaultKubeVersion
+	}
+	major, minor := "", ""
+	if parts := strings.SplitN(strings.TrimPrefix(kubeVersion, "v"), ".", 3); len(parts) > 1 {
+		major, minor = parts[0], parts[1]
+	}
+	capabilities := Capabilities{
+		KubeVersion: KubeVersion{Version: kubeVersion, Major: major, Minor: minor, GitVersion: kubeVersion},
+		APIVersions: append(VersionSet(defaultAPIVersions), options.APIVersions...),
+		HelmVersion: map[string]string{"version": "v3"},
+	}
+
+	var charts []renderedChart
+	collectCharts(chart, chart.Metadata.Name, MergeMaps(chart.Values, values), &charts)
+
+	t := template.New("gotpl").Option("missingkey=zero")
+	includeDepth := 0
+	funcs := funcMap()
+	funcs["include"] = func(name string, data interface{}) (string, error) {
+		if includeDepth > maxIncludeDepth {
+			return "", errors.Errorf("rendering template has a nested reference name: %s", name)
+		}
+		includeDepth++
+		defer func() { includeDepth-- }()
+		var buf strings.Builder
+		if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	funcs["tpl"] = func(text string, data interface{}) (string, error) {
+		clone, err := t.Clone()
+		if err != nil {
+			return "", err
+		}
+		tt, err := clone.New("tpl").Parse(text)
+		if err != nil {
+			return "", errors.Wrap(err, "cannot parse template in tpl")
+		}
+		var buf strings.Builder
+		if err := tt.Execute(&buf, data); err != nil {
+			return "", errors.Wrap(err, "error during tpl function execution")
+		}
+		return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+	}
+	t.Funcs(funcs)
+
+	type templateData struct {
+		name string
+		rc   renderedChart
+	}
+	var toRender []templateData
+	for _, rc := range charts {
+		var names []string
+		for name := range rc.chart.Templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fullName := rc.prefix + "/" + name
+			if _, err := t.New(fullName).Parse(rc.chart.Templates[name]); err != nil {
+				return nil, errors.Wrapf(err, "parse error in %s", fullName)
+			}
+			base := path.Base(name)
+			if strings.HasPrefix(base, "_") || base == "NOTES.txt" {
+				continue
+			}
+			toRender = append(toRender, templateData{name: fullName, rc: rc})
+		}
+	}
+
+	rendered := map[string]string{}
+	for _, td := range toRender {
+		data := map[string]interface{}{
+			"Values": td.rc.values,
+			"Release": map[string]interface{}{
+				"Name":      options.Name,
+				"Namespace": options.Namespace,
+				"Service":   "Helm",
+				"Revision":  options.Revision,
+				"IsInstall": !options.IsUpgrade,
+				"IsUpgrade": options.IsUpgrade,
+			},
+			"Chart": map[string]interface{}{
+				"Name":        td.rc.chart.Metadata.Name,
+				"Version":     td.rc.chart.Metadata.Version,
+				"AppVersion":  td.rc.chart.Metadata.AppVersion,
+				"Description": td.rc.chart.Metadata.Description,
+				"Type":        td.rc.chart.Metadata.Type,
+				"APIVersion":  td.rc.chart.Metadata.ApiVersion,
+				"KubeVersion": td.rc.chart.Metadata.KubeVersion,
+			},
+			"Capabilities": capabilities,
+			"Template": map[string]interface{}{
+				"Name":     td.name,
+				"BasePath": td.rc.prefix + "/templates",
+			},
+			"Files": Files(td.rc.chart.Files),
+		}
+		var buf strings.Builder
+		if err := t.ExecuteTemplate(&buf, td.name, data); err != nil {
+			return nil, errors.Wrapf(err, "render error in %s", td.name)
+		}
+		out := strings.ReplaceAll(buf.String(), "<no value>", "")
+		if len(strings.TrimSpace(out)) > 0 {
+			rendered[td.name] = out
+		}
+	}
+	return rendered, nil
+}
+
+// collectCharts coalesces values of subcharts and collects enabled charts for rendering
+func collectCharts(chart *Chart, prefix string, values map[string]interface{}, charts *[]renderedChart) {
+	*charts = append(*charts, renderedChart{chart: chart, prefix: prefix, values: values})
+	global, _ := values["global"].(map[string]interface{})
+	for _, sub := range chart.Charts {
+		if !isDependencyEnabled(chart, sub, values) {
+			continue
+		}
+		parentSubValues, _ := values[sub.Name()].(map[string]interface{})
+		subValues := MergeMaps(sub.Values, parentSubValues)
+		subGlobal, _ := sub.Values["global"].(map[string]interface{})
+		subValues["global"] = MergeMaps(subGlobal, global)
+		// parent sees coalesced values of the subchart, as in helm
+		values[sub.Name()] = subValues
+		collectCharts(sub, prefix+"/charts/"+sub.Name(), subValues, charts)
+	}
+}
+
+// CoalesceValues returns values merged over chart defaults, with defaults of enabled subcharts coalesced under
+// their names, globals are not copied into subcharts. Result contains every value templates may read.
+func CoalesceValues(chart *Chart, values map[string]interface{}) map[string]interface{} {
+	coalesced := MergeMaps(chart.Values, values)
+	for _, sub := range chart.Charts {
+		if !isDependencyEnabled(chart, sub, coalesced) {
+			continue
+		}
+		parentSubValues, _ := coalesced[sub.Name()].(map[string]interface{})
+		coalesced[sub.Name()] = CoalesceValues(sub, parentSubValues)
+	}
+	return coalesced
+}
+
+// isDependencyEnabled evaluates condition of dependency, i.e. redis.enabled, against parent values,
+// subcharts without condition or with condition pointing to missing value are enabled
+func isDependencyEnabled(parent *Chart, sub *Chart, values map[string]interface{}) bool {
+	for _, dep := range parent.Metadata.Dependencies {
+		if dep.Name != sub.Metadata.Name || (len(dep.Alias) > 0 && dep.Alias != sub.Name()) {
+			continue
+		}
+		// condition may list several comma separated paths, first existing one wins
+		for _, condition := range strings.Split(dep.Condition, ",") {
+			condition = strings.TrimSpace(condition)
+			if len(condition) < 1 {
+				continue
+			}
+			if value, ok := pathValue(values, condition); ok {
+				if enabled, ok := value.(bool); ok {
+					return enabled
+				}
+				if enabled, err := strconv.ParseBool(toString(value)); err == nil {
+					return enabled
+				}
+			}
+		}
+	}
+	return true
+}
+
+// pathValue returns value at dot separated path
+func pathValue(values map[string]interface{}, valuePath string) (interface{}, bool) {
+	var current interface{} = values
+	for _, part := range strings.Split(valuePath, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Manifests joins rendered templates into a single multi document yaml, sorted by template name
+func Manifests(rendered map[string]string) string {
+	var names []string
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out strings.Builder
+	for _, name := range names {
+		out.WriteString("---\n# Source: " + name + "\n")
+		out.WriteString(strings.TrimSpace(rendered[name]) + "\n")
+	}
+	return out.String()
+}
+
+func toYaml(v interface{}) string {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(string(data), "\n")
+}
@@ -0,0 +1,215 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package helm
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// semver is a parsed semantic version as used by semverCompare
+type semver struct {
+	Major      int64
+	Minor      int64
+	Patch      int64
+	Prerelease string
+	Metadata   string
+	Original   string
+}
+
+func (v semver) String() string {
+	return v.Original
+}
+
+func parseSemver(s string) (semver, error) {
+	v := semver{Original: s}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(s, "+"); i >= 0 {
+		v.Metadata = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.Prerelease = s[i+1:]
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 || len(parts[0]) == 0 {
+		return v, errors.Errorf("invalid semantic version: %s", v.Original)
+	}
+	nums := []*int64{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			break
+		}
+		n, err := strconv.ParseInt(p, 10, 64)
+		if err != nil {
+			return v, errors.Errorf("invalid semantic version: %s", v.Original)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+func parseSemverFunc(s string) (*semver, error) {
+	v, err := parseSemver(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// compare returns -1, 0 or 1, build metadata is ignored
+func (v semver) compare(o semver) int {
+	for _, d := range []int64{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		} else if d > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func comparePrerelease(a string, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+	ap := strings.Split(a, ".")
+	bp := strings.Split(b, ".")
+	for i := 0; i < len(ap) && i < len(bp); i++ {
+		an, aErr := strconv.ParseInt(ap[i], 10, 64)
+		bn, bErr := strconv.ParseInt(bp[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(ap[i], bp[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(ap) < len(bp):
+		return -1
+	case len(ap) > len(bp):
+		return 1
+	}
+	return 0
+}
+
+/*
+semverCompare checks version against constraint, supported operators are =, !=, >, <, >=, <=, ~ and ^,
+constraints separated by comma or space must all match and || separates alternatives.
+As in helm, prerelease versions only match constraints which carry a prerelease themselves,
+so charts commonly use ">=1.19.0-0" to include them.
+*/
+func semverCompare(constraint string, version string) (bool, error) {
+	v, err := parseSemver(version)
+	if err != nil {
+		return false, err
+	}
+	for _, alternative := range strings.Split(constraint, "||") {
+		matched := true
+		conditions := strings.FieldsFunc(normalizeConstraint(alternative), func(r rune) bool { return r == ',' || r == ' ' })
+		if len(conditions) == 0 {
+			continue
+		}
+		for _, c := range conditions {
+			ok, err := checkCondition(c, v)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// normalizeConstraint glues operators to their versions so that ">= 1.2" is a single condition
+func normalizeConstraint(c string) string {
+	c = strings.TrimSpace(c)
+	for _, op := range []string{">=", "<=", "!=", "=", ">", "<", "~", "^"} {
+		c = strings.ReplaceAll(c, op+" ", op)
+	}
+	return c
+}
+
+func checkCondition(condition string, v semver) (bool, error) {
+	op := ""
+	for _, candidate := range []string{">=", "<=", "!=", "=>", "=<", "==", "=", ">", "<", "~>", "~", "^"} {
+		if strings.HasPrefix(condition, candidate) {
+			op = candidate
+			break
+		}
+	}
+	c, err := parseSemver(strings.TrimPrefix(condition, op))
+	if err != nil {
+		return false, err
+	}
+	if v.Prerelease != "" && c.Prerelease == "" {
+		return false, nil
+	}
+	cmp := v.compare(c)
+	switch op {
+	case "", "=", "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case ">":
+		return cmp > 0, nil
+	case "<":
+		return cmp < 0, nil
+	case ">=", "=>":
+		return cmp >= 0, nil
+	case "<=", "=<":
+		return cmp <= 0, nil
+	case "~", "~>":
+		upper := semver{Major: c.Major, Minor: c.Minor + 1}
+		return cmp >= 0 && v.compare(upper) < 0, nil
+	case "^":
+		upper := semver{Major: c.Major + 1}
+		if c.Major == 0 {
+			upper = semver{Minor: c.Minor + 1}
+		}
+		return cmp >= 0 && v.compare(upper) < 0, nil
+	}
+	return false, errors.Errorf("unsupported constraint: %s", condition)
+}
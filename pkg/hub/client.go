@@ -0,0 +1,234 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+/*
+Package hub provides a client for Reliza Hub with configurable timeouts, retries
+on transient failures and context cancellation. It is shared by all commands
+which talk to Reliza Hub, so that authentication, CSRF session handling and
+error handling are done the same way everywhere.
+*/
+package hub
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/machinebox/graphql"
+)
+
+const (
+	DefaultUri     = "https://app.relizahub.com"
+	DefaultTimeout = 60 * time.Second
+	DefaultRetries = 3
+	UserAgent      = "Reliza Go Client"
+)
+
+// Client is a Reliza Hub client. It is safe for concurrent use.
+type Client struct {
+	Uri      string
+	ApiKeyId string
+	ApiKey   string
+
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	httpClient *http.Client
+}
+
+// Option configures optional Client settings.
+type Option func(*Client)
+
+// WithTimeout sets timeout for every single attempt of a request, 0 disables timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a request is retried on connection errors and 5xx responses,
+// mutations are only retried when they could not be sent.
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		if retries < 0 {
+			retries = 0
+		}
+		c.retries = retries
+	}
+}
+
+// WithBackoff sets initial delay between retries, which is doubled after every attempt.
+func WithBackoff(backoff time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = backoff
+	}
+}
+
+// WithTransport sets underlying http transport, mostly useful for tests.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = rt
+	}
+}
+
+func NewClient(uri string, apiKeyId string, apiKey string, opts ...Option) *Client {
+	if len(uri) < 1 {
+		uri = DefaultUri
+	}
+	c := &Client{
+		Uri:        uri,
+		ApiKeyId:   apiKeyId,
+		ApiKey:     apiKey,
+		timeout:    DefaultTimeout,
+		retries:    DefaultRetries,
+		backoff:    defaultBackoff,
+		httpClient: &http.Client{Transport: http.DefaultTransport},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.httpClient.Transport = &retryTransport{
+		base:    c.httpClient.Transport,
+		timeout: c.timeout,
+		retries: c.retries,
+		backoff: c.backoff,
+	}
+	return c
+}
+
+// HTTPClient returns http client with retries and timeouts configured for this hub client.
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// Run executes GraphQL request on Reliza Hub GraphQL endpoint and unmarshals response data into resp.
+func (c *Client) Run(ctx context.Context, req *graphql.Request, resp interface{}) error {
+	return c.RunWithUri(ctx, c.Uri+"/graphql", req, resp)
+}
+
+// RunWithUri executes GraphQL request on the arbitrary GraphQL endpoint (i.e. rebom) using hub credentials.
+func (c *Client) RunWithUri(ctx context.Context, uri string, req *graphql.Request, resp interface{}) error {
+	session, err := c.Session(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return fmt.Errorf("error obtaining Reliza Hub session: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	req.Header.Set("X-CSRF-Token", session.Token)
+	req.Header.Set("Cookie", "JSESSIONID="+session.JSessionId)
+	if len(c.ApiKeyId) > 0 && len(c.ApiKey) > 0 {
+		auth := base64.StdEncoding.EncodeToString([]byte(c.ApiKeyId + ":" + c.ApiKey))
+		req.Header.Set("Authorization", "Basic "+auth)
+	}
+
+	client := graphql.NewClient(uri, graphql.WithHTTPClient(c.httpClient))
+	return client.Run(ctx, req, resp)
+}
+
+// Query runs GraphQL request and returns json of the response data under the specified endpoint key.
+func (c *Client) Query(ctx context.Context, req *graphql.Request, endpoint string) (string, error) {
+	var respData map[string]interface{}
+	if err := c.Run(ctx, req, &respData); err != nil {
+		return "", err
+	}
+	jsonResponse, err := json.Marshal(respData[endpoint])
+	if err != nil {
+		return "", err
+	}
+	return string(jsonResponse), nil
+}
+
+// Upload posts file as multipart form to the specified path on Reliza Hub.
+func (c *Client) Upload(ctx context.Context, path string, filePath string, formData map[string]string) (*resty.Response, error) {
+	client := resty.NewWithClient(c.httpClient)
+	session, err := c.Session(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining Reliza Hub session: %w", err)
+	}
+	client.SetHeader("X-CSRF-Token", session.Token)
+	client.SetHeader("Cookie", "JSESSIONID="+session.JSessionId)
+	return client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("User-Agent", UserAgent).
+		SetHeader("Accept-Encoding", "gzip, deflate").
+		SetFile("file", filePath).
+		SetFormData(formData).
+		SetBasicAuth(c.ApiKeyId, c.ApiKey).
+		Post(c.Uri + path)
+}
+
+// Session holds CSRF token and session cookie required by Reliza Hub for mutating requests.
+type Session struct {
+	JSessionId string
+	Token      string
+}
+
+// Session obtains new CSRF session from Reliza Hub.
+func (c *Client) Session(ctx context.Context) (*Session, error) {
+	client := resty.NewWithClient(c.httpClient)
+	resp, err := client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("User-Agent", UserAgent).
+		SetHeader("Accept-Encoding", "gzip, deflate").
+		Get(c.Uri + "/api/manual/v1/fetchCsrf")
+
+	if err != nil {
+		return nil, err
+	}
+	return getJSessionIDCookieAndToken(resp)
+}
+
+func getJSessionIDCookieAndToken(resp *resty.Response) (*Session, error) {
+	// Extract cookies
+	cookies := resp.Cookies()
+	var jsessionid string
+	for _, cookie := range cookies {
+		if cookie.Name == "JSESSIONID" {
+			jsessionid = cookie.Value
+			break
+		}
+	}
+
+	if jsessionid == "" {
+		return nil, fmt.Errorf("JSESSIONID cookie not found")
+	}
+
+	// Assume the token is returned in the response body as a JSON object
+	var result map[string]interface{}
+	err := json.Unmarshal(resp.Body(), &result)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %s", err)
+	}
+
+	token, ok := result["token"].(string)
+	if !ok {
+		return nil, fmt.Errorf("token not found in the response body")
+	}
+
+	return &Session{JSessionId: jsessionid, Token: token}, nil
+}
@@ -0,0 +1,189 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package hub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/machinebox/graphql"
+)
+
+var (
+	ErrInstanceNotSpecified    = errors.New("instance or instanceURI not specified")
+	ErrBundleNotSpecified      = errors.New("bundle name and either version or environment must be provided")
+	ErrEnvironmentNotSpecified = errors.New("environment not specified")
+)
+
+// IsInstanceKey returns true if the client authenticates with instance or cluster own API key,
+// in which case instance does not need to be specified explicitly
+func (c *Client) IsInstanceKey() bool {
+	return strings.HasPrefix(c.ApiKeyId, "INSTANCE__") || strings.HasPrefix(c.ApiKeyId, "CLUSTER__")
+}
+
+// InstanceRevisionCycloneDx exports CycloneDX BOM of the instance at specific revision, -1 or empty revision means latest
+func (c *Client) InstanceRevisionCycloneDx(ctx context.Context, instance string, revision string, instanceURI string, namespace string) ([]byte, error) {
+	if len(instance) <= 0 && len(instanceURI) <= 0 && !c.IsInstanceKey() {
+		return nil, ErrInstanceNotSpecified
+	}
+
+	if len(revision) < 1 {
+		revision = "-1"
+	}
+
+	req := graphql.NewRequest(`
+		query ($instanceUuid: ID, $instanceUri: String, $revision: Int!, $namespace: String) {
+			getInstanceRevisionCycloneDxExportProg(instanceUuid: $instanceUuid, instanceUri: $instanceUri, revision: $revision, namespace: $namespace)
+		}
+	`)
+	req.Var("instanceUuid", instance)
+	req.Var("instanceUri", instanceURI)
+	intRevision, _ := strconv.Atoi(revision)
+	req.Var("revision", intRevision)
+	req.Var("namespace", namespace)
+
+	var respData map[string]string
+	if err := c.Run(ctx, req, &respData); err != nil {
+		return nil, err
+	}
+	return []byte(respData["getInstanceRevisionCycloneDxExportProg"]), nil
+}
+
+// BundleVersionCycloneDx exports CycloneDX BOM of the bundle by version or latest approved for environment
+func (c *Client) BundleVersionCycloneDx(ctx context.Context, bundle string, environment string, version string) ([]byte, error) {
+	if len(bundle) <= 0 && (len(version) <= 0 || len(environment) <= 0) {
+		return nil, ErrBundleNotSpecified
+	}
+
+	req := graphql.NewRequest(`
+		query ($bundleName: String!, $bundleVersion: String, $environment: String) {
+			exportAsBomProg(bundleName: $bundleName, bundleVersion: $bundleVersion, environment: $environment)
+		}
+	`)
+	req.Var("bundleName", bundle)
+	req.Var("bundleVersion", version)
+	req.Var("environment", environment)
+
+	var respData map[string]string
+	if err := c.Run(ctx, req, &respData); err != nil {
+		return nil, err
+	}
+	return []byte(respData["exportAsBomProg"]), nil
+}
+
+// EnvironmentCycloneDx exports CycloneDX BOM of latest approved releases for environment
+func (c *Client) EnvironmentCycloneDx(ctx context.Context, environment string) ([]byte, error) {
+	if len(environment) <= 0 {
+		return nil, ErrEnvironmentNotSpecified
+	}
+
+	req := graphql.NewRequest(`
+		query ($environment: String!) {
+			exportAsBomProgByEnv(environment: $environment)
+		}
+	`)
+	req.Var("environment", environment)
+
+	var respData map[string]string
+	if err := c.Run(ctx, req, &respData); err != nil {
+		return nil, err
+	}
+	return []byte(respData["exportAsBomProgByEnv"]), nil
+}
+
+// InstancePropsSecretsInput selects instance and the properties and secrets to resolve on it
+type InstancePropsSecretsInput struct {
+	Instance            string
+	InstanceURI         string
+	Revision            string
+	Namespace           string
+	Bundle              string
+	BundleSpecificProps bool
+	Properties          []string
+	Secrets             []string
+}
+
+// InstancePropsSecrets resolves properties and sealed secrets of the instance
+func (c *Client) InstancePropsSecrets(ctx context.Context, input InstancePropsSecretsInput) (SecretPropsRHResp, error) {
+	var respData SecretPropsRHResp
+
+	if len(input.Instance) <= 0 && len(input.InstanceURI) <= 0 && !c.IsInstanceKey() {
+		return respData, ErrInstanceNotSpecified
+	}
+
+	revision := input.Revision
+	if len(revision) < 1 {
+		revision = "-1"
+	}
+
+	namespace := input.Namespace
+	if len(namespace) <= 1 {
+		namespace = "default"
+	}
+
+	req := graphql.NewRequest(`
+		query ($instanceUuid: ID, $instanceUri: String, $revision: Int!, $namespace: String!, $properties: [String], $secrets: [String], $bundle: ID, $bundleSpecificProps: Boolean) {
+			getInstancePropSecrets(instanceUuid: $instanceUuid, instanceUri: $instanceUri, revision: $revision, namespace: $namespace, properties: $properties, secrets: $secrets, bundle: $bundle, bundleSpecificProps: $bundleSpecificProps) {
+				properties {
+					key
+					value
+				}
+				secrets {
+					key
+					value
+					lastUpdated
+				}
+			}
+		}
+	`)
+
+	req.Var("instanceUuid", input.Instance)
+	req.Var("instanceUri", input.InstanceURI)
+	intRevision, _ := strconv.Atoi(revision)
+	req.Var("revision", intRevision)
+	req.Var("namespace", namespace)
+	req.Var("properties", input.Properties)
+	req.Var("secrets", input.Secrets)
+	req.Var("bundle", input.Bundle)
+	req.Var("bundleSpecificProps", input.BundleSpecificProps)
+
+	err := c.Run(ctx, req, &respData)
+	return respData, err
+}
+
+type SecretPropsRHResp struct {
+	Responsewrapper SecretPropsRHRespMaps `json:"getInstancePropSecrets"`
+}
+
+type SecretPropsRHRespMaps struct {
+	Secrets    []ResolvedSecret   `json:"secrets"`
+	Properties []ResolvedProperty `json:"properties"`
+}
+
+type ResolvedSecret struct {
+	Secret    string `json:"value"`
+	Timestamp int64  `json:"lastUpdated"`
+	Key       string `json:"key"`
+}
+
+type ResolvedProperty struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
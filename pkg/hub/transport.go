@@ -0,0 +1,160 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package hub
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBackoff = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+// retryTransport retries requests on connection errors and 5xx responses with exponential backoff.
+// Each attempt is bounded by timeout, while the whole sequence is bounded by the request context.
+// GraphQL mutations and other non-idempotent requests are only retried if they failed before the
+// request was sent, since Hub may have already committed them when it responds with 5xx or times out.
+type retryTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	delay := t.backoff
+	// body must be rewound for every retry, otherwise request is only tried once
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	idempotent := isIdempotent(req)
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, sent, err := t.roundTripOnce(attemptReq)
+		if ctx.Err() != nil {
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		if !replayable || !isRetryable(resp, err, idempotent, sent) || attempt >= t.retries {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+		if delay > maxBackoff {
+			delay = maxBackoff
+		}
+	}
+}
+
+// roundTripOnce performs single attempt and reports whether request headers were sent to the server
+func (t *retryTransport) roundTripOnce(req *http.Request) (*http.Response, bool, error) {
+	var sent atomic.Bool
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteHeaders: func() { sent.Store(true) },
+	})
+	if t.timeout <= 0 {
+		resp, err := t.base.RoundTrip(req.WithContext(ctx))
+		return resp, sent.Load(), err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, sent.Load(), err
+	}
+	// attempt context must live until the body is consumed
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, true, nil
+}
+
+func isRetryable(resp *http.Response, err error, idempotent bool, sent bool) bool {
+	if err != nil {
+		return idempotent || !sent
+	}
+	return idempotent && resp.StatusCode >= 500
+}
+
+// isIdempotent reports whether request may be repeated after it reached the server, which holds for
+// GET requests and GraphQL queries, but not for GraphQL mutations or uploads
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	case http.MethodPost:
+	default:
+		return false
+	}
+	if req.GetBody == nil {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	var gqlReq struct {
+		Query string `json:"query"`
+	}
+	if err := json.NewDecoder(body).Decode(&gqlReq); err != nil || len(gqlReq.Query) == 0 {
+		return false
+	}
+	operation := strings.TrimSpace(gqlReq.Query)
+	return strings.HasPrefix(operation, "query") || strings.HasPrefix(operation, "{")
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
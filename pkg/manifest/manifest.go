@@ -0,0 +1,303 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package manifest parses declarative release manifests used as input of addrelease and addartifact.
+// Manifest may be written either in yaml or in json and must conform to the schema returned by Schema.
+package manifest
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/bom"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"sigs.k8s.io/yaml"
+)
+
+//go:embed release-manifest.schema.json
+var schema []byte
+
+// schemaUrl is the $id of the embedded schema
+const schemaUrl = "https://github.com/relizaio/reliza-cli/blob/master/pkg/manifest/release-manifest.schema.json"
+
+// Schema returns published json schema of the release manifest
+func Schema() []byte {
+	return schema
+}
+
+// Manifest describes release with its source code entry, commits and artifacts
+type Manifest struct {
+	ApiVersion      string           `json:"apiVersion,omitempty"`
+	Release         string           `json:"release,omitempty"`
+	Project         string           `json:"project,omitempty"`
+	Branch          string           `json:"branch,omitempty"`
+	Version         string           `json:"version,omitempty"`
+	Status          string           `json:"status,omitempty"`
+	Endpoint        string           `json:"endpoint,omitempty"`
+	SourceCodeEntry *SourceCodeEntry `json:"sourceCodeEntry,omitempty"`
+	Commits         []Commit         `json:"commits,omitempty"`
+	Artifacts       []Artifact       `json:"artifacts,omitempty"`
+	FsBom           string           `json:"fsBom,omitempty"`
+
+	// dir is the directory of the manifest file, bom paths are resolved relative to it
+	dir string
+}
+
+// SourceCodeEntry describes commit from which release is built
+type SourceCodeEntry struct {
+	Uri           string `json:"uri,omitempty"`
+	Type          string `json:"type,omitempty"`
+	Commit        string `json:"commit"`
+	CommitMessage string `json:"commitMessage,omitempty"`
+	VcsTag        string `json:"vcsTag,omitempty"`
+	DateActual    string `json:"dateActual,omitempty"`
+	CommitAuthor  string `json:"commitAuthor,omitempty"`
+	CommitEmail   string `json:"commitEmail,omitempty"`
+}
+
+// Commit is a single commit associated with the release
+type Commit struct {
+	Commit        string `json:"commit"`
+	DateActual    string `json:"dateActual,omitempty"`
+	CommitMessage string `json:"commitMessage,omitempty"`
+	CommitAuthor  string `json:"commitAuthor,omitempty"`
+	CommitEmail   string `json:"commitEmail,omitempty"`
+}
+
+// Artifact of the release
+type Artifact struct {
+	Identifier  string   `json:"identifier"`
+	BuildId     string   `json:"buildId,omitempty"`
+	BuildUri    string   `json:"buildUri,omitempty"`
+	CicdMeta    string   `json:"cicdMeta,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Digests     []string `json:"digests,omitempty"`
+	DateFrom    string   `json:"dateFrom,omitempty"`
+	DateTo      string   `json:"dateTo,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Publisher   string   `json:"publisher,omitempty"`
+	PackageType string   `json:"packageType,omitempty"`
+	Group       string   `json:"group,omitempty"`
+	Tags        []Tag    `json:"tags,omitempty"`
+	Boms        []BomRef `json:"boms,omitempty"`
+}
+
+// Tag is a key-value pair attached to artifact
+type Tag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// BomRef references BOM file of the artifact, path is relative to the manifest file
+type BomRef struct {
+	Type string `json:"type"`
+	Path string `json:"path"`
+}
+
+// ReadFile reads and validates manifest file, format is detected by extension (.json or .yaml / .yml)
+func ReadFile(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading manifest %s", path)
+	}
+	m, err := Parse(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest %s", path)
+	}
+	m.dir = filepath.Dir(path)
+	return m, nil
+}
+
+// Parse parses manifest in yaml or json form and validates it against the schema, unknown fields are rejected
+func Parse(content []byte) (*Manifest, error) {
+	jsonContent, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateJson(jsonContent); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(jsonContent))
+	dec.DisallowUnknownFields()
+	var m Manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks manifest against the schema returned by Schema
+func (m *Manifest) Validate() error {
+	jsonContent, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return validateJson(jsonContent)
+}
+
+var (
+	compileSchemaOnce sync.Once
+	compiledSchema    *jsonschema.Schema
+	compileSchemaErr  error
+)
+
+// compileSchema compiles embedded schema once, it is never fetched from the network
+func compileSchema() (*jsonschema.Schema, error) {
+	compileSchemaOnce.Do(func() {
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+		if err != nil {
+			compileSchemaErr = errors.Wrap(err, "error parsing release manifest schema")
+			return
+		}
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource(schemaUrl, doc); err != nil {
+			compileSchemaErr = errors.Wrap(err, "error loading release manifest schema")
+			return
+		}
+		compiledSchema, compileSchemaErr = compiler.Compile(schemaUrl)
+		if compileSchemaErr != nil {
+			compileSchemaErr = errors.Wrap(compileSchemaErr, "error compiling release manifest schema")
+		}
+	})
+	return compiledSchema, compileSchemaErr
+}
+
+func validateJson(jsonContent []byte) error {
+	sch, err := compileSchema()
+	if err != nil {
+		return err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(jsonContent))
+	if err != nil {
+		return err
+	}
+	return sch.Validate(doc)
+}
+
+// ReleaseBody returns release fields of the manifest in the form of Reliza Hub programmatic release input.
+// BOM files referenced by the manifest are read and embedded.
+func (m *Manifest) ReleaseBody() (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	putIfSet(body, "release", m.Release)
+	putIfSet(body, "project", m.Project)
+	putIfSet(body, "branch", m.Branch)
+	putIfSet(body, "version", m.Version)
+	putIfSet(body, "status", strings.ToUpper(m.Status))
+	putIfSet(body, "endpoint", m.Endpoint)
+
+	if m.SourceCodeEntry != nil {
+		body["sourceCodeEntry"] = *m.SourceCodeEntry
+	}
+	if len(m.Commits) > 0 {
+		body["commits"] = m.Commits
+		// same as with --commits flag, if commit is not present use first commit as source code entry
+		if m.SourceCodeEntry == nil {
+			first := m.Commits[0]
+			body["sourceCodeEntry"] = SourceCodeEntry{Commit: first.Commit, DateActual: first.DateActual,
+				CommitMessage: first.CommitMessage, CommitAuthor: first.CommitAuthor, CommitEmail: first.CommitEmail}
+		}
+	}
+
+	if len(m.Artifacts) > 0 {
+		artifacts, err := m.ArtifactsBody()
+		if err != nil {
+			return nil, err
+		}
+		body["artifacts"] = artifacts
+	}
+
+	if m.FsBom != "" {
+		rawBom, err := bom.ReadJsonFromFile(m.resolvePath(m.FsBom))
+		if err != nil {
+			return nil, err
+		}
+		body["fsBom"] = map[string]interface{}{"rawBom": rawBom, "bomType": "APPLICATION"}
+	}
+	return body, nil
+}
+
+// AddArtifactBody returns release identification and artifacts of the manifest in the form of Reliza Hub add artifact input
+func (m *Manifest) AddArtifactBody() (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	putIfSet(body, "release", m.Release)
+	putIfSet(body, "project", m.Project)
+	putIfSet(body, "version", m.Version)
+	if len(m.Artifacts) > 0 {
+		artifacts, err := m.ArtifactsBody()
+		if err != nil {
+			return nil, err
+		}
+		body["artifacts"] = artifacts
+	}
+	return body, nil
+}
+
+// ArtifactsBody returns artifacts of the manifest in the form of Reliza Hub artifact input
+func (m *Manifest) ArtifactsBody() ([]map[string]interface{}, error) {
+	artifacts := make([]map[string]interface{}, 0, len(m.Artifacts))
+	for _, a := range m.Artifacts {
+		art := map[string]interface{}{"identifier": a.Identifier}
+		putIfSet(art, "buildId", a.BuildId)
+		putIfSet(art, "buildUri", a.BuildUri)
+		putIfSet(art, "cicdMeta", a.CicdMeta)
+		putIfSet(art, "type", a.Type)
+		putIfSet(art, "dateFrom", a.DateFrom)
+		putIfSet(art, "dateTo", a.DateTo)
+		putIfSet(art, "artifactVersion", a.Version)
+		putIfSet(art, "publisher", a.Publisher)
+		putIfSet(art, "packageType", strings.ToUpper(a.PackageType))
+		putIfSet(art, "group", a.Group)
+		if len(a.Digests) > 0 {
+			art["digests"] = a.Digests
+		}
+		if len(a.Tags) > 0 {
+			art["tags"] = a.Tags
+		}
+		if len(a.Boms) > 0 {
+			var boms []map[string]interface{}
+			for _, b := range a.Boms {
+				rawBom, err := bom.ReadJsonFromFile(m.resolvePath(b.Path))
+				if err != nil {
+					return nil, err
+				}
+				boms = append(boms, map[string]interface{}{"rawBom": rawBom, "bomType": strings.ToUpper(b.Type)})
+			}
+			art["bomInputs"] = boms
+		}
+		artifacts = append(artifacts, art)
+	}
+	return artifacts, nil
+}
+
+func (m *Manifest) resolvePath(path string) string {
+	if filepath.IsAbs(path) || m.dir == "" {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
+
+func putIfSet(body map[string]interface{}, key string, value string) {
+	if value != "" {
+		body[key] = value
+	}
+}
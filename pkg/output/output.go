@@ -0,0 +1,213 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package output renders json responses of Reliza Hub as json, yaml or table
+// and allows to select specific fields from them via --query expressions.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// Supported output formats
+const (
+	FormatJSON       = "json"
+	FormatJSONPretty = "json-pretty"
+	FormatYAML       = "yaml"
+	FormatTable      = "table"
+)
+
+// Formats lists all supported output formats
+var Formats = []string{FormatJSON, FormatJSONPretty, FormatYAML, FormatTable}
+
+// Printer renders json responses according to selected format and query
+type Printer struct {
+	// Format is one of Formats, empty string means compact json
+	Format string
+	// Query is either a path selector such as .artifactDetails[0].digests[0]
+	// or a Go template such as {{ .version }}
+	Query string
+}
+
+// Validate checks that format of the printer is supported
+func (p Printer) Validate() error {
+	if p.Format == "" {
+		return nil
+	}
+	for _, f := range Formats {
+		if p.Format == f {
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported output format %s, must be one of %s", p.Format, strings.Join(Formats, ", "))
+}
+
+// Render applies query to json input and renders result in the format of the printer.
+// Returned string does not end with new line.
+func (p Printer) Render(jsonInput []byte) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	var data interface{}
+	if len(bytes.TrimSpace(jsonInput)) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(jsonInput))
+		dec.UseNumber()
+		if err := dec.Decode(&data); err != nil {
+			return "", errors.Wrap(err, "error parsing json response")
+		}
+	}
+
+	query := strings.TrimSpace(p.Query)
+	if strings.Contains(query, "{{") {
+		return executeTemplate(query, data)
+	}
+	if query != "" {
+		selected, err := Select(data, query)
+		if err != nil {
+			return "", err
+		}
+		// scalars are printed raw, so that they can be used in shell directly
+		if scalar, ok := scalarString(selected); ok {
+			return scalar, nil
+		}
+		data = selected
+	}
+
+	switch p.Format {
+	case FormatJSONPretty:
+		out, err := json.MarshalIndent(data, "", "  ")
+		return string(out), err
+	case FormatYAML:
+		out, err := yaml.Marshal(data)
+		return strings.TrimSuffix(string(out), "\n"), err
+	case FormatTable:
+		return renderTable(data), nil
+	default:
+		out, err := json.Marshal(data)
+		return string(out), err
+	}
+}
+
+func executeTemplate(query string, data interface{}) (string, error) {
+	funcs := template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+	}
+	tmpl, err := template.New("query").Funcs(funcs).Option("missingkey=zero").Parse(query)
+	if err != nil {
+		return "", errors.Wrap(err, "error parsing query template")
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrap(err, "error executing query template")
+	}
+	return buf.String(), nil
+}
+
+// scalarString returns string representation of scalar json value
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "null", true
+	case string:
+		return val, true
+	case json.Number:
+		return val.String(), true
+	case bool:
+		return fmt.Sprint(val), true
+	}
+	return "", false
+}
+
+// cellString renders value for a table cell, nested objects are rendered as compact json
+func cellString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	if scalar, ok := scalarString(v); ok {
+		return scalar
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+func renderTable(data interface{}) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	switch val := data.(type) {
+	case map[string]interface{}:
+		fmt.Fprintln(w, "KEY\tVALUE")
+		for _, k := range sortedKeys(val) {
+			fmt.Fprintf(w, "%s\t%s\n", k, cellString(val[k]))
+		}
+	case []interface{}:
+		columns := tableColumns(val)
+		if len(columns) == 0 {
+			// list of scalars
+			for _, entry := range val {
+				fmt.Fprintln(w, cellString(entry))
+			}
+			break
+		}
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, entry := range val {
+			row, _ := entry.(map[string]interface{})
+			cells := make([]string, len(columns))
+			for i, c := range columns {
+				cells[i] = cellString(row[c])
+			}
+			fmt.Fprintln(w, strings.Join(cells, "\t"))
+		}
+	default:
+		fmt.Fprintln(w, cellString(val))
+	}
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// tableColumns returns sorted union of keys of all objects in the list
+func tableColumns(list []interface{}) []string {
+	keySet := map[string]interface{}{}
+	for _, entry := range list {
+		if obj, ok := entry.(map[string]interface{}); ok {
+			for k := range obj {
+				keySet[k] = nil
+			}
+		}
+	}
+	return sortedKeys(keySet)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
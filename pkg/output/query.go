@@ -0,0 +1,157 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package output
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Select resolves path query such as .artifactDetails[0].digests[0] against decoded json.
+// Supported steps are .field, ["field"], [index] (negative index counts from the end)
+// and [*] which applies remaining steps to every element of the list.
+func Select(data interface{}, query string) (interface{}, error) {
+	steps, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return selectSteps(data, steps)
+}
+
+type queryStep struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func parseQuery(query string) ([]queryStep, error) {
+	var steps []queryStep
+	q := strings.TrimSpace(query)
+	for i := 0; i < len(q); {
+		switch q[i] {
+		case '.':
+			i++
+			start := i
+			for i < len(q) && q[i] != '.' && q[i] != '[' {
+				i++
+			}
+			if i > start {
+				steps = append(steps, queryStep{field: q[start:i]})
+			}
+		case '[':
+			end := strings.IndexByte(q[i:], ']')
+			if end < 0 {
+				return nil, errors.Errorf("invalid query %s: missing closing bracket", query)
+			}
+			inner := strings.TrimSpace(q[i+1 : i+end])
+			i += end + 1
+			switch {
+			case inner == "*" || inner == "":
+				steps = append(steps, queryStep{wildcard: true})
+			case strings.HasPrefix(inner, "\"") || strings.HasPrefix(inner, "'"):
+				if len(inner) < 2 || inner[len(inner)-1] != inner[0] {
+					return nil, errors.Errorf("invalid query %s: unterminated quoted field", query)
+				}
+				steps = append(steps, queryStep{field: inner[1 : len(inner)-1]})
+			default:
+				idx, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, errors.Errorf("invalid query %s: index %s is not a number", query, inner)
+				}
+				steps = append(steps, queryStep{index: idx, isIndex: true})
+			}
+		default:
+			// allow leading field without a dot, i.e. version instead of .version
+			if len(steps) > 0 {
+				return nil, errors.Errorf("invalid query %s: unexpected character %q", query, q[i])
+			}
+			start := i
+			for i < len(q) && q[i] != '.' && q[i] != '[' {
+				i++
+			}
+			steps = append(steps, queryStep{field: q[start:i]})
+		}
+	}
+	return steps, nil
+}
+
+func selectSteps(data interface{}, steps []queryStep) (interface{}, error) {
+	for n, step := range steps {
+		switch {
+		case step.wildcard:
+			list, ok := data.([]interface{})
+			if !ok {
+				return nil, errors.Errorf("cannot iterate over %s", describe(data))
+			}
+			results := make([]interface{}, 0, len(list))
+			for _, entry := range list {
+				res, err := selectSteps(entry, steps[n+1:])
+				if err != nil {
+					return nil, err
+				}
+				results = append(results, res)
+			}
+			return results, nil
+		case step.isIndex:
+			list, ok := data.([]interface{})
+			if !ok {
+				return nil, errors.Errorf("cannot index %s with [%d]", describe(data), step.index)
+			}
+			idx := step.index
+			if idx < 0 {
+				idx += len(list)
+			}
+			if idx < 0 || idx >= len(list) {
+				return nil, errors.Errorf("index [%d] is out of range, list has %d elements", step.index, len(list))
+			}
+			data = list[idx]
+		default:
+			if data == nil {
+				return nil, nil
+			}
+			obj, ok := data.(map[string]interface{})
+			if !ok {
+				return nil, errors.Errorf("cannot select field %s from %s", step.field, describe(data))
+			}
+			data = obj[step.field]
+		}
+	}
+	return data, nil
+}
+
+func describe(data interface{}) string {
+	switch data.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "list"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "value"
+}
@@ -0,0 +1,266 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package reference parses container image references as defined by the OCI distribution spec
+// and implemented by docker:
+//
+//	reference       := name [ ":" tag ] [ "@" digest ]
+//	name            := [domain '/'] remote-name
+//	domain          := host [':' port-number]
+//	host            := domain-name | IPv4address | \[ IPv6address \]
+//	domain-name     := domain-component ['.' domain-component]*
+//	domain-component := /([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])/
+//	port-number     := /[0-9]+/
+//	remote-name     := path-component ['/' path-component]*
+//	path-component  := alpha-numeric [separator alpha-numeric]*
+//	alpha-numeric   := /[a-z0-9]+/
+//	separator       := /[_.]|__|[-]+/
+//	tag             := /[\w][\w.-]{0,127}/
+//	digest          := algorithm ":" encoded
+//	algorithm       := /[a-z0-9]+(?:[.+_-][a-z0-9]+)*/
+//	encoded         := /[a-zA-Z0-9=_-]+/
+//
+// First component of the name is a domain only if it contains "." or ":", is "localhost" or
+// contains uppercase letters, otherwise the reference points to docker hub.
+package reference
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultDomain is used for references without domain
+	DefaultDomain = "docker.io"
+	// OfficialRepoPrefix is added to single component docker hub references, i.e. redis -> library/redis
+	OfficialRepoPrefix  = "library/"
+	legacyDefaultDomain = "index.docker.io"
+
+	// NameTotalLengthMax is maximum length of domain and path of a reference
+	NameTotalLengthMax = 255
+)
+
+var (
+	ErrReferenceInvalidFormat = errors.New("invalid reference format")
+	ErrNameEmpty              = errors.New("repository name must have at least one component")
+	ErrNameContainsUppercase  = errors.New("repository name must be lowercase")
+	ErrNameTooLong            = errors.New("repository name must not be more than 255 characters")
+	ErrDomainInvalidFormat    = errors.New("invalid domain format")
+	ErrTagInvalidFormat       = errors.New("invalid tag format")
+	ErrDigestInvalidFormat    = errors.New("invalid digest format")
+	ErrDigestUnsupported      = errors.New("unsupported digest algorithm")
+	ErrDigestInvalidLength    = errors.New("invalid digest length")
+)
+
+var (
+	domainComponentRegex = regexp.MustCompile(`^(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])$`)
+	ipv6Regex            = regexp.MustCompile(`^\[[a-fA-F0-9:]+\]$`)
+	portRegex            = regexp.MustCompile(`^[0-9]+$`)
+	pathComponentRegex   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*$`)
+	tagRegex             = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	algorithmRegex       = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*$`)
+	encodedRegex         = regexp.MustCompile(`^[a-zA-Z0-9=_-]+$`)
+	lowerHexRegex        = regexp.MustCompile(`^[a-f0-9]+$`)
+)
+
+// registered digest algorithms and lengths of their hex encoded values
+var digestAlgorithms = map[string]int{
+	"sha256": 64,
+	"sha384": 96,
+	"sha512": 128,
+}
+
+// Reference is a parsed image reference, Domain is empty if reference does not have one and was not normalized
+type Reference struct {
+	Domain string
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// Parse parses reference as is, without adding default domain
+func Parse(s string) (Reference, error) {
+	var ref Reference
+	if len(s) == 0 {
+		return ref, errors.Wrap(ErrNameEmpty, "empty reference")
+	}
+
+	name := s
+	if i := strings.Index(name, "@"); i > -1 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if err := ValidateDigest(ref.Digest); err != nil {
+			return Reference{}, errors.Wrapf(err, "reference %q", s)
+		}
+	}
+
+	// tag separator is the last colon after the last slash, earlier colons belong to domain port
+	if i := strings.LastIndex(name, ":"); i > -1 && i > strings.LastIndex(name, "/") && !strings.HasSuffix(name, "]") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagRegex.MatchString(ref.Tag) {
+			return Reference{}, errors.Wrapf(ErrTagInvalidFormat, "reference %q", s)
+		}
+	}
+
+	if len(name) > NameTotalLengthMax {
+		return Reference{}, errors.Wrapf(ErrNameTooLong, "reference %q", s)
+	}
+
+	ref.Domain, ref.Path = splitDomain(name)
+	if len(ref.Domain) > 0 {
+		if err := validateDomain(ref.Domain); err != nil {
+			return Reference{}, errors.Wrapf(err, "reference %q", s)
+		}
+	}
+	if len(ref.Path) == 0 {
+		return Reference{}, errors.Wrapf(ErrNameEmpty, "reference %q", s)
+	}
+	for _, component := range strings.Split(ref.Path, "/") {
+		if !pathComponentRegex.MatchString(component) {
+			if pathComponentRegex.MatchString(strings.ToLower(component)) {
+				return Reference{}, errors.Wrapf(ErrNameContainsUppercase, "reference %q", s)
+			}
+			return Reference{}, errors.Wrapf(ErrReferenceInvalidFormat, "reference %q", s)
+		}
+	}
+	return ref, nil
+}
+
+// ParseNormalized parses reference and normalizes it to the fully qualified form: docker.io is used as
+// default domain, library/ is added to official docker hub images and domain is lowercased
+func ParseNormalized(s string) (Reference, error) {
+	ref, err := Parse(s)
+	if err != nil {
+		return ref, err
+	}
+	ref.Domain = strings.ToLower(ref.Domain)
+	if len(ref.Domain) == 0 || ref.Domain == legacyDefaultDomain {
+		ref.Domain = DefaultDomain
+	}
+	if ref.Domain == DefaultDomain && !strings.Contains(ref.Path, "/") {
+		ref.Path = OfficialRepoPrefix + ref.Path
+	}
+	return ref, nil
+}
+
+// TrimScheme removes http://, https:// and oci:// prefixes sometimes used in image lists
+func TrimScheme(s string) string {
+	for _, scheme := range []string{"http://", "https://", "oci://"} {
+		s = strings.TrimPrefix(s, scheme)
+	}
+	return s
+}
+
+// splitDomain splits name into domain and path, domain is empty when first component is not a domain
+func splitDomain(name string) (string, string) {
+	i := strings.Index(name, "/")
+	if i == -1 {
+		return "", name
+	}
+	first := name[:i]
+	if !strings.ContainsAny(first, ".:") && first != "localhost" && strings.ToLower(first) == first {
+		return "", name
+	}
+	return first, name[i+1:]
+}
+
+func validateDomain(domain string) error {
+	host := domain
+	if strings.HasPrefix(domain, "[") {
+		end := strings.Index(domain, "]")
+		if end == -1 {
+			return ErrDomainInvalidFormat
+		}
+		host = domain[:end+1]
+		if !ipv6Regex.MatchString(host) {
+			return ErrDomainInvalidFormat
+		}
+		if rest := domain[end+1:]; len(rest) > 0 {
+			if !strings.HasPrefix(rest, ":") || !portRegex.MatchString(rest[1:]) {
+				return ErrDomainInvalidFormat
+			}
+		}
+		return nil
+	}
+	if i := strings.Index(domain, ":"); i > -1 {
+		host = domain[:i]
+		if !portRegex.MatchString(domain[i+1:]) {
+			return ErrDomainInvalidFormat
+		}
+	}
+	for _, component := range strings.Split(host, ".") {
+		if !domainComponentRegex.MatchString(component) {
+			return ErrDomainInvalidFormat
+		}
+	}
+	return nil
+}
+
+// ValidateDigest checks digest format, registered algorithms (sha256, sha384, sha512) must have lowercase
+// hex encoded value of correct length, other algorithms are rejected
+func ValidateDigest(digest string) error {
+	i := strings.Index(digest, ":")
+	if i < 1 || i == len(digest)-1 {
+		return ErrDigestInvalidFormat
+	}
+	algorithm, encoded := digest[:i], digest[i+1:]
+	if !algorithmRegex.MatchString(algorithm) || !encodedRegex.MatchString(encoded) {
+		return ErrDigestInvalidFormat
+	}
+	length, ok := digestAlgorithms[algorithm]
+	if !ok {
+		return ErrDigestUnsupported
+	}
+	if !lowerHexRegex.MatchString(encoded) {
+		return ErrDigestInvalidFormat
+	}
+	if len(encoded) != length {
+		return ErrDigestInvalidLength
+	}
+	return nil
+}
+
+// Name returns domain and path of the reference
+func (r Reference) Name() string {
+	if len(r.Domain) == 0 {
+		return r.Path
+	}
+	return r.Domain + "/" + r.Path
+}
+
+// FamiliarName returns name in the short form used by docker, i.e. redis instead of docker.io/library/redis
+func (r Reference) FamiliarName() string {
+	if r.Domain != DefaultDomain && len(r.Domain) > 0 {
+		return r.Name()
+	}
+	return strings.TrimPrefix(r.Path, OfficialRepoPrefix)
+}
+
+// String returns full reference
+func (r Reference) String() string {
+	s := r.Name()
+	if len(r.Tag) > 0 {
+		s += ":" + r.Tag
+	}
+	if len(r.Digest) > 0 {
+		s += "@" + r.Digest
+	}
+	return s
+}
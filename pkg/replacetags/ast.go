@@ -0,0 +1,390 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+/*
+"ast" parse mode loads yaml documents into node trees instead of scanning lines.
+Image references are looked up in:
+  - string values of keys ending with "image" (i.e. image, initImage) and items of lists under keys ending with "images"
+  - registry / repository / tag / digest blocks (bitnami style), in any key order and any yaml style
+  - string values at arbitrary paths which contain a registry or organization part and match substitution map
+
+Matching scalars are rewritten in place in the original text, so comments, key order, quoting and
+formatting of the rest of the file are preserved.
+*/
+
+var imageKeyRegex = regexp.MustCompile(`(?i)images?$`)
+
+// textEdit replaces a single line scalar at given position, lines and columns are 0-based, columns are in runes
+type textEdit struct {
+	line     int
+	startCol int
+	endCol   int
+	value    string
+}
+
+type astParser struct {
+	fp    *fileParser
+	lines [][]rune
+	edits []textEdit
+	// input file line number of every line in lines, resolved placeholders may span several lines
+	sourceLines []int
+}
+
+func (fp *fileParser) parseAst(in io.Reader) ([]string, error) {
+	ap, docs, err := fp.loadYamlDocuments(in)
+	if err != nil || ap == nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		ap.walk(doc, "", false)
+	}
+	return ap.apply(), nil
+}
+
+// loadYamlDocuments resolves secrets and properties and decodes all yaml documents of the input,
+// nil parser is returned for empty input. Node positions refer to the resolved text, which is split
+// into lines again so that multi-line placeholder values keep positions of the following nodes intact
+func (fp *fileParser) loadYamlDocuments(in io.Reader) (*astParser, []*yaml.Node, error) {
+	var resolvedLines []string
+	ap := astParser{fp: fp}
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(content) == 0 {
+		return nil, nil, nil
+	}
+	text := strings.TrimSuffix(string(content), "\n")
+	for lineindex, line := range strings.Split(text, "\n") {
+		if (lineindex == 0 && strings.HasPrefix(line, "# Tags replaced with Reliza CLI")) || (lineindex == 1 && strings.HasPrefix(line, "# According to")) {
+			// drop previous provenance
+			continue
+		}
+		fp.line = lineindex + 1
+		resolvedLine, err := fp.resolveSecretsPropsInLine(line)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, l := range strings.Split(resolvedLine, "\n") {
+			resolvedLines = append(resolvedLines, l)
+			ap.lines = append(ap.lines, []rune(l))
+			ap.sourceLines = append(ap.sourceLines, lineindex+1)
+		}
+	}
+
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader([]byte(strings.Join(resolvedLines, "\n") + "\n")))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "error parsing yaml in %s", fp.fileName)
+		}
+		docs = append(docs, &doc)
+	}
+	return &ap, docs, nil
+}
+
+// sourceLine maps 1-based line of the resolved text to the line number of the input file
+func (ap *astParser) sourceLine(line int) int {
+	if line < 1 || line > len(ap.sourceLines) {
+		return line
+	}
+	return ap.sourceLines[line-1]
+}
+
+// walk visits node tree, key is the mapping key under which node is located, inFlow is true inside of flow collections
+func (ap *astParser) walk(node *yaml.Node, key string, inFlow bool) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, c := range node.Content {
+			ap.walk(c, key, inFlow)
+		}
+	case yaml.SequenceNode:
+		flow := inFlow || node.Style&yaml.FlowStyle != 0
+		for _, c := range node.Content {
+			ap.walk(c, key, flow)
+		}
+	case yaml.MappingNode:
+		flow := inFlow || node.Style&yaml.FlowStyle != 0
+		if ap.replaceBitnamiBlock(node, flow) {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			ap.walk(node.Content[i+1], node.Content[i].Value, flow)
+		}
+	case yaml.ScalarNode:
+		if node.Tag != "!!str" {
+			return
+		}
+		isImageKey := imageKeyRegex.MatchString(key)
+		if !isImageKey && !strings.Contains(node.Value, "/") {
+			// avoid matching arbitrary words such as redis to docker.io/library/redis
+			return
+		}
+		ap.fp.line = ap.sourceLine(node.Line)
+		if kvs, ok := ap.matchImage(node.Value); ok {
+			replacement := GetDigestedImageFromSubstitution(kvs.Value)
+			if ap.addEdits(inFlow, scalarReplacement{node, replacement}) {
+				ap.fp.recordReplacement(node.Value, replacement, kvs.Key)
+			} else {
+				ap.fp.recordUnresolved(node.Value)
+			}
+		} else if isImageKey && len(strings.TrimSpace(node.Value)) > 0 {
+			ap.fp.recordUnresolved(node.Value)
+		}
+	}
+}
+
+// matchImage finds substitution for image reference, longer keys are preferred
+func (ap *astParser) matchImage(image string) (KeyValueSorted, bool) {
+	image = strings.TrimSpace(image)
+	if len(image) == 0 {
+		return KeyValueSorted{}, false
+	}
+	return ap.fp.findSubstitution(image)
+}
+
+// replaceBitnamiBlock rewrites registry / repository / tag / digest block, returns false if node is not such block
+func (ap *astParser) replaceBitnamiBlock(node *yaml.Node, inFlow bool) bool {
+	fields := map[string]*yaml.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch k := node.Content[i].Value; k {
+		case "registry", "repository", "tag", "digest":
+			if node.Content[i+1].Kind == yaml.ScalarNode {
+				fields[k] = node.Content[i+1]
+			}
+		}
+	}
+	repository, hasRepository := fields["repository"]
+	_, hasTag := fields["tag"]
+	_, hasDigest := fields["digest"]
+	if !hasRepository || (!hasTag && !hasDigest) {
+		return false
+	}
+
+	var bitnamiSubst Substitution
+	bitnamiSubst.Image = repository.Value
+	if registry, ok := fields["registry"]; ok && registry.Value != "" {
+		bitnamiSubst.Registry = registry.Value
+	} else {
+		// repository may include registry when registry key is absent
+		parsed := GetSubstitutionFromDigestedString(repository.Value)
+		bitnamiSubst.Registry = parsed.Registry
+		bitnamiSubst.Image = parsed.Image
+	}
+
+	if tag, ok := fields["tag"]; ok {
+		bitnamiSubst.Tag = tag.Value
+	}
+	if digest, ok := fields["digest"]; ok {
+		bitnamiSubst.Digest = digest.Value
+	}
+	ap.fp.line = ap.sourceLine(repository.Line)
+
+	var replacedSubst Substitution
+	var matchedKey string
+	matchKey := GetMatchingKeyFromSubstitution(bitnamiSubst)
+	for _, kvs := range ap.fp.sortedSubstitutions {
+		if isImageMatchingSubstitutionKey(matchKey, kvs.Key) {
+			replacedSubst = kvs.Value
+			matchedKey = kvs.Key
+			break
+		}
+	}
+	if len(replacedSubst.Digest) < 1 {
+		// not matched, still a block of scalars so there is nothing else to look for inside
+		ap.fp.recordUnresolved(GetDigestedImageFromSubstitution(bitnamiSubst))
+		return true
+	}
+	var replacements []scalarReplacement
+	if registry, ok := fields["registry"]; ok {
+		replacements = append(replacements, scalarReplacement{registry, replacedSubst.Registry}, scalarReplacement{repository, replacedSubst.Image})
+	} else if replacedSubst.Registry == "docker.io" && !strings.HasPrefix(repository.Value, "docker.io/") {
+		replacements = append(replacements, scalarReplacement{repository, replacedSubst.Image})
+	} else {
+		replacements = append(replacements, scalarReplacement{repository, replacedSubst.Registry + "/" + replacedSubst.Image})
+	}
+	if tag, ok := fields["tag"]; ok {
+		if hasDigest {
+			replacements = append(replacements, scalarReplacement{tag, replacedSubst.Tag})
+		} else {
+			// tag is used to hold digest when there is no digest key
+			replacements = append(replacements, scalarReplacement{tag, replacedSubst.Digest})
+		}
+	}
+	if digest, ok := fields["digest"]; ok {
+		replacements = append(replacements, scalarReplacement{digest, replacedSubst.Digest})
+	}
+	if ap.addEdits(inFlow, replacements...) {
+		ap.fp.recordReplacement(GetDigestedImageFromSubstitution(bitnamiSubst), GetDigestedImageFromSubstitution(replacedSubst), matchedKey)
+	} else {
+		ap.fp.recordUnresolved(GetDigestedImageFromSubstitution(bitnamiSubst))
+	}
+	return true
+}
+
+// scalarReplacement is a new value of scalar node
+type scalarReplacement struct {
+	node  *yaml.Node
+	value string
+}
+
+// addEdits registers replacements of scalar nodes text, either all of them or none. False is returned when text of
+// a scalar can not be located, i.e. for multi-line and block scalars, which are then left as is
+func (ap *astParser) addEdits(inFlow bool, replacements ...scalarReplacement) bool {
+	var edits []textEdit
+	for _, r := range replacements {
+		edit, ok := ap.scalarEdit(r.node, r.value, inFlow)
+		if !ok {
+			return false
+		}
+		if edit != nil {
+			edits = append(edits, *edit)
+		}
+	}
+	ap.edits = append(ap.edits, edits...)
+	return true
+}
+
+// scalarEdit returns edit replacing text of scalar node, edit is nil when value is unchanged
+func (ap *astParser) scalarEdit(node *yaml.Node, value string, inFlow bool) (*textEdit, bool) {
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 || node.Line < 1 || node.Line > len(ap.lines) {
+		return nil, false
+	}
+	line := ap.lines[node.Line-1]
+	start := skipNodeProperties(line, node.Column-1)
+	if start < 0 || start > len(line) {
+		return nil, false
+	}
+	end, ok := scalarEnd(line, start, node, inFlow)
+	if !ok {
+		return nil, false
+	}
+	if node.Value == value {
+		return nil, true
+	}
+	return &textEdit{line: node.Line - 1, startCol: start, endCol: end, value: quoteScalar(value, node.Style)}, true
+}
+
+// skipNodeProperties returns column of scalar text after anchor and tag, i.e. &img or !!str, which precede it
+func skipNodeProperties(line []rune, start int) int {
+	for start >= 0 && start < len(line) && (line[start] == '&' || line[start] == '!') {
+		for start < len(line) && line[start] != ' ' && line[start] != '\t' {
+			start++
+		}
+		for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+			start++
+		}
+	}
+	return start
+}
+
+// scalarEnd returns column right after the scalar which starts at start, ok is false for scalars continuing on next lines
+func scalarEnd(line []rune, start int, node *yaml.Node, inFlow bool) (int, bool) {
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\\' {
+				i++
+			} else if line[i] == '"' {
+				return i + 1, true
+			}
+		}
+		return 0, false
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, true
+			}
+		}
+		return 0, false
+	}
+	end := len(line)
+	for i := start; i < len(line); i++ {
+		if line[i] == '#' && i > start && (line[i-1] == ' ' || line[i-1] == '\t') {
+			end = i
+			break
+		}
+		if inFlow && (line[i] == ',' || line[i] == ']' || line[i] == '}') {
+			end = i
+			break
+		}
+	}
+	for end > start && (line[end-1] == ' ' || line[end-1] == '\t') {
+		end--
+	}
+	if string(line[start:end]) != node.Value {
+		// plain scalar folded over several lines
+		return 0, false
+	}
+	return end, true
+}
+
+// quoteScalar renders value in the same style as the original scalar
+func quoteScalar(value string, style yaml.Style) string {
+	switch {
+	case style&yaml.DoubleQuotedStyle != 0:
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`) + `"`
+	case style&yaml.SingleQuotedStyle != 0:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	case value == "":
+		return `""`
+	}
+	return value
+}
+
+// apply applies edits to the text and returns resulting lines
+func (ap *astParser) apply() []string {
+	sort.Slice(ap.edits, func(i, j int) bool {
+		if ap.edits[i].line != ap.edits[j].line {
+			return ap.edits[i].line < ap.edits[j].line
+		}
+		return ap.edits[i].startCol > ap.edits[j].startCol
+	})
+	for _, e := range ap.edits {
+		line := ap.lines[e.line]
+		replaced := append([]rune{}, line[:e.startCol]...)
+		replaced = append(replaced, []rune(e.value)...)
+		ap.lines[e.line] = append(replaced, line[e.endCol:]...)
+	}
+	parsedLines := make([]string, len(ap.lines))
+	for i, line := range ap.lines {
+		parsedLines[i] = string(line)
+	}
+	return parsedLines
+}
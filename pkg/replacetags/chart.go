@@ -0,0 +1,209 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/helm"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+	"sigs.k8s.io/yaml"
+)
+
+/*
+Chart mode renders helm chart with built-in template engine instead of rewriting files, so helm binary is not needed:
+
+ 1. values.yaml of the chart is merged with value files (relative paths are resolved against chart directory, as in helmvalues)
+ 2. chart is rendered and image references found in rendered manifests limit substitution map, same as definition reference file does
+ 3. merged values, including defaults of enabled subcharts, are rewritten in ast parse mode
+ 4. only values changed by substitution are written, so output is an override values file to pass to helm with -f,
+    $RELIZA{...} placeholders of values are left as is so that secrets never end up in it
+
+Chart is rendered again with the overrides and images which are still not pinned by digest are recorded in report as unresolved,
+with strict parse mode they fail the run. Line numbers of replacements in report refer to merged values, not to any file of the chart.
+*/
+
+// ErrChartWithInput is returned when chart is used together with infile or indirectory
+var ErrChartWithInput = errors.New("chart can not be used together with infile or indirectory")
+
+// prepareChartVars points infile to chart, outfile is left as is, so by default overrides go to stdout
+func prepareChartVars(replaceTagsVars *ReplaceTagsVars) error {
+	if len(replaceTagsVars.Infile) > 0 || len(replaceTagsVars.Indirectory) > 0 {
+		return ErrChartWithInput
+	}
+	if len(replaceTagsVars.Kustomization) > 0 {
+		return errors.New("chart can not be used together with kustomization")
+	}
+	replaceTagsVars.Infile = replaceTagsVars.Chart
+	return nil
+}
+
+func renderChartValues(ctx context.Context, replaceTagsVars *ReplaceTagsVars, substitutionMap map[string]Substitution) (string, error) {
+	chartPath := replaceTagsVars.Chart
+	parseMode, err := normalizeParseMode(replaceTagsVars.ParseMode)
+	if err != nil {
+		return "", err
+	}
+	if parseMode == "compose" {
+		return "", errors.New("compose parse mode can not be used with chart")
+	}
+
+	chart, err := helm.LoadChart(chartPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "error loading chart: %s", chartPath)
+	}
+	valuesDir := chartPath
+	if fileInfo, err := os.Stat(chartPath); err == nil && !fileInfo.IsDir() {
+		// packaged chart, value files are next to it
+		valuesDir = filepath.Dir(chartPath)
+	}
+	userValues, err := helm.MergeValues(replaceTagsVars.ValueFiles, valuesDir)
+	if err != nil {
+		return "", err
+	}
+	values := helm.MergeMaps(chart.Values, userValues)
+	options := helm.ReleaseOptions{Namespace: replaceTagsVars.Namespace}
+
+	rendered, err := helm.Render(chart, values, options)
+	if err != nil {
+		return "", err
+	}
+	defScanMap, err := scanDefinitionReferences(strings.NewReader(helm.Manifests(rendered)))
+	if err != nil {
+		return "", err
+	}
+	chartSubstitutionMap := map[string]Substitution{}
+	for k, v := range substitutionMap {
+		if _, ok := defScanMap[k]; ok {
+			chartSubstitutionMap[k] = v
+		}
+	}
+	replaceTagsVars.logf("Images rendered by chart %s: %d, of them in tag source: %d\n", chartPath, len(defScanMap), len(chartSubstitutionMap))
+
+	coalesced := helm.CoalesceValues(chart, values)
+	valuesYaml, err := yaml.Marshal(coalesced)
+	if err != nil {
+		return "", err
+	}
+	valuesVars := *replaceTagsVars
+	valuesVars.ParseMode = "ast"
+	// placeholders are not resolved, otherwise values of secrets and properties would be written to overrides
+	valuesVars.keepPlaceholders = true
+	parsedLines, err := substituteCopyBasedOnMap(ctx, bytes.NewReader(valuesYaml), chartPath, chartSubstitutionMap, &valuesVars, hub.SecretPropsRHResp{})
+	if err != nil {
+		return "", err
+	}
+	pinned := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(strings.Join(parsedLines, "\n")), &pinned); err != nil {
+		return "", errors.Wrap(err, "error parsing pinned values")
+	}
+	overrides := changedValues(coalesced, pinned)
+
+	if err := checkChartPinned(replaceTagsVars, chart, helm.MergeMaps(values, overrides), options, parseMode); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if !replaceTagsVars.ForDiff && replaceTagsVars.Provenance {
+		out.WriteString(provenanceHeader(replaceTagsVars))
+	}
+	if len(overrides) > 0 {
+		overridesYaml, err := yaml.Marshal(overrides)
+		if err != nil {
+			return "", err
+		}
+		out.Write(overridesYaml)
+	}
+	return out.String(), nil
+}
+
+// checkChartPinned renders chart with overrides applied and records images without digest as unresolved,
+// in strict mode first of them is returned as error
+func checkChartPinned(replaceTagsVars *ReplaceTagsVars, chart *helm.Chart, values map[string]interface{}, options helm.ReleaseOptions, parseMode string) error {
+	if replaceTagsVars.Report == nil && parseMode != "strict" {
+		return nil
+	}
+	rendered, err := helm.Render(chart, values, options)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range rendered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		unresolved := unpinnedImages(rendered[name])
+		if len(unresolved) == 0 {
+			continue
+		}
+		if parseMode == "strict" {
+			return &StrictModeError{File: name, Line: "image: " + unresolved[0].Reference}
+		}
+		replaceTagsVars.Report.addFile(FileReport{Infile: name, Outfile: replaceTagsVars.Outfile, Unresolved: unresolved})
+	}
+	return nil
+}
+
+// unpinnedImages returns image references of manifest which are not pinned by digest
+func unpinnedImages(manifest string) []UnresolvedImage {
+	var unresolved []UnresolvedImage
+	scanner := bufio.NewScanner(strings.NewReader(manifest))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if i := strings.Index(strings.ToLower(line), "image: "); i > -1 {
+			image := strings.Trim(strings.TrimSpace(line[i+len("image: "):]), "\"'")
+			ref, err := reference.Parse(image)
+			if err == nil && len(ref.Digest) == 0 {
+				unresolved = append(unresolved, UnresolvedImage{Line: lineNumber, Reference: image})
+			}
+		}
+	}
+	return unresolved
+}
+
+// changedValues returns leaves of after which differ from before, lists are compared as a whole since helm does not merge them
+func changedValues(before map[string]interface{}, after map[string]interface{}) map[string]interface{} {
+	changed := map[string]interface{}{}
+	for k, afterValue := range after {
+		beforeValue, exists := before[k]
+		beforeMap, beforeIsMap := beforeValue.(map[string]interface{})
+		afterMap, afterIsMap := afterValue.(map[string]interface{})
+		if exists && beforeIsMap && afterIsMap {
+			if nested := changedValues(beforeMap, afterMap); len(nested) > 0 {
+				changed[k] = nested
+			}
+			continue
+		}
+		if !exists || !reflect.DeepEqual(beforeValue, afterValue) {
+			changed[k] = afterValue
+		}
+	}
+	return changed
+}
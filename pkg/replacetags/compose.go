@@ -0,0 +1,402 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+/*
+"compose" parse mode understands docker compose files:
+  - image keys of services are rewritten, including services with build sections and services in profiles
+  - image keys inside of top level x- extension fields are rewritten, so anchors shared by services are pinned once
+  - variable interpolation, i.e. image: ${REGISTRY}/app:${TAG}, is resolved from env file (.env next to compose
+    file by default), environment variables take precedence over env file as in docker compose
+
+With EmitEnv set compose file is not changed, instead env file with pinned values of variables is produced:
+a variable which holds whole image is set to digested image, a variable which holds tag (follows ':') is set
+to tag@digest and a variable which follows '@' is set to digest.
+*/
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+
+// interpolationToken is a literal part or a variable of interpolated string
+type interpolationToken struct {
+	literal  string
+	variable string
+	isVar    bool
+}
+
+// envFile keeps lines of env file, so that it can be written back with pinned values
+type envFile struct {
+	lines  []string
+	values map[string]string
+}
+
+// interpolate expands compose style variables: $VAR, ${VAR}, ${VAR:-default}, ${VAR-default}, ${VAR:?error},
+// ${VAR?error}, ${VAR:+replacement}, ${VAR+replacement}, $$ is literal $
+func interpolate(template string, lookup func(string) (string, bool)) (string, []interpolationToken, error) {
+	var tokens []interpolationToken
+	var expanded strings.Builder
+	literal := func(s string) {
+		expanded.WriteString(s)
+		if len(tokens) > 0 && !tokens[len(tokens)-1].isVar {
+			tokens[len(tokens)-1].literal += s
+		} else {
+			tokens = append(tokens, interpolationToken{literal: s})
+		}
+	}
+	for i := 0; i < len(template); {
+		if template[i] != '$' {
+			next := strings.IndexByte(template[i:], '$')
+			if next == -1 {
+				next = len(template) - i
+			}
+			literal(template[i : i+next])
+			i += next
+			continue
+		}
+		rest := template[i+1:]
+		switch {
+		case strings.HasPrefix(rest, "$"):
+			literal("$")
+			i += 2
+		case strings.HasPrefix(rest, "{"):
+			end := matchingBrace(rest)
+			if end == -1 {
+				return "", nil, errors.Errorf("unterminated variable in %q", template)
+			}
+			value, name, err := expandBraced(rest[1:end], lookup)
+			if err != nil {
+				return "", nil, err
+			}
+			expanded.WriteString(value)
+			tokens = append(tokens, interpolationToken{literal: value, variable: name, isVar: true})
+			i += end + 2
+		default:
+			name := envNameRegex.FindString(rest)
+			if len(name) == 0 {
+				literal("$")
+				i++
+				continue
+			}
+			value, _ := lookup(name)
+			expanded.WriteString(value)
+			tokens = append(tokens, interpolationToken{literal: value, variable: name, isVar: true})
+			i += len(name) + 1
+		}
+	}
+	return expanded.String(), tokens, nil
+}
+
+// matchingBrace returns index of } closing { at position 0
+func matchingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func expandBraced(expr string, lookup func(string) (string, bool)) (string, string, error) {
+	name := envNameRegex.FindString(expr)
+	if len(name) == 0 {
+		return "", "", errors.Errorf("invalid variable name in ${%s}", expr)
+	}
+	value, isSet := lookup(name)
+	modifier := expr[len(name):]
+	if len(modifier) == 0 {
+		return value, name, nil
+	}
+	operator := modifier[:1]
+	colon := false
+	if operator == ":" && len(modifier) > 1 {
+		colon = true
+		operator = modifier[1:2]
+		modifier = modifier[1:]
+	}
+	arg, _, err := interpolate(modifier[1:], lookup)
+	if err != nil {
+		return "", "", err
+	}
+	missing := !isSet || (colon && len(value) == 0)
+	switch operator {
+	case "-":
+		if missing {
+			value = arg
+		}
+	case "?":
+		if missing {
+			return "", "", errors.Errorf("required variable %s is missing a value: %s", name, arg)
+		}
+	case "+":
+		if missing {
+			value = ""
+		} else {
+			value = arg
+		}
+	default:
+		return "", "", errors.Errorf("invalid interpolation format ${%s}", expr)
+	}
+	return value, name, nil
+}
+
+// readEnvFile reads KEY=VALUE lines, comments and export prefix are supported, values may be quoted
+func readEnvFile(path string) (*envFile, error) {
+	ef := &envFile{values: map[string]string{}}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		ef.lines = append(ef.lines, line)
+		if key, value, ok := parseEnvLine(line); ok {
+			ef.values[key] = value
+		}
+	}
+	return ef, s.Err()
+}
+
+func parseEnvLine(line string) (string, string, bool) {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+		return "", "", false
+	}
+	trimmed = strings.TrimPrefix(trimmed, "export ")
+	key, value, ok := strings.Cut(trimmed, "=")
+	if !ok {
+		return "", "", false
+	}
+	key = strings.TrimSpace(key)
+	value = strings.TrimSpace(value)
+	if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		value = value[1 : len(value)-1]
+	} else if i := strings.Index(value, " #"); i > -1 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return key, value, true
+}
+
+// render returns lines of env file with pinned values replaced in place and new values appended
+func (ef *envFile) render(pinned map[string]string) []string {
+	var lines []string
+	written := map[string]bool{}
+	for _, line := range ef.lines {
+		if key, _, ok := parseEnvLine(line); ok {
+			if value, isPinned := pinned[key]; isPinned {
+				prefix := ""
+				if strings.HasPrefix(strings.TrimSpace(line), "export ") {
+					prefix = "export "
+				}
+				line = prefix + key + "=" + value
+				written[key] = true
+			}
+		}
+		lines = append(lines, line)
+	}
+	var newKeys []string
+	for key := range pinned {
+		if !written[key] {
+			newKeys = append(newKeys, key)
+		}
+	}
+	sort.Strings(newKeys)
+	for _, key := range newKeys {
+		lines = append(lines, key+"="+pinned[key])
+	}
+	return lines
+}
+
+type composeParser struct {
+	*astParser
+	env    *envFile
+	lookup func(string) (string, bool)
+	pinned map[string]string
+}
+
+func (fp *fileParser) parseCompose(in io.Reader) ([]string, error) {
+	ap, docs, err := fp.loadYamlDocuments(in)
+	if err != nil || ap == nil {
+		return nil, err
+	}
+	cp := composeParser{astParser: ap, env: &envFile{values: map[string]string{}}, pinned: map[string]string{}}
+
+	envFilePath := fp.vars.EnvFile
+	if len(envFilePath) < 1 {
+		defaultEnvFile := filepath.Join(filepath.Dir(fp.fileName), ".env")
+		if _, err := os.Stat(defaultEnvFile); err == nil {
+			envFilePath = defaultEnvFile
+		}
+	}
+	if len(envFilePath) > 0 {
+		if cp.env, err = readEnvFile(envFilePath); err != nil {
+			return nil, errors.Wrapf(err, "error reading env file %s", envFilePath)
+		}
+	}
+	cp.lookup = func(key string) (string, bool) {
+		if fp.vars.LookupEnv != nil {
+			if value, ok := fp.vars.LookupEnv(key); ok {
+				return value, true
+			}
+		}
+		value, ok := cp.env.values[key]
+		return value, ok
+	}
+
+	for _, doc := range docs {
+		if len(doc.Content) < 1 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		root := doc.Content[0]
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			key, value := root.Content[i].Value, root.Content[i+1]
+			if key == "services" && value.Kind == yaml.MappingNode {
+				for j := 1; j < len(value.Content); j += 2 {
+					if err := cp.walkService(value.Content[j], value.Style&yaml.FlowStyle != 0); err != nil {
+						return nil, err
+					}
+				}
+			} else if strings.HasPrefix(key, "x-") {
+				if err := cp.walkExtension(value, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if fp.vars.EmitEnv {
+		return cp.env.render(cp.pinned), nil
+	}
+	return cp.apply(), nil
+}
+
+func (cp *composeParser) walkService(service *yaml.Node, inFlow bool) error {
+	if service.Kind != yaml.MappingNode {
+		return nil
+	}
+	flow := inFlow || service.Style&yaml.FlowStyle != 0
+	if image := mappingValue(service, "image"); image != nil && image.Kind == yaml.ScalarNode {
+		return cp.replaceImage(image, flow)
+	}
+	return nil
+}
+
+// walkExtension looks for image keys at any depth of x- extension field
+func (cp *composeParser) walkExtension(node *yaml.Node, inFlow bool) error {
+	flow := inFlow || node.Style&yaml.FlowStyle != 0
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == "image" && node.Content[i+1].Kind == yaml.ScalarNode {
+				if err := cp.replaceImage(node.Content[i+1], flow); err != nil {
+					return err
+				}
+			} else if err := cp.walkExtension(node.Content[i+1], flow); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, c := range node.Content {
+			if err := cp.walkExtension(c, flow); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (cp *composeParser) replaceImage(node *yaml.Node, inFlow bool) error {
+	cp.fp.line = cp.sourceLine(node.Line)
+	expanded, tokens, err := interpolate(node.Value, cp.lookup)
+	if err != nil {
+		return errors.Wrapf(err, "%s line %d", cp.fp.fileName, cp.fp.line)
+	}
+	if len(strings.TrimSpace(expanded)) == 0 {
+		return nil
+	}
+	kvs, found := cp.fp.findSubstitution(expanded)
+	if !found {
+		cp.fp.recordUnresolved(expanded)
+		return nil
+	}
+	replacement := GetDigestedImageFromSubstitution(kvs.Value)
+	if !cp.fp.vars.EmitEnv {
+		if cp.addEdits(inFlow, scalarReplacement{node, replacement}) {
+			cp.fp.recordReplacement(node.Value, replacement, kvs.Key)
+		} else {
+			cp.fp.recordUnresolved(node.Value)
+		}
+		return nil
+	}
+
+	pinnedVar, pinnedValue := pinVariable(tokens, kvs.Value, replacement)
+	if len(pinnedVar) < 1 {
+		// image is not parameterized in a way that can be pinned with env file
+		cp.fp.recordUnresolved(node.Value)
+		return nil
+	}
+	cp.pinned[pinnedVar] = pinnedValue
+	cp.fp.recordReplacement(node.Value, replacement, kvs.Key)
+	return nil
+}
+
+// pinVariable returns variable and its value which pin interpolated image to the substitution
+func pinVariable(tokens []interpolationToken, subst Substitution, replacement string) (string, string) {
+	last := tokens[len(tokens)-1]
+	if !last.isVar {
+		return "", ""
+	}
+	if len(tokens) == 1 {
+		return last.variable, replacement
+	}
+	previous := tokens[len(tokens)-2].literal
+	switch {
+	case strings.HasSuffix(previous, "@"):
+		return last.variable, subst.Digest
+	case strings.HasSuffix(previous, ":"):
+		tag := subst.Tag
+		if len(tag) < 1 {
+			tag, _, _ = strings.Cut(last.literal, "@")
+		}
+		if len(subst.Digest) > 0 {
+			return last.variable, tag + "@" + subst.Digest
+		}
+		return last.variable, tag
+	}
+	return "", ""
+}
@@ -0,0 +1,216 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+)
+
+/*
+Directory replacement runs in three steps:
+ 1. input tree is walked and a job is created for every file, output directories are created on the way
+ 2. $RELIZA{...} placeholders of all parsed files are collected and resolved on Reliza Hub in a single call
+ 3. jobs are processed by a pool of workers, report entries and errors are collected per job and merged
+    in walk order, so that output does not depend on scheduling
+*/
+
+// fileJob is a single file of input directory
+type fileJob struct {
+	infile  string
+	outfile string
+	parse   bool // false if file is copied as is
+}
+
+// DirectoryError is returned when processing of one or more files of directory failed, errors are in walk order
+type DirectoryError struct {
+	Errors []error
+}
+
+func (e *DirectoryError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	var msgs []string
+	for _, err := range e.Errors {
+		msgs = append(msgs, "\t"+err.Error())
+	}
+	return "processing of " + strconv.Itoa(len(e.Errors)) + " files failed:\n" + strings.Join(msgs, "\n")
+}
+
+// Unwrap allows errors.Is and errors.As to inspect errors of single files
+func (e *DirectoryError) Unwrap() []error {
+	return e.Errors
+}
+
+// collectDirectoryJobs walks indir, output directories are only created if create is set
+func collectDirectoryJobs(replaceTagsVars *ReplaceTagsVars, indir string, outdir string, filter *fileFilter, create bool) ([]fileJob, error) {
+	if create {
+		if err := os.MkdirAll(outdir, os.FileMode(0770)); err != nil {
+			return nil, errors.Wrapf(err, "could not create directory %s", outdir)
+		}
+	}
+
+	files, err := os.ReadDir(indir)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []fileJob
+	for _, f := range files {
+		curinfile := filepath.Join(indir, f.Name())
+		curoutfile := filepath.Join(outdir, f.Name())
+		if (f.IsDir() && f.Name() == ".git") || (f.Name() == RelizaIgnoreFile && indir == replaceTagsVars.Indirectory) {
+			// repository metadata and ignore file are not part of output
+			continue
+		}
+		if f.IsDir() {
+			dirJobs, err := collectDirectoryJobs(replaceTagsVars, curinfile, curoutfile, filter, create)
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, dirJobs...)
+			continue
+		}
+		relPath, err := filepath.Rel(replaceTagsVars.Indirectory, curinfile)
+		if err != nil {
+			return nil, err
+		}
+		parse, err := filter.shouldParseFile(curinfile, relPath)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, fileJob{infile: curinfile, outfile: curoutfile, parse: parse})
+	}
+	return jobs, nil
+}
+
+// resolveDirectorySecretProps resolves placeholders of all parsed files with a single Reliza Hub call
+func resolveDirectorySecretProps(ctx context.Context, replaceTagsVars *ReplaceTagsVars, jobs []fileJob) (*hub.SecretPropsRHResp, error) {
+	if !replaceTagsVars.ResolveProps {
+		return &hub.SecretPropsRHResp{}, nil
+	}
+	sp := SecretProps{Secrets: map[string]bool{}, Properties: map[string]bool{}}
+	for _, job := range jobs {
+		if !job.parse {
+			continue
+		}
+		content, err := os.ReadFile(job.infile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error opening infile: %s", job.infile)
+		}
+		fileSp, err := parseSecretsPropsFromInFile(bytes.NewReader(content), job.infile)
+		if err != nil {
+			return nil, err
+		}
+		for k := range fileSp.Secrets {
+			sp.Secrets[k] = true
+		}
+		for k := range fileSp.Properties {
+			sp.Properties[k] = true
+		}
+	}
+	replaceTagsVars.logf("Resolving %d properties and %d secrets of %d files...\n", len(sp.Properties), len(sp.Secrets), len(jobs))
+	resolvedSp, err := resolveSecretPropsOnRelizaHub(ctx, replaceTagsVars, sp)
+	if err != nil {
+		return nil, err
+	}
+	return &resolvedSp, nil
+}
+
+// runFileJobs calls process for every job using replaceTagsVars.Workers workers, process receives copy of vars
+// with infile, outfile, resolved placeholders and own report set
+func runFileJobs(ctx context.Context, replaceTagsVars *ReplaceTagsVars, jobs []fileJob, resolvedSp *hub.SecretPropsRHResp,
+	process func(fileVars *ReplaceTagsVars, job fileJob) error) error {
+	workers := replaceTagsVars.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	errs := make([]error, len(jobs))
+	reports := make([]*Report, len(jobs))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := ctx.Err(); err != nil {
+					errs[i] = err
+					continue
+				}
+				fileVars := *replaceTagsVars
+				fileVars.Infile = jobs[i].infile
+				fileVars.Outfile = jobs[i].outfile
+				fileVars.Indirectory = ""
+				fileVars.resolvedSecretProps = resolvedSp
+				if replaceTagsVars.Report != nil {
+					reports[i] = &Report{}
+					fileVars.Report = reports[i]
+				}
+				errs[i] = process(&fileVars, jobs[i])
+			}
+		}()
+	}
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var dirErr DirectoryError
+	for i, err := range errs {
+		if err != nil {
+			dirErr.Errors = append(dirErr.Errors, errors.Wrapf(err, "%s", jobs[i].infile))
+		}
+		if reports[i] != nil {
+			for _, fr := range reports[i].Files {
+				replaceTagsVars.Report.addFile(fr)
+			}
+		}
+	}
+	if len(dirErr.Errors) > 0 {
+		return &dirErr
+	}
+	return nil
+}
+
+// secretProps returns placeholders resolved for the whole directory or resolves placeholders of content of fileName
+func (replaceTagsVars *ReplaceTagsVars) secretProps(ctx context.Context, fileName string, content []byte) (hub.SecretPropsRHResp, error) {
+	if replaceTagsVars.resolvedSecretProps != nil {
+		return *replaceTagsVars.resolvedSecretProps, nil
+	}
+	sp, err := parseSecretsPropsFromInFile(bytes.NewReader(content), fileName)
+	if err != nil {
+		return hub.SecretPropsRHResp{}, err
+	}
+	return resolveSecretPropsOnRelizaHub(ctx, replaceTagsVars, sp)
+}
@@ -0,0 +1,84 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidInput is returned when neither or both of infile and indirectory are supplied
+	ErrInvalidInput = errors.New("must supply either infile or indirectory (but not both)")
+	// ErrNoTagSource is returned when none of tag source file, bundle, environment or instance are supplied
+	ErrNoTagSource = errors.New("specify either tagsource or instance or bundle and version")
+	// ErrOutfileWithDirectory is returned when outfile is supplied together with indirectory
+	ErrOutfileWithDirectory = errors.New("please only provide outdirectory (no outfile) when using indirectory as input instead of infile")
+	// ErrNoOutDirectory is returned when indirectory is used without outdirectory
+	ErrNoOutDirectory = errors.New("outdirectory is not set, must supply a path to an output directory when using indirectory")
+)
+
+// InvalidParseModeError is returned when parse mode is not one of supported modes
+type InvalidParseModeError struct {
+	Mode string
+}
+
+func (e *InvalidParseModeError) Error() string {
+	return fmt.Sprintf("'%s' is not a valid parsemode, must be either 'simple', 'extended', 'strict', 'ast' or 'compose'", e.Mode)
+}
+
+// MissingPropertyError is returned when $RELIZA{PROPERTY.key} can not be resolved and has no default
+type MissingPropertyError struct {
+	Key  string
+	File string
+}
+
+func (e *MissingPropertyError) Error() string {
+	return fmt.Sprintf("property %s used in %s is not set; also make sure that resolveprops is set to true", e.Key, e.File)
+}
+
+// MissingSecretError is returned when $RELIZA{SECRET.key} or $RELIZA{PLAINSECRET.key} can not be resolved
+type MissingSecretError struct {
+	Key  string
+	File string
+}
+
+func (e *MissingSecretError) Error() string {
+	return fmt.Sprintf("secret %s used in %s is not set or not available; also make sure that resolveprops is set to true", e.Key, e.File)
+}
+
+// MissingEnvError is returned when $RELIZA{ENV.key} refers to environment variable which is not set and has no default
+type MissingEnvError struct {
+	Key  string
+	File string
+}
+
+func (e *MissingEnvError) Error() string {
+	return fmt.Sprintf("environment variable %s used in %s is not set", e.Key, e.File)
+}
+
+// StrictModeError is returned in strict parse mode when an image line does not match any substitution
+type StrictModeError struct {
+	File string
+	Line string
+}
+
+func (e *StrictModeError) Error() string {
+	return fmt.Sprintf("failed to parse infile '%s'. Parse mode is set to 'strict' and cannot find artifact in substitution map: \n\t%s", e.File, e.Line)
+}
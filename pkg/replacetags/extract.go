@@ -0,0 +1,207 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+)
+
+/*
+Extraction is the reverse of tag replacement: image references already present in infile or in all files of
+indirectory are collected and written as CycloneDX BOM of container components, which can be used as a tag
+source file or compared with what Reliza Hub says should be deployed. Images are detected the same way as
+unresolved images are during replacement - image keys, bitnami style blocks, images lists and JSON manifests.
+*/
+
+// cycloneBom is the subset of CycloneDX JSON written by extraction
+type cycloneBom struct {
+	BomFormat    string             `json:"bomFormat"`
+	SpecVersion  string             `json:"specVersion"`
+	SerialNumber string             `json:"serialNumber"`
+	Version      int                `json:"version"`
+	Metadata     cycloneMetadata    `json:"metadata"`
+	Components   []cycloneComponent `json:"components"`
+}
+
+type cycloneMetadata struct {
+	Timestamp string        `json:"timestamp"`
+	Tools     []cycloneTool `json:"tools"`
+}
+
+type cycloneTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cycloneComponent struct {
+	Type    string        `json:"type"`
+	Name    string        `json:"name"`
+	Version string        `json:"version,omitempty"`
+	Purl    string        `json:"purl"`
+	Hashes  []cycloneHash `json:"hashes,omitempty"`
+}
+
+type cycloneHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// ExtractTagSource collects images referenced in infile or in files of indirectory selected by Include and Exclude
+// and returns them as CycloneDX JSON BOM, which is written to outfile instead if it is set
+func ExtractTagSource(ctx context.Context, replaceTagsVars ReplaceTagsVars) (string, error) {
+	var files []string
+	if len(replaceTagsVars.Infile) > 0 && len(replaceTagsVars.Indirectory) == 0 {
+		files = []string{replaceTagsVars.Infile}
+	} else if len(replaceTagsVars.Infile) == 0 && len(replaceTagsVars.Indirectory) > 0 {
+		filter, err := newFileFilter(&replaceTagsVars, replaceTagsVars.Indirectory)
+		if err != nil {
+			return "", err
+		}
+		// output directory is not used, so it is the same as input to skip checks of its existence
+		jobs, err := collectDirectoryJobs(&replaceTagsVars, replaceTagsVars.Indirectory, replaceTagsVars.Indirectory, filter, false)
+		if err != nil {
+			return "", err
+		}
+		for _, job := range jobs {
+			if job.parse {
+				files = append(files, job.infile)
+			}
+		}
+	} else {
+		return "", ErrInvalidInput
+	}
+
+	parseMode, err := normalizeParseMode(replaceTagsVars.ParseMode)
+	if err != nil {
+		return "", err
+	}
+	if parseMode == "strict" {
+		// nothing is matched on extraction, so strict mode would fail on the first image
+		parseMode = "extended"
+	}
+
+	images := map[string]reference.Reference{}
+	for _, file := range files {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", errors.Wrapf(err, "error opening infile: %s", file)
+		}
+		fp := fileParser{vars: &replaceTagsVars, fileName: file, parseMode: parseMode, keepPlaceholders: true}
+		if _, err := fp.parseContent(content); err != nil {
+			return "", errors.Wrapf(err, "error parsing %s", file)
+		}
+		for _, u := range fp.report.Unresolved {
+			if ref, ok := parseExtractedImage(u.Reference); ok {
+				images[ref.String()] = ref
+			}
+		}
+		replaceTagsVars.logf("Extracted %d images from %s\n", len(fp.report.Unresolved), file)
+	}
+
+	bomJson, err := json.MarshalIndent(newExtractedBom(images, replaceTagsVars.CliVersion), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	bomJson = append(bomJson, '\n')
+	if len(replaceTagsVars.Outfile) < 1 {
+		return string(bomJson), nil
+	}
+	if err := os.WriteFile(replaceTagsVars.Outfile, bomJson, 0644); err != nil {
+		return "", errors.Wrapf(err, "error writing outfile: %s", replaceTagsVars.Outfile)
+	}
+	return "", nil
+}
+
+// parseExtractedImage parses image reference found in file, bitnami blocks with digest in tag are
+// reported as repository:sha256:..., values which are not image references, i.e. templates, are skipped
+func parseExtractedImage(image string) (reference.Reference, bool) {
+	image, _, _ = strings.Cut(image, " #")
+	image = reference.TrimScheme(strings.Trim(strings.TrimSpace(image), "\"'"))
+	if i := strings.LastIndex(image, ":sha256:"); i > -1 && !strings.Contains(image, "@") {
+		image = image[:i] + "@" + image[i+1:]
+	}
+	ref, err := reference.ParseNormalized(image)
+	if err != nil {
+		return ref, false
+	}
+	return ref, true
+}
+
+// newExtractedBom creates BOM with a container component per image, sorted by reference
+func newExtractedBom(images map[string]reference.Reference, cliVersion string) cycloneBom {
+	bom := cycloneBom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + uuid.NewString(),
+		Version:      1,
+		Metadata: cycloneMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []cycloneTool{{Vendor: "Reliza Incorporated", Name: "Reliza CLI", Version: cliVersion}},
+		},
+		Components: []cycloneComponent{},
+	}
+	var keys []string
+	for k := range images {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		ref := images[k]
+		component := cycloneComponent{Type: "container", Name: ref.Name(), Version: ref.Tag, Purl: imagePurl(ref)}
+		if alg, content, ok := strings.Cut(ref.Digest, ":"); ok && alg == "sha256" {
+			component.Hashes = []cycloneHash{{Alg: "SHA-256", Content: content}}
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return bom
+}
+
+// imagePurl returns pkg:docker package url of image, registries other than docker hub are set in repository_url
+// as Reliza Hub does, i.e. pkg:docker/org/app@sha256%3A...?repository_url=ghcr.io&tag=1.0
+func imagePurl(ref reference.Reference) string {
+	purl := "pkg:docker/" + ref.Path
+	if len(ref.Digest) > 0 {
+		purl += "@" + strings.ReplaceAll(ref.Digest, ":", "%3A")
+	} else if len(ref.Tag) > 0 {
+		purl += "@" + ref.Tag
+	}
+	var qualifiers []string
+	if ref.Domain != reference.DefaultDomain {
+		qualifiers = append(qualifiers, "repository_url="+ref.Domain)
+	}
+	if len(ref.Digest) > 0 && len(ref.Tag) > 0 {
+		qualifiers = append(qualifiers, "tag="+ref.Tag)
+	}
+	if len(qualifiers) > 0 {
+		purl += "?" + strings.Join(qualifiers, "&")
+	}
+	return purl
+}
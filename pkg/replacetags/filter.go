@@ -0,0 +1,264 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+When replacing tags on directory, only files selected by the filter are parsed, the rest, as well as binary files,
+are copied to output directory verbatim with their file mode. A file is parsed when:
+  - it matches one of Include globs, or Include is empty
+  - it does not match any of Exclude globs
+  - it is not ignored by .relizaignore in root of input directory
+
+Globs and .relizaignore patterns follow .gitignore rules: paths are relative to input directory with / separators,
+a pattern without / matches name at any depth, a pattern with / is anchored to input directory, * does not cross /,
+** matches any number of directories, trailing / only matches directories, and a matched directory applies to all its files.
+In .relizaignore, lines starting with # are comments, ! negates pattern and the last matching pattern wins.
+.git directories and .relizaignore itself are neither parsed nor copied.
+*/
+
+// RelizaIgnoreFile is read from root of input directory
+const RelizaIgnoreFile = ".relizaignore"
+
+// number of leading bytes checked for NUL byte to detect binary files, same as git
+const binaryCheckSize = 8000
+
+type globPattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+type fileFilter struct {
+	include []globPattern
+	exclude []globPattern
+	ignore  []globPattern
+}
+
+func newFileFilter(replaceTagsVars *ReplaceTagsVars, indir string) (*fileFilter, error) {
+	var ff fileFilter
+	var err error
+	if ff.include, err = compileGlobs(replaceTagsVars.Include); err != nil {
+		return nil, err
+	}
+	if ff.exclude, err = compileGlobs(replaceTagsVars.Exclude); err != nil {
+		return nil, err
+	}
+	ignoreFile, err := os.Open(filepath.Join(indir, RelizaIgnoreFile))
+	if os.IsNotExist(err) {
+		return &ff, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer ignoreFile.Close()
+	var patterns []string
+	scanner := bufio.NewScanner(ignoreFile)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if len(line) > 0 && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if ff.ignore, err = compileGlobs(patterns); err != nil {
+		return nil, errors.Wrapf(err, "error in %s", RelizaIgnoreFile)
+	}
+	return &ff, nil
+}
+
+// shouldParse reports whether file at path relative to input directory should be parsed
+func (ff *fileFilter) shouldParse(relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	if len(ff.include) > 0 && !matchesAny(ff.include, relPath) {
+		return false
+	}
+	if matchesAny(ff.exclude, relPath) {
+		return false
+	}
+	return !ff.isIgnored(relPath)
+}
+
+// isIgnored applies .relizaignore patterns to file and to each of its parent directories, last match wins
+func (ff *fileFilter) isIgnored(relPath string) bool {
+	ignored := false
+	parts := strings.Split(relPath, "/")
+	for i := range parts {
+		candidate := strings.Join(parts[:i+1], "/")
+		isDir := i < len(parts)-1
+		for _, gp := range ff.ignore {
+			if gp.matches(candidate, isDir) {
+				ignored = !gp.negate
+			}
+		}
+		if ignored && isDir {
+			// as in git, files of ignored directory can not be re-included
+			return true
+		}
+	}
+	return ignored
+}
+
+func matchesAny(patterns []globPattern, relPath string) bool {
+	parts := strings.Split(relPath, "/")
+	for i := range parts {
+		candidate := strings.Join(parts[:i+1], "/")
+		isDir := i < len(parts)-1
+		for _, gp := range patterns {
+			if gp.matches(candidate, isDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (gp globPattern) matches(relPath string, isDir bool) bool {
+	if gp.dirOnly && !isDir {
+		return false
+	}
+	return gp.re.MatchString(relPath)
+}
+
+func compileGlobs(patterns []string) ([]globPattern, error) {
+	var compiled []globPattern
+	for _, pattern := range patterns {
+		gp, err := compileGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, gp)
+	}
+	return compiled, nil
+}
+
+// compileGlob converts .gitignore style pattern to regular expression matched against relative path
+func compileGlob(pattern string) (globPattern, error) {
+	var gp globPattern
+	if strings.HasPrefix(pattern, "!") {
+		gp.negate = true
+		pattern = pattern[1:]
+	}
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if strings.HasSuffix(pattern, "/") {
+		gp.dirOnly = true
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if len(pattern) == 0 {
+		return gp, errors.New("empty glob pattern")
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return gp, errors.Errorf("invalid glob pattern %s: unterminated character class", pattern)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			re.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return gp, errors.Wrapf(err, "invalid glob pattern %s", pattern)
+	}
+	gp.re = compiled
+	return gp, nil
+}
+
+// isBinaryFile reports whether file looks like binary data, i.e. chart archive or image
+func isBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, binaryCheckSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return bytes.IndexByte(head[:n], 0) > -1, nil
+}
+
+// shouldParseFile combines filter with binary check, filter is applied first so that excluded files are not read
+func (ff *fileFilter) shouldParseFile(path string, relPath string) (bool, error) {
+	if !ff.shouldParse(relPath) {
+		return false, nil
+	}
+	binary, err := isBinaryFile(path)
+	return !binary, err
+}
+
+// copyFileVerbatim copies file preserving its mode
+func copyFileVerbatim(infile string, outfile string) error {
+	fileInfo, err := os.Stat(infile)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(infile)
+	if err != nil {
+		return errors.Wrapf(err, "error reading %s", infile)
+	}
+	if err := os.WriteFile(outfile, content, fileInfo.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "error writing %s", outfile)
+	}
+	// mode of existing file is not changed by WriteFile and new file mode is subject to umask
+	return os.Chmod(outfile, fileInfo.Mode().Perm())
+}
@@ -0,0 +1,358 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+/*
+JSON manifests (Kubernetes .json, ECS task definitions, Nomad JSON jobs) are detected by .json extension or by
+content which is a valid JSON object or array. Image references are looked up in string values at paths matching
+ReplaceTagsVars.JsonPaths, or, if no paths are set, in string values of keys ending with "image" as in ast mode.
+Path patterns are dot separated keys with array indexes, where * matches any key, [*] any index and ** any number
+of segments, i.e.
+
+	containerDefinitions[*].image
+	spec.template.spec.containers[*].image
+	Job.TaskGroups[*].Tasks[*].Config.image
+	**.image
+
+Matched string literals are replaced in the original text, so key order, indentation and the rest of the file are
+kept byte for byte. Provenance header is never added to JSON output since JSON does not allow comments.
+*/
+
+// isJsonInput returns true if file should be handled as JSON manifest
+func isJsonInput(fileName string, content []byte) bool {
+	if strings.EqualFold(filepath.Ext(fileName), ".json") {
+		return true
+	}
+	trimmed := bytes.TrimSpace(content)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	return json.Valid(trimmed)
+}
+
+// jsonString is a string value of JSON document with its location, start and end are byte offsets of the literal including quotes
+type jsonString struct {
+	path  []string
+	value string
+	start int
+	end   int
+	line  int
+}
+
+// jsonScanner collects string values of JSON document together with their paths
+type jsonScanner struct {
+	data    []byte
+	pos     int
+	strings []jsonString
+}
+
+func (fp *fileParser) parseJson(in io.Reader) ([]string, error) {
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, nil
+	}
+	// resolve secrets and properties per line, same as other modes
+	var resolvedLines []string
+	for lineindex, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		fp.line = lineindex + 1
+		resolvedLine, err := fp.resolveSecretsPropsInLine(line)
+		if err != nil {
+			return nil, err
+		}
+		resolvedLines = append(resolvedLines, resolvedLine)
+	}
+	data := []byte(strings.Join(resolvedLines, "\n"))
+
+	js := jsonScanner{data: data}
+	js.skipSpace()
+	if err := js.scanValue(nil); err != nil {
+		return nil, errors.Wrapf(err, "error parsing json in %s", fp.fileName)
+	}
+	js.skipSpace()
+	if js.pos < len(data) {
+		return nil, errors.Errorf("error parsing json in %s: unexpected data after top-level value at offset %d", fp.fileName, js.pos)
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, s := range js.strings {
+		if !fp.isJsonImagePath(s.path) {
+			continue
+		}
+		fp.line = s.line
+		image := strings.TrimSpace(s.value)
+		if len(image) == 0 {
+			continue
+		}
+		kvs, found := fp.findSubstitution(image)
+		if !found || len(kvs.Value.Digest) == 0 {
+			if fp.parseMode == "strict" {
+				return nil, &StrictModeError{File: fp.fileName, Line: strings.Join(s.path, ".") + ": " + s.value}
+			}
+			fp.recordUnresolved(s.value)
+			continue
+		}
+		replacement := GetDigestedImageFromSubstitution(kvs.Value)
+		fp.recordReplacement(s.value, replacement, kvs.Key)
+		out.Write(data[last:s.start])
+		out.WriteString(encodeJsonString(replacement))
+		last = s.end
+	}
+	out.Write(data[last:])
+	return strings.Split(out.String(), "\n"), nil
+}
+
+// isJsonImagePath checks path against configured patterns or, if there are none, checks that the last key ends with image
+func (fp *fileParser) isJsonImagePath(path []string) bool {
+	if len(fp.vars.JsonPaths) == 0 {
+		for i := len(path) - 1; i >= 0; i-- {
+			if !strings.HasPrefix(path[i], "[") {
+				return imageKeyRegex.MatchString(path[i])
+			}
+		}
+		return false
+	}
+	for _, pattern := range fp.vars.JsonPaths {
+		if matchJsonPath(splitJsonPath(pattern), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitJsonPath splits pattern such as $.containers[*].image into segments containers, [*], image
+func splitJsonPath(pattern string) []string {
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "$"), ".")
+	var segments []string
+	for _, part := range strings.Split(pattern, ".") {
+		for len(part) > 0 {
+			i := strings.Index(part, "[")
+			if i < 0 {
+				segments = append(segments, part)
+				break
+			}
+			if i > 0 {
+				segments = append(segments, part[:i])
+			}
+			j := strings.Index(part[i:], "]")
+			if j < 0 {
+				segments = append(segments, part[i:])
+				break
+			}
+			segments = append(segments, part[i:i+j+1])
+			part = part[i+j+1:]
+		}
+	}
+	return segments
+}
+
+func matchJsonPath(pattern []string, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchJsonPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	segment, p := pattern[0], path[0]
+	isIndex := strings.HasPrefix(p, "[")
+	switch {
+	case segment == "[*]":
+		if !isIndex {
+			return false
+		}
+	case segment == "*":
+		if isIndex {
+			return false
+		}
+	case segment != p:
+		return false
+	}
+	return matchJsonPath(pattern[1:], path[1:])
+}
+
+func encodeJsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (js *jsonScanner) skipSpace() {
+	for js.pos < len(js.data) {
+		switch js.data[js.pos] {
+		case ' ', '\t', '\n', '\r':
+			js.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (js *jsonScanner) errorf(format string, a ...interface{}) error {
+	return errors.Errorf("line %d: "+format, append([]interface{}{js.lineAt(js.pos)}, a...)...)
+}
+
+func (js *jsonScanner) lineAt(offset int) int {
+	return bytes.Count(js.data[:offset], []byte("\n")) + 1
+}
+
+func (js *jsonScanner) scanValue(path []string) error {
+	if js.pos >= len(js.data) {
+		return js.errorf("unexpected end of input")
+	}
+	switch c := js.data[js.pos]; {
+	case c == '{':
+		return js.scanObject(path)
+	case c == '[':
+		return js.scanArray(path)
+	case c == '"':
+		start := js.pos
+		value, err := js.scanString()
+		if err != nil {
+			return err
+		}
+		js.strings = append(js.strings, jsonString{path: append([]string{}, path...), value: value, start: start,
+			end: js.pos, line: js.lineAt(start)})
+		return nil
+	default:
+		// number, true, false or null
+		start := js.pos
+		for js.pos < len(js.data) && !strings.ContainsRune(",}] \t\r\n", rune(js.data[js.pos])) {
+			js.pos++
+		}
+		literal := js.data[start:js.pos]
+		if !json.Valid(literal) {
+			return js.errorf("invalid value %q", literal)
+		}
+		return nil
+	}
+}
+
+func (js *jsonScanner) scanObject(path []string) error {
+	js.pos++ // {
+	js.skipSpace()
+	if js.pos < len(js.data) && js.data[js.pos] == '}' {
+		js.pos++
+		return nil
+	}
+	for {
+		js.skipSpace()
+		if js.pos >= len(js.data) || js.data[js.pos] != '"' {
+			return js.errorf("expected object key")
+		}
+		key, err := js.scanString()
+		if err != nil {
+			return err
+		}
+		js.skipSpace()
+		if js.pos >= len(js.data) || js.data[js.pos] != ':' {
+			return js.errorf("expected ':' after object key")
+		}
+		js.pos++
+		js.skipSpace()
+		if err := js.scanValue(append(path, key)); err != nil {
+			return err
+		}
+		js.skipSpace()
+		if js.pos >= len(js.data) {
+			return js.errorf("unexpected end of input in object")
+		}
+		if js.data[js.pos] == '}' {
+			js.pos++
+			return nil
+		}
+		if js.data[js.pos] != ',' {
+			return js.errorf("expected ',' or '}' in object")
+		}
+		js.pos++
+	}
+}
+
+func (js *jsonScanner) scanArray(path []string) error {
+	js.pos++ // [
+	js.skipSpace()
+	if js.pos < len(js.data) && js.data[js.pos] == ']' {
+		js.pos++
+		return nil
+	}
+	for i := 0; ; i++ {
+		js.skipSpace()
+		if err := js.scanValue(append(path, "["+strconv.Itoa(i)+"]")); err != nil {
+			return err
+		}
+		js.skipSpace()
+		if js.pos >= len(js.data) {
+			return js.errorf("unexpected end of input in array")
+		}
+		if js.data[js.pos] == ']' {
+			js.pos++
+			return nil
+		}
+		if js.data[js.pos] != ',' {
+			return js.errorf("expected ',' or ']' in array")
+		}
+		js.pos++
+	}
+}
+
+// scanString reads string literal at current position and returns its decoded value
+func (js *jsonScanner) scanString() (string, error) {
+	start := js.pos
+	js.pos++ // opening quote
+	for js.pos < len(js.data) {
+		switch js.data[js.pos] {
+		case '\\':
+			js.pos += 2
+			continue
+		case '"':
+			js.pos++
+			var value string
+			if err := json.Unmarshal(js.data[start:js.pos], &value); err != nil {
+				return "", js.errorf("invalid string: %v", err)
+			}
+			return value, nil
+		}
+		_, size := utf8.DecodeRune(js.data[js.pos:])
+		js.pos += size
+	}
+	return "", js.errorf("unterminated string")
+}
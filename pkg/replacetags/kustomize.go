@@ -0,0 +1,311 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+	"go.yaml.in/yaml/v3"
+)
+
+/*
+Kustomize mode writes images transformer list of kustomization file instead of rewriting manifests:
+
+	images:
+	  - name: taleodor/mafia-express
+	    newTag: 21.08.3
+	    digest: sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d
+
+Images are taken from existing entries of the list, from image references found in local resources, components
+and bases listed by the kustomization file (local directories are followed into their kustomization files) and from
+definition reference file. Remote bases are not fetched, so for them output of kustomize build should be passed
+as definition reference file.
+Names are kept as they are written in manifests since kustomize matches them literally, while matching against
+substitution map is done on normalized names, so redis matches docker.io/library/redis.
+*/
+
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// ErrNoKustomization is returned when kustomization directory does not contain kustomization file
+var ErrNoKustomization = errors.New("kustomization file not found")
+
+// FindKustomizationFile returns path of kustomization file in dir, dir may also point to the file itself
+func FindKustomizationFile(dir string) (string, error) {
+	fileInfo, err := os.Stat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !fileInfo.IsDir() {
+		return dir, nil
+	}
+	for _, name := range kustomizationFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", errors.Wrapf(ErrNoKustomization, "directory %s", dir)
+}
+
+// prepareKustomizationVars points infile to kustomization file, which is also used as outfile unless it is set
+func prepareKustomizationVars(replaceTagsVars *ReplaceTagsVars) error {
+	if len(replaceTagsVars.Infile) > 0 || len(replaceTagsVars.Indirectory) > 0 {
+		return ErrInvalidInput
+	}
+	kustomizationFile, err := FindKustomizationFile(replaceTagsVars.Kustomization)
+	if err != nil {
+		return err
+	}
+	replaceTagsVars.Infile = kustomizationFile
+	if len(replaceTagsVars.Outfile) < 1 {
+		replaceTagsVars.Outfile = kustomizationFile
+	}
+	return nil
+}
+
+// kustomizeImage is an entry of images transformer list
+type kustomizeImage struct {
+	node   *yaml.Node
+	name   string
+	target string // newName if set, otherwise name
+}
+
+func renderKustomization(replaceTagsVars *ReplaceTagsVars, substitutionMap map[string]Substitution) (string, error) {
+	infile := replaceTagsVars.Infile
+	inContent, err := os.ReadFile(infile)
+	if err != nil {
+		return "", errors.Wrapf(err, "error opening kustomization: %s", infile)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(stripProvenance(string(inContent))), &doc); err != nil {
+		return "", errors.Wrapf(err, "error parsing yaml in %s", infile)
+	}
+	if doc.Kind == 0 {
+		// empty kustomization
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return "", errors.Errorf("kustomization %s must be a mapping", infile)
+	}
+	imagesNode := mappingValue(root, "images")
+	if imagesNode == nil {
+		imagesNode = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "images"}, imagesNode)
+	} else if imagesNode.Kind != yaml.SequenceNode {
+		return "", errors.Errorf("images of kustomization %s must be a list", infile)
+	}
+
+	var images []kustomizeImage
+	known := map[string]bool{}
+	for _, entry := range imagesNode.Content {
+		if entry.Kind != yaml.MappingNode {
+			continue
+		}
+		ki := kustomizeImage{node: entry}
+		if nameNode := mappingValue(entry, "name"); nameNode != nil {
+			ki.name = nameNode.Value
+		}
+		ki.target = ki.name
+		if newNameNode := mappingValue(entry, "newName"); newNameNode != nil && len(newNameNode.Value) > 0 {
+			ki.target = newNameNode.Value
+		}
+		known[ki.name] = true
+		images = append(images, ki)
+	}
+
+	referencedNames, err := kustomizationImageNames(replaceTagsVars)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range referencedNames {
+		if known[name] {
+			continue
+		}
+		known[name] = true
+		images = append(images, kustomizeImage{name: name, target: name})
+	}
+
+	fp := fileParser{vars: replaceTagsVars, fileName: infile, sortedSubstitutions: sortSubstitutionMap(substitutionMap)}
+	for _, ki := range images {
+		kvs, found := fp.findSubstitution(ki.target)
+		if ki.node != nil {
+			fp.line = ki.node.Line
+		}
+		if !found {
+			if ki.node != nil {
+				fp.recordUnresolved(ki.target)
+			}
+			continue
+		}
+		if ki.node == nil {
+			ki.node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(ki.node, "name", ki.name)
+			imagesNode.Content = append(imagesNode.Content, ki.node)
+		}
+		if !isImageMatchingSubstitutionKey(ki.target, GetMatchingKeyFromSubstitution(kvs.Value)) {
+			setMappingValue(ki.node, "newName", GetMatchingKeyFromSubstitution(kvs.Value))
+		}
+		if len(kvs.Value.Tag) > 0 {
+			setMappingValue(ki.node, "newTag", kvs.Value.Tag)
+		}
+		if len(kvs.Value.Digest) > 0 {
+			setMappingValue(ki.node, "digest", kvs.Value.Digest)
+		}
+		fp.recordReplacement(ki.target, GetDigestedImageFromSubstitution(kvs.Value), kvs.Key)
+	}
+	policyErr := fp.checkPolicy()
+	if replaceTagsVars.Report != nil {
+		fp.report.Infile = infile
+		fp.report.Outfile = replaceTagsVars.Outfile
+		replaceTagsVars.Report.addFile(fp.report)
+	}
+	if policyErr != nil {
+		return "", policyErr
+	}
+
+	var out bytes.Buffer
+	if !replaceTagsVars.ForDiff && replaceTagsVars.Provenance {
+		out.WriteString(provenanceHeader(replaceTagsVars))
+	}
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// kustomizationImageNames returns names of images, as written, referenced by local resources of kustomization file and by definition reference file
+func kustomizationImageNames(replaceTagsVars *ReplaceTagsVars) ([]string, error) {
+	sources, err := kustomizationResourceFiles(replaceTagsVars.Infile, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if len(replaceTagsVars.DefinitionReferenceFile) > 0 {
+		sources = append(sources, replaceTagsVars.DefinitionReferenceFile)
+	}
+
+	// kustomize matches names literally, so redis and docker.io/library/redis need separate entries
+	namesSet := map[string]bool{}
+	for _, source := range sources {
+		sourceFile, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		err = scanDefinitionImages(sourceFile, func(image string) {
+			if ref, err := reference.Parse(image); err == nil {
+				namesSet[ref.Name()] = true
+			}
+		})
+		sourceFile.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "error scanning %s", source)
+		}
+	}
+	var names []string
+	for name := range namesSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// kustomizationResourceFiles returns local files listed in resources, components and bases of kustomization file,
+// local directories are followed into their kustomization files. Remote resources are skipped
+func kustomizationResourceFiles(kustomizationFile string, visited map[string]bool) ([]string, error) {
+	if visited[kustomizationFile] {
+		return nil, nil
+	}
+	visited[kustomizationFile] = true
+	content, err := os.ReadFile(kustomizationFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening kustomization: %s", kustomizationFile)
+	}
+	var kustomization struct {
+		Resources  []string `yaml:"resources"`
+		Components []string `yaml:"components"`
+		Bases      []string `yaml:"bases"`
+	}
+	if err := yaml.Unmarshal([]byte(stripProvenance(string(content))), &kustomization); err != nil {
+		return nil, errors.Wrapf(err, "error parsing yaml in %s", kustomizationFile)
+	}
+
+	var files []string
+	dir := filepath.Dir(kustomizationFile)
+	for _, resource := range append(append(kustomization.Resources, kustomization.Components...), kustomization.Bases...) {
+		if strings.Contains(resource, "://") {
+			continue
+		}
+		path := resource
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			// remote resource without scheme, i.e. github.com/org/repo//base?ref=v1
+			continue
+		}
+		if !fileInfo.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		nested, err := FindKustomizationFile(path)
+		if err != nil {
+			continue
+		}
+		nestedFiles, err := kustomizationResourceFiles(nested, visited)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, nestedFiles...)
+	}
+	return files, nil
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, key string, value string) {
+	if valueNode := mappingValue(node, key); valueNode != nil {
+		valueNode.Kind = yaml.ScalarNode
+		valueNode.Tag = "!!str"
+		valueNode.Value = value
+		valueNode.Content = nil
+		valueNode.Style &^= yaml.LiteralStyle | yaml.FoldedStyle
+		return
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+}
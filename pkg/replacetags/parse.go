@@ -0,0 +1,657 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+)
+
+// fileParser holds state required to substitute tags, properties and secrets in a single file
+type fileParser struct {
+	ctx                 context.Context // used to resolve plain secrets, may be nil when placeholders are not resolved
+	vars                *ReplaceTagsVars
+	parseMode           string
+	fileName            string
+	sortedSubstitutions []KeyValueSorted
+	resolvedProperties  map[string]string
+	resolvedSecrets     map[string]hub.ResolvedSecret
+	line                int // 1-based number of the input line being parsed, used in report
+	report              FileReport
+	keepPlaceholders    bool // leave $RELIZA{...} placeholders as is, i.e. when only images are extracted
+}
+
+/*
+sort map by length of keys to always prefer longer matches
+*/
+func sortSubstitutionMap(substitutionMap map[string]Substitution) []KeyValueSorted {
+	var sortedSubstitutions []KeyValueSorted
+	for k, v := range substitutionMap {
+		kvs := KeyValueSorted{Key: k, Value: v, Length: len(k)}
+		sortedSubstitutions = append(sortedSubstitutions, kvs)
+	}
+	// keys of equal length are ordered lexically so that output does not depend on map iteration order
+	sort.SliceStable(sortedSubstitutions, func(i, j int) bool {
+		if sortedSubstitutions[i].Length != sortedSubstitutions[j].Length {
+			return sortedSubstitutions[i].Length > sortedSubstitutions[j].Length
+		}
+		return sortedSubstitutions[i].Key < sortedSubstitutions[j].Key
+	})
+	return sortedSubstitutions
+}
+
+/*
+This function takes as input a reader of the infile, substitutionMap and replace tags vars.
+The contents of the inFile will be read and parsed according to the mappings of substitutionMap.
+The output of the function is a slice of strings each representing a line to be written to
+the CLI output (either outfile or stdout). If the inFile cannot be parsed for any reason
+(ex: strict mode), then an error is returned.
+
+There are five modes for parsing input files: simple, extended, strict, ast and compose (default = "extended")
+"simple"   mode: only replaces 'image' keys (suitable for k8s templates or compose files)
+"extended" mode: replaces all keys present in substitution map (needed for helm values files)
+"strict"   mode: if artifact is not found upstream, parsing fails
+"ast"      mode: parses yaml into node tree and rewrites matched scalars only, preserving formatting (see ast.go)
+"compose"  mode: same as ast, but only for image keys of docker compose services with env interpolation (see compose.go)
+
+JSON manifests are detected by extension or content and handled by path in every mode except compose (see json.go).
+
+resolvedSp - result of resolving secrets and properties on the instance, if applicable
+*/
+func substituteCopyBasedOnMap(ctx context.Context, in io.Reader, inFileName string, substitutionMap map[string]Substitution, replaceTagsVars *ReplaceTagsVars, resolvedSp hub.SecretPropsRHResp) ([]string, error) {
+	fp := fileParser{
+		ctx:                ctx,
+		vars:               replaceTagsVars,
+		fileName:           inFileName,
+		resolvedProperties: map[string]string{},
+		resolvedSecrets:    map[string]hub.ResolvedSecret{},
+		keepPlaceholders:   replaceTagsVars.keepPlaceholders,
+	}
+
+	for _, rpr := range resolvedSp.Responsewrapper.Properties {
+		fp.resolvedProperties[rpr.Key] = rpr.Value
+	}
+
+	for _, rsr := range resolvedSp.Responsewrapper.Secrets {
+		fp.resolvedSecrets[rsr.Key] = rsr
+	}
+
+	parseMode, err := normalizeParseMode(replaceTagsVars.ParseMode)
+	if err != nil {
+		return nil, err
+	}
+	fp.parseMode = parseMode
+
+	fp.sortedSubstitutions = sortSubstitutionMap(substitutionMap)
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	parsedLines, err := fp.parseContent(content)
+	if err != nil {
+		return nil, err
+	}
+	if parsedLines == nil {
+		return nil, errors.Errorf("failed to parse empty/non-existent input file: %s", inFileName)
+	}
+	policyErr := fp.checkPolicy()
+	if replaceTagsVars.Report != nil {
+		fp.report.Infile = inFileName
+		fp.report.Outfile = replaceTagsVars.Outfile
+		replaceTagsVars.Report.addFile(fp.report)
+	}
+	if policyErr != nil {
+		return nil, policyErr
+	}
+	return parsedLines, nil
+}
+
+// parseContent parses content of the file according to parse mode, JSON is detected by file name or content
+func (fp *fileParser) parseContent(content []byte) ([]string, error) {
+	in := bytes.NewReader(content)
+	if fp.parseMode != "compose" && isJsonInput(fp.fileName, content) {
+		return fp.parseJson(in)
+	} else if fp.parseMode == "ast" {
+		return fp.parseAst(in)
+	} else if fp.parseMode == "compose" {
+		return fp.parseCompose(in)
+	}
+	return fp.parseLines(in)
+}
+
+func normalizeParseMode(parseMode string) (string, error) {
+	parseMode = strings.ToLower(parseMode)
+	if len(parseMode) < 1 {
+		parseMode = "extended"
+	}
+	if parseMode != "simple" && parseMode != "extended" && parseMode != "strict" && parseMode != "ast" && parseMode != "compose" {
+		return "", &InvalidParseModeError{Mode: parseMode}
+	}
+	return parseMode, nil
+}
+
+func (fp *fileParser) parseLines(in io.Reader) ([]string, error) {
+	var parsedLines []string
+
+	inScanner := bufio.NewScanner(in)
+	establishedWhiteSpacePrefix := 0
+
+	var bitnamiLineCache []string
+	bitnamiStartLine := 0
+	// indentation of images: key whose list items are being parsed, -1 if not in such list
+	imageListIndent := -1
+	lineindex := 0
+	for inScanner.Scan() {
+		line := inScanner.Text()
+		fp.line = lineindex + 1
+		isBitnamiImageStart, whiteSpacePrefix := isBitnamiImageStart(line)
+		if (lineindex == 0 && strings.HasPrefix(line, "# Tags replaced with Reliza CLI")) || (lineindex == 1 && strings.HasPrefix(line, "# According to")) {
+			// do nothing
+		} else if len(bitnamiLineCache) == 0 && imageListIndent > -1 && isImageListItem(line, imageListIndent) {
+			parsedLine, err := fp.parseImageListItem(line)
+			if err != nil {
+				return nil, err
+			}
+			parsedLines = append(parsedLines, parsedLine)
+		} else if isBitnamiImageStart {
+			establishedWhiteSpacePrefix = whiteSpacePrefix + 2
+			bitnamiStartLine = fp.line
+			bitnamiLineCache = append(bitnamiLineCache, line)
+		} else if len(bitnamiLineCache) > 0 && IsInBitnamiParse(line, establishedWhiteSpacePrefix) {
+			bitnamiLineCache = append(bitnamiLineCache, line)
+		} else {
+			if len(bitnamiLineCache) > 0 {
+				parsedBitnamiLines, err := fp.parseBitnamiLines(bitnamiLineCache, bitnamiStartLine)
+				if err != nil {
+					return nil, err
+				}
+				parsedLines = append(parsedLines, parsedBitnamiLines...)
+				bitnamiLineCache = []string{}
+				fp.line = lineindex + 1
+			}
+			if isDocumentSeparator(line) {
+				imageListIndent = -1
+			} else if indent, ok := isImageListStart(line); ok {
+				imageListIndent = indent
+			} else if imageListIndent > -1 && !isBlankOrComment(line) {
+				imageListIndent = -1
+			}
+			parsedLine, err := fp.parseLineOnScan(line)
+			if err != nil {
+				return nil, err
+			}
+			parsedLines = append(parsedLines, parsedLine)
+		}
+		lineindex++
+	}
+	if err := inScanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(bitnamiLineCache) > 0 {
+		parsedBitnamiLines, err := fp.parseBitnamiLines(bitnamiLineCache, bitnamiStartLine)
+		if err != nil {
+			return nil, err
+		}
+		parsedLines = append(parsedLines, parsedBitnamiLines...)
+	}
+	return parsedLines, nil
+}
+
+func (fp *fileParser) parseBitnamiLines(bitnamiLineCache []string, startLine int) ([]string, error) {
+	parsedLines, isBitnami, match := validateAndParseBitnamiLines(bitnamiLineCache, fp.sortedSubstitutions)
+	if isBitnami {
+		fp.line = startLine
+		for _, field := range []*string{&match.original.Registry, &match.original.Image, &match.original.Tag, &match.original.Digest} {
+			*field = strings.Trim(*field, "\"'")
+		}
+		original := GetDigestedImageFromSubstitution(match.original)
+		if match.found {
+			fp.recordReplacement(original, GetDigestedImageFromSubstitution(match.kvs.Value), match.kvs.Key)
+		} else {
+			fp.recordUnresolved(original)
+		}
+	} else {
+		parsedLines = []string{}
+		for i, blc := range bitnamiLineCache {
+			fp.line = startLine + i
+			line, err := fp.parseLineOnScan(blc)
+			if err != nil {
+				return nil, err
+			}
+			parsedLines = append(parsedLines, line)
+		}
+	}
+	return parsedLines, nil
+}
+
+/*
+*
+Sample non-merged:
+
+	image:
+	  registry: docker.io
+	  repository: taleodor/mafia-express
+	  tag: latest
+	  digest: ""
+
+Sample merged:
+
+	image:
+	  debug: false
+	  digest: ""
+	  pullPolicy: IfNotPresent
+	  pullSecrets: []
+	  registry: docker.io
+	  repository: library/redis
+	  tag: latest
+*/
+func validateAndParseBitnamiLines(bitnamiLineCache []string, sortedSubstitutions []KeyValueSorted) ([]string, bool, bitnamiMatch) {
+	var parsedLines []string
+	var bitnamiSubst Substitution
+	var match bitnamiMatch
+
+	bitnamiCheckMap := map[string]bool{}
+	isBitnami := true
+	isTagAsDigest := true
+
+	if len(bitnamiLineCache) < 5 {
+		isBitnami = false
+	}
+
+	if len(bitnamiLineCache) < 4 {
+		isTagAsDigest = false
+	}
+
+	if isBitnami || isTagAsDigest {
+		for _, line := range bitnamiLineCache {
+			trimmedLine := strings.Trim(line, " ")
+			if strings.HasPrefix(trimmedLine, "registry: ") {
+				bitnamiCheckMap["registry"] = true
+				bitnamiSubst.Registry = strings.ReplaceAll(trimmedLine, "registry: ", "")
+			} else if strings.HasPrefix(trimmedLine, "repository: ") {
+				bitnamiCheckMap["repository"] = true
+				bitnamiSubst.Image = strings.ReplaceAll(trimmedLine, "repository: ", "")
+			} else if strings.HasPrefix(trimmedLine, "tag: ") {
+				bitnamiCheckMap["tag"] = true
+				bitnamiSubst.Tag = strings.ReplaceAll(trimmedLine, "tag: ", "")
+			} else if strings.HasPrefix(trimmedLine, "digest: ") {
+				bitnamiCheckMap["digest"] = true
+				bitnamiSubst.Digest = strings.ReplaceAll(trimmedLine, "digest: ", "")
+			}
+		}
+	}
+
+	if isBitnami && len(bitnamiCheckMap) < 4 {
+		isBitnami = false
+	}
+
+	if isTagAsDigest && len(bitnamiCheckMap) < 3 {
+		isTagAsDigest = false
+	}
+
+	if isBitnami || isTagAsDigest {
+		matchKey := GetMatchingKeyFromSubstitution(bitnamiSubst)
+		var replacedSubst Substitution
+		match.original = bitnamiSubst
+		for _, kvs := range sortedSubstitutions {
+			k := kvs.Key
+			if isImageMatchingSubstitutionKey(matchKey, k) {
+				replacedSubst = kvs.Value
+				match.kvs = kvs
+				break
+			}
+		}
+
+		if len(replacedSubst.Digest) > 0 {
+			match.found = true
+			for _, line := range bitnamiLineCache {
+				trimmedLine := strings.Trim(line, " ")
+				if strings.HasPrefix(trimmedLine, "registry: ") {
+					lineSplit := strings.Split(line, ": ")
+					parsedLines = append(parsedLines, lineSplit[0]+": "+replacedSubst.Registry)
+				} else if strings.HasPrefix(trimmedLine, "repository: ") {
+					lineSplit := strings.Split(line, ": ")
+					parsedLines = append(parsedLines, lineSplit[0]+": "+replacedSubst.Image)
+				} else if strings.HasPrefix(trimmedLine, "tag: ") {
+					lineSplit := strings.Split(line, ": ")
+					if isBitnami {
+						parsedLines = append(parsedLines, lineSplit[0]+": "+replacedSubst.Tag)
+					} else if isTagAsDigest {
+						parsedLines = append(parsedLines, lineSplit[0]+": "+replacedSubst.Digest)
+					}
+				} else if strings.HasPrefix(trimmedLine, "digest: ") {
+					lineSplit := strings.Split(line, ": ")
+					parsedLines = append(parsedLines, lineSplit[0]+": "+replacedSubst.Digest)
+				} else {
+					parsedLines = append(parsedLines, line)
+				}
+			}
+		}
+	}
+
+	if (!isBitnami && !isTagAsDigest) || !match.found {
+		// not a bitnami block or no substitution found, leave block as is
+		parsedLines = bitnamiLineCache
+	}
+
+	return parsedLines, isBitnami || isTagAsDigest, match
+}
+
+// bitnamiMatch describes image of a bitnami block and substitution it was matched to, if found
+type bitnamiMatch struct {
+	original Substitution
+	kvs      KeyValueSorted
+	found    bool
+}
+
+// isImageMatchingSubstitutionKey compares normalized names of image and key, so that i.e. redis matches docker.io/library/redis
+func isImageMatchingSubstitutionKey(image string, substKey string) bool {
+	imageRef, err := reference.ParseNormalized(image)
+	if err != nil {
+		return false
+	}
+	keyRef, err := reference.ParseNormalized(substKey)
+	if err != nil {
+		return false
+	}
+	return imageRef.Name() == keyRef.Name()
+}
+
+/*
+*
+Returns true if is start and returns number of whitespace before image
+*/
+func isBitnamiImageStart(line string) (bool, int) {
+	isBitnamiImageStart := false
+	if strings.Trim(line, " ") == "image:" {
+		isBitnamiImageStart = true
+	}
+	whiteSpacePrefix := strings.Split(line, "image:")[0]
+	return isBitnamiImageStart, len(whiteSpacePrefix)
+}
+
+func IsInBitnamiParse(line string, whitespacePrefix int) bool {
+	isInParse := false
+	re := regexp.MustCompile(`^\s{` + strconv.Itoa(whitespacePrefix) + `}[^\s]`)
+	if re.MatchString(line) {
+		isInParse = true
+	}
+	return isInParse
+}
+
+var simpleImageLineRegex = regexp.MustCompile(`^\s*image:`)
+var imageLineRegex = regexp.MustCompile(`(?i)^\s*(?:-\s+)?image:`)
+
+// imageToken is a candidate image reference found in a line, start and end are byte offsets
+type imageToken struct {
+	text  string
+	start int
+	end   int
+}
+
+// imageTokens splits line into candidate image references on whitespace, quotes and separators used around
+// images in yaml, shell and env files, i.e. image: "nginx:1.25", --image=nginx or [nginx, redis], comments are skipped
+func imageTokens(line string) []imageToken {
+	var tokens []imageToken
+	start := -1
+	for i := 0; i <= len(line); i++ {
+		if i < len(line) && !strings.ContainsRune(" \t\"'=,[](){}", rune(line[i])) {
+			if start < 0 {
+				if line[i] == '#' {
+					// comment till the end of line
+					break
+				}
+				start = i
+			}
+			continue
+		}
+		if start > -1 {
+			tokens = append(tokens, imageToken{text: line[start:i], start: start, end: i})
+			start = -1
+		}
+	}
+	return tokens
+}
+
+// findNormalizedSubstitution finds substitution which key has the same normalized name as ref, longer keys are preferred
+func (fp *fileParser) findNormalizedSubstitution(ref reference.Reference) (KeyValueSorted, bool) {
+	for _, kvs := range fp.sortedSubstitutions {
+		keyRef, err := reference.ParseNormalized(kvs.Key)
+		if err == nil && keyRef.Name() == ref.Name() {
+			return kvs, true
+		}
+	}
+	return KeyValueSorted{}, false
+}
+
+func (fp *fileParser) parseLineOnScan(line string) (string, error) {
+	// resolve props and secrets first
+	line, err := fp.resolveSecretsPropsInLine(line)
+	if err != nil {
+		return "", err
+	}
+
+	matchFound := false // flag used for strict mode to indicate if we fail to find image match (for strict mode)
+
+	// if simple mode, only substitute if line begins with 'image:' key
+	if fp.parseMode != "simple" || simpleImageLineRegex.MatchString(line) {
+		isImageLine := imageLineRegex.MatchString(line)
+		for _, token := range imageTokens(line) {
+			ref, err := reference.ParseNormalized(token.text)
+			if err != nil {
+				continue
+			}
+			if !strings.ContainsAny(token.text, "/:@") && !isImageLine {
+				// avoid matching arbitrary words such as redis to docker.io/library/redis
+				continue
+			}
+			kvs, ok := fp.findNormalizedSubstitution(ref)
+			if !ok {
+				continue
+			}
+			v := GetDigestedImageFromSubstitution(kvs.Value)
+			matchFound = true
+			fp.recordReplacement(token.text, v, kvs.Key)
+			line = line[:token.start] + v + line[token.end:]
+			break
+		}
+	}
+
+	// strict mode: if line has an image tag, but no matching key found in substitution map, fail
+	if !matchFound && fp.parseMode == "strict" && imageLineRegex.MatchString(line) {
+		return "", &StrictModeError{File: fp.fileName, Line: strings.TrimSpace(line)}
+	}
+	if !matchFound && imageLineRegex.MatchString(line) {
+		if image := strings.Trim(strings.TrimSpace(strings.SplitN(line, ":", 2)[1]), "\"'"); len(image) > 0 {
+			fp.recordUnresolved(image)
+		}
+	}
+	return line, nil
+}
+
+var imageListStartRegex = regexp.MustCompile(`(?i)^(\s*)(?:-\s+)?[\w.-]*images:\s*(?:#.*)?$`)
+var imageListItemRegex = regexp.MustCompile(`^(\s*)-\s+([^\s#][^#]*?)(\s+#.*)?$`)
+
+// isImageListStart checks for key ending with images without value, i.e. images: or sidecarImages:, and returns its indentation
+func isImageListStart(line string) (int, bool) {
+	m := imageListStartRegex.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	return len(m[1]), true
+}
+
+// isImageListItem checks for scalar list item which is not less indented than images key, list items may be on the same level as key
+func isImageListItem(line string, indent int) bool {
+	m := imageListItemRegex.FindStringSubmatch(line)
+	if m == nil || len(m[1]) < indent {
+		return false
+	}
+	value := strings.Trim(m[2], "\"'")
+	// mapping items such as - name: nginx are not images
+	return !strings.Contains(value, ": ") && !strings.HasSuffix(value, ":")
+}
+
+func isDocumentSeparator(line string) bool {
+	return line == "---" || line == "..." || strings.HasPrefix(line, "--- ")
+}
+
+func isBlankOrComment(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return len(trimmed) == 0 || strings.HasPrefix(trimmed, "#")
+}
+
+// parseImageListItem replaces image reference of list item under images key, quotes and trailing comment are kept
+func (fp *fileParser) parseImageListItem(line string) (string, error) {
+	line, err := fp.resolveSecretsPropsInLine(line)
+	if err != nil {
+		return "", err
+	}
+	m := imageListItemRegex.FindStringSubmatch(line)
+	if m == nil {
+		return line, nil
+	}
+	value := m[2]
+	quote := ""
+	if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		quote = value[:1]
+		value = value[1 : len(value)-1]
+	}
+	kvs, found := fp.findSubstitution(value)
+	if !found || len(kvs.Value.Digest) == 0 {
+		if fp.parseMode == "strict" {
+			return "", &StrictModeError{File: fp.fileName, Line: strings.TrimSpace(line)}
+		}
+		fp.recordUnresolved(value)
+		return line, nil
+	}
+	replacement := GetDigestedImageFromSubstitution(kvs.Value)
+	fp.recordReplacement(value, replacement, kvs.Key)
+	prefix := line[:strings.Index(line, m[2])]
+	return prefix + quote + replacement + quote + m[3], nil
+}
+
+func (fp *fileParser) resolveSecretsPropsInLine(line string) (string, error) {
+	if fp.keepPlaceholders || !strings.Contains(line, placeholderPrefix) {
+		return line, nil
+	}
+	segments, err := tokenizePlaceholders(line)
+	if err != nil {
+		return "", withPlaceholderLocation(err, fp.fileName, fp.line)
+	}
+	var resolved strings.Builder
+	for _, segment := range segments {
+		if segment.placeholder == nil {
+			resolved.WriteString(segment.literal)
+			continue
+		}
+		psp := *segment.placeholder
+		value, err := fp.resolvePlaceholder(psp)
+		if err != nil {
+			return "", err
+		}
+		value, err = applyPlaceholderFilters(value, psp.Filters)
+		if err != nil {
+			return "", errors.Wrapf(err, "placeholder %s in %s line %d", psp.Wholetext, fp.fileName, fp.line)
+		}
+		resolved.WriteString(value)
+	}
+	return resolved.String(), nil
+}
+
+// resolvePlaceholder returns value of placeholder before filters are applied and records it in report. Default
+// applies to this placeholder only, other placeholders with the same key may have different defaults.
+func (fp *fileParser) resolvePlaceholder(psp PropSecretParse) (string, error) {
+	var value string
+	var exists, usedDefault bool
+	switch psp.Type {
+	case "PROPERTY":
+		value, exists = fp.resolvedProperties[psp.Key]
+	case "ENV":
+		if fp.vars.LookupEnv != nil {
+			value, exists = fp.vars.LookupEnv(psp.Key)
+		}
+	}
+	if (psp.Type == "PROPERTY" || psp.Type == "ENV") && len(value) < 1 && psp.HasDefault {
+		value, exists, usedDefault = psp.Default, true, true
+	}
+	fp.report.Placeholders = append(fp.report.Placeholders, PlaceholderEntry{Line: fp.line, Type: psp.Type, Key: psp.Key,
+		UsedDefault: usedDefault})
+
+	// locate value corresponding to key
+	switch psp.Type {
+	case "PROPERTY":
+		if !exists {
+			return "", &MissingPropertyError{Key: psp.Key, File: fp.fileName}
+		}
+		return value, nil
+	case "ENV":
+		if !exists {
+			return "", &MissingEnvError{Key: psp.Key, File: fp.fileName}
+		}
+		return value, nil
+	}
+	rs, isSecretExists := fp.resolvedSecrets[psp.Key]
+	if !isSecretExists {
+		return "", &MissingSecretError{Key: psp.Key, File: fp.fileName}
+	}
+	if fp.vars.ForDiff {
+		return fmt.Sprintf("%d", rs.Timestamp), nil
+	} else if psp.Type == "SECRET" {
+		return rs.Secret, nil
+	}
+	if fp.vars.PlainSecretResolver == nil {
+		return "", errors.Errorf("plain secret %s can not be resolved, no plain secret resolver configured", psp.Key)
+	}
+	plainSecret, err := fp.vars.PlainSecretResolver(fp.ctx, rs.Secret, secretNamespace(fp.vars.Namespace))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve plain secret %s", psp.Key)
+	}
+	return plainSecret, nil
+}
+
+// findSubstitution returns substitution matching image, longer keys are preferred
+func (fp *fileParser) findSubstitution(image string) (KeyValueSorted, bool) {
+	matchKey := GetMatchingKeyFromSubstitution(GetSubstitutionFromDigestedString(image))
+	for _, kvs := range fp.sortedSubstitutions {
+		if isImageMatchingSubstitutionKey(matchKey, kvs.Key) {
+			return kvs, true
+		}
+	}
+	return KeyValueSorted{}, false
+}
+
+func (fp *fileParser) recordReplacement(original string, replacement string, substitutionKey string) {
+	fp.report.Replacements = append(fp.report.Replacements, ReplacementEntry{Line: fp.line, Original: original,
+		Replacement: replacement, SubstitutionKey: substitutionKey})
+}
+
+func (fp *fileParser) recordUnresolved(reference string) {
+	fp.report.Unresolved = append(fp.report.Unresolved, UnresolvedImage{Line: fp.line, Reference: reference})
+}
@@ -0,0 +1,290 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Placeholders are resolved by replacetags in every parsed line, their grammar is:
+
+	placeholder = "$RELIZA{" source "." key [ ":" default ] { "|" filter [ argument ] } "}"
+	source      = "PROPERTY" | "SECRET" | "PLAINSECRET" | "ENV"
+	default     = '"' double quoted, \" and \\ are escapes '"' | "'" single quoted, no escapes "'" | unquoted
+
+Unquoted default runs up to the next | or } and is taken literally, so it may contain colons and backslashes,
+i.e. URLs or Windows paths, defaults containing | or } must be quoted. Spaces are allowed around | and filter
+arguments. $$RELIZA{ is written as $RELIZA{ without being resolved. Text starting with $RELIZA{ which is not
+followed by one of the sources, i.e. $RELIZA{...} in comments, is left as is, while malformed placeholders of
+known sources are errors. Filters are applied to resolved value in order:
+
+	base64    - base64 encode
+	b64dec    - base64 decode
+	quote     - wrap in double quotes with escaping, as helm quote does
+	upper     - upper case
+	lower     - lower case
+	trim      - remove leading and trailing whitespace
+	indent N  - prefix every line with N spaces
+*/
+
+const placeholderPrefix = "$RELIZA{"
+
+var placeholderSources = []string{"PROPERTY", "SECRET", "PLAINSECRET", "ENV"}
+
+// PlaceholderFilter is a filter applied to resolved value of placeholder, Arg is empty if filter has no argument
+type PlaceholderFilter struct {
+	Name string
+	Arg  string
+}
+
+// PlaceholderSyntaxError is returned when $RELIZA{...} placeholder is malformed, column is 1-based and
+// points to the character where parsing failed
+type PlaceholderSyntaxError struct {
+	File        string
+	Line        int
+	Column      int
+	Placeholder string
+	Reason      string
+}
+
+func (e *PlaceholderSyntaxError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+	}
+	return fmt.Sprintf("malformed placeholder %s at %s:%d: %s", e.Placeholder, location, e.Column, e.Reason)
+}
+
+// placeholderSegment is either literal text of line or a placeholder
+type placeholderSegment struct {
+	literal     string
+	placeholder *PropSecretParse
+}
+
+// tokenizePlaceholders splits line into literal text and placeholders, escaped placeholders become literal text
+func tokenizePlaceholders(line string) ([]placeholderSegment, error) {
+	var segments []placeholderSegment
+	var literal strings.Builder
+	for i := 0; i < len(line); {
+		if strings.HasPrefix(line[i:], "$"+placeholderPrefix) {
+			literal.WriteString(placeholderPrefix)
+			i += 1 + len(placeholderPrefix)
+			continue
+		}
+		if !strings.HasPrefix(line[i:], placeholderPrefix) {
+			literal.WriteByte(line[i])
+			i++
+			continue
+		}
+		p := placeholderParser{line: line, start: i, pos: i + len(placeholderPrefix)}
+		if !p.knownSource() {
+			literal.WriteString(placeholderPrefix)
+			i += len(placeholderPrefix)
+			continue
+		}
+		psp, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		if literal.Len() > 0 {
+			segments = append(segments, placeholderSegment{literal: literal.String()})
+			literal.Reset()
+		}
+		segments = append(segments, placeholderSegment{placeholder: &psp})
+		i = p.pos
+	}
+	if literal.Len() > 0 {
+		segments = append(segments, placeholderSegment{literal: literal.String()})
+	}
+	return segments, nil
+}
+
+type placeholderParser struct {
+	line  string
+	start int // offset of $RELIZA{
+	pos   int
+}
+
+func (p *placeholderParser) fail(format string, a ...interface{}) error {
+	end := p.pos + 1
+	if end > len(p.line) {
+		end = len(p.line)
+	}
+	return &PlaceholderSyntaxError{Column: p.pos + 1, Placeholder: p.line[p.start:end], Reason: fmt.Sprintf(format, a...)}
+}
+
+func (p *placeholderParser) peek() byte {
+	if p.pos < len(p.line) {
+		return p.line[p.pos]
+	}
+	return 0
+}
+
+func (p *placeholderParser) skipSpaces() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+func (p *placeholderParser) readWhile(accept func(c byte) bool) string {
+	begin := p.pos
+	for p.pos < len(p.line) && accept(p.line[p.pos]) {
+		p.pos++
+	}
+	return p.line[begin:p.pos]
+}
+
+func isPlaceholderIdentChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' || c == '/' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// knownSource reports whether text after $RELIZA{ starts with one of placeholder sources followed by .
+func (p *placeholderParser) knownSource() bool {
+	for _, s := range placeholderSources {
+		if strings.HasPrefix(p.line[p.pos:], s+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// parse reads placeholder starting after $RELIZA{ with known source, on success pos is after closing }
+func (p *placeholderParser) parse() (PropSecretParse, error) {
+	var psp PropSecretParse
+	psp.Type = p.readWhile(func(c byte) bool { return c >= 'A' && c <= 'Z' })
+	p.pos++
+	psp.Key = p.readWhile(isPlaceholderIdentChar)
+	if len(psp.Key) == 0 {
+		return psp, p.fail("key is empty")
+	}
+	p.skipSpaces()
+
+	if p.peek() == ':' {
+		p.pos++
+		value, err := p.parseDefault()
+		if err != nil {
+			return psp, err
+		}
+		psp.Default = value
+		psp.HasDefault = true
+		p.skipSpaces()
+	}
+
+	for p.peek() == '|' {
+		p.pos++
+		p.skipSpaces()
+		filter := PlaceholderFilter{Name: p.readWhile(func(c byte) bool { return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' })}
+		p.skipSpaces()
+		filter.Arg = p.readWhile(func(c byte) bool { return c != ' ' && c != '\t' && c != '|' && c != '}' })
+		if err := validatePlaceholderFilter(filter); err != nil {
+			return psp, p.fail("%s", err.Error())
+		}
+		psp.Filters = append(psp.Filters, filter)
+		p.skipSpaces()
+	}
+
+	switch p.peek() {
+	case '}':
+		p.pos++
+	case 0:
+		return psp, p.fail("closing } is missing")
+	default:
+		return psp, p.fail("unexpected character %q", p.peek())
+	}
+	psp.Wholetext = p.line[p.start:p.pos]
+	return psp, nil
+}
+
+// parseDefault reads quoted or unquoted default value
+func (p *placeholderParser) parseDefault() (string, error) {
+	var value strings.Builder
+	switch quote := p.peek(); quote {
+	case '"', '\'':
+		p.pos++
+		for {
+			c := p.peek()
+			if p.pos >= len(p.line) {
+				return "", p.fail("default value is missing closing %c", quote)
+			}
+			p.pos++
+			if c == quote {
+				return value.String(), nil
+			}
+			if c == '\\' && quote == '"' && (p.peek() == '"' || p.peek() == '\\') {
+				c = p.peek()
+				p.pos++
+			}
+			value.WriteByte(c)
+		}
+	default:
+		return strings.TrimRight(p.readWhile(func(c byte) bool { return c != '|' && c != '}' }), " \t"), nil
+	}
+}
+
+func validatePlaceholderFilter(filter PlaceholderFilter) error {
+	switch filter.Name {
+	case "base64", "b64dec", "quote", "upper", "lower", "trim":
+		if len(filter.Arg) > 0 {
+			return errors.Errorf("filter %s does not take argument", filter.Name)
+		}
+	case "indent":
+		if n, err := strconv.Atoi(filter.Arg); err != nil || n < 0 {
+			return errors.Errorf("filter indent requires number of spaces")
+		}
+	case "":
+		return errors.Errorf("filter name is missing after |")
+	default:
+		return errors.Errorf("unknown filter %s", filter.Name)
+	}
+	return nil
+}
+
+// applyPlaceholderFilters applies filters to value in order, filters are validated by tokenizer
+func applyPlaceholderFilters(value string, filters []PlaceholderFilter) (string, error) {
+	for _, filter := range filters {
+		switch filter.Name {
+		case "base64":
+			value = base64.StdEncoding.EncodeToString([]byte(value))
+		case "b64dec":
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return "", errors.Wrap(err, "b64dec")
+			}
+			value = string(decoded)
+		case "quote":
+			value = strconv.Quote(value)
+		case "upper":
+			value = strings.ToUpper(value)
+		case "lower":
+			value = strings.ToLower(value)
+		case "trim":
+			value = strings.TrimSpace(value)
+		case "indent":
+			n, _ := strconv.Atoi(filter.Arg)
+			pad := strings.Repeat(" ", n)
+			value = pad + strings.ReplaceAll(value, "\n", "\n"+pad)
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,189 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/diff"
+)
+
+// FileChange is the result of replacing tags in a single file, computed without writing anything
+type FileChange struct {
+	Infile  string
+	Outfile string // empty when output would go to stdout
+	// Before is current content of Outfile, or content of Infile when Outfile is not set or does not exist yet
+	Before string
+	// Base is the file Before was read from, empty when there is nothing to compare with
+	Base string
+	// Exists is false when Outfile would be created
+	Exists bool
+	After  string
+}
+
+// Changed reports whether writing the file would change it, provenance header is ignored since it
+// contains generation time
+func (fc FileChange) Changed() bool {
+	if len(fc.Base) == 0 {
+		return true
+	}
+	return stripProvenance(fc.Before) != stripProvenance(fc.After)
+}
+
+// Diff returns unified diff of the change or empty string if the file would not change
+func (fc FileChange) Diff() string {
+	if !fc.Changed() {
+		return ""
+	}
+	fromName := "/dev/null"
+	if len(fc.Base) > 0 {
+		fromName = "a/" + filepath.ToSlash(fc.Base)
+	}
+	return diff.Unified(fromName, "b/"+filepath.ToSlash(fc.target()), fc.Before, fc.After)
+}
+
+func (fc FileChange) target() string {
+	if len(fc.Outfile) > 0 {
+		return fc.Outfile
+	}
+	return fc.Infile
+}
+
+func stripProvenance(content string) string {
+	if strings.HasPrefix(content, "# Tags replaced with Reliza CLI") {
+		_, content, _ = strings.Cut(content, "\n")
+		if strings.HasPrefix(content, "# According to") {
+			_, content, _ = strings.Cut(content, "\n")
+		}
+	}
+	return content
+}
+
+// PlanReplaceTags does the same as ReplaceTags but does not write any files, instead it returns
+// changes which would be made to every file of infile or indirectory
+func PlanReplaceTags(ctx context.Context, replaceTagsVars ReplaceTagsVars) ([]FileChange, error) {
+	tagSourceMap, componentProperties, err := scanTags(ctx, &replaceTagsVars)
+	if err != nil {
+		return nil, err
+	}
+	replaceTagsVars.componentProperties = componentProperties
+
+	substitutionMap, err := constructSubstitutionMap(&replaceTagsVars, tagSourceMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(replaceTagsVars.Chart) > 0 {
+		if err := prepareChartVars(&replaceTagsVars); err != nil {
+			return nil, err
+		}
+	}
+	if len(replaceTagsVars.Kustomization) > 0 {
+		if err := prepareKustomizationVars(&replaceTagsVars); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(replaceTagsVars.Infile) > 0 && len(replaceTagsVars.Indirectory) == 0 {
+		change, err := planFile(ctx, &replaceTagsVars, substitutionMap)
+		if err != nil {
+			return nil, err
+		}
+		return []FileChange{change}, nil
+	} else if len(replaceTagsVars.Infile) == 0 && len(replaceTagsVars.Indirectory) > 0 {
+		if len(replaceTagsVars.Outfile) > 0 {
+			return nil, ErrOutfileWithDirectory
+		}
+		if len(replaceTagsVars.Outdirectory) == 0 {
+			return nil, ErrNoOutDirectory
+		}
+		filter, err := newFileFilter(&replaceTagsVars, replaceTagsVars.Indirectory)
+		if err != nil {
+			return nil, err
+		}
+		return planDirectory(ctx, &replaceTagsVars, filter, substitutionMap)
+	}
+	return nil, ErrInvalidInput
+}
+
+func planFile(ctx context.Context, replaceTagsVars *ReplaceTagsVars, substitutionMap map[string]Substitution) (FileChange, error) {
+	change := FileChange{Infile: replaceTagsVars.Infile, Outfile: replaceTagsVars.Outfile}
+	after, err := renderFile(ctx, replaceTagsVars, substitutionMap)
+	if err != nil {
+		return change, err
+	}
+	change.After = after
+	if len(replaceTagsVars.Chart) > 0 && len(change.Outfile) < 1 {
+		// overrides would go to stdout, there is nothing to compare with
+		return change, nil
+	}
+
+	// output which does not exist yet is compared with input, so that only actual replacements are reported
+	base := change.target()
+	before, err := os.ReadFile(base)
+	change.Exists = err == nil
+	if os.IsNotExist(err) && len(change.Infile) > 0 && change.Infile != base {
+		base = change.Infile
+		before, err = os.ReadFile(base)
+	}
+	if os.IsNotExist(err) {
+		return change, nil
+	} else if err != nil {
+		return change, errors.Wrapf(err, "error reading %s", base)
+	}
+	change.Base = base
+	change.Before = string(before)
+	return change, nil
+}
+
+// planDirectory plans changes of parsed files only, files copied as is are not reported
+func planDirectory(ctx context.Context, replaceTagsVars *ReplaceTagsVars, filter *fileFilter, substitutionMap map[string]Substitution) ([]FileChange, error) {
+	// nothing is written, so existing outdirectory is not an error, its files are compared with instead
+	jobs, err := collectDirectoryJobs(replaceTagsVars, replaceTagsVars.Indirectory, replaceTagsVars.Outdirectory, filter, false)
+	if err != nil {
+		return nil, err
+	}
+	var parsedJobs []fileJob
+	for _, job := range jobs {
+		if job.parse {
+			parsedJobs = append(parsedJobs, job)
+		}
+	}
+	resolvedSp, err := resolveDirectorySecretProps(ctx, replaceTagsVars, parsedJobs)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]FileChange, len(parsedJobs))
+	index := map[string]int{}
+	for i, job := range parsedJobs {
+		index[job.infile] = i
+	}
+	err = runFileJobs(ctx, replaceTagsVars, parsedJobs, resolvedSp, func(fileVars *ReplaceTagsVars, job fileJob) error {
+		change, err := planFile(ctx, fileVars, substitutionMap)
+		changes[index[job.infile]] = change
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
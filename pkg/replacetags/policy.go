@@ -0,0 +1,186 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+	"sigs.k8s.io/yaml"
+)
+
+// Policy rules which images written by replacetags must satisfy, i.e.
+//
+//	allowedRegistries:
+//	  - registry.corp.local
+//	requireDigest: true
+//	forbiddenTags:
+//	  - latest
+//	requiredProperties:
+//	  - name: reliza:approved
+//	    value: "true"
+//
+// Images replaced from tag sources and images left unresolved are checked, required properties only apply
+// to replaced images since they refer to CycloneDX component of the tag source
+type Policy struct {
+	// AllowedRegistries are prefixes of normalized image names matched on path component boundary,
+	// i.e. docker.io/library allows redis, empty list allows any registry
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+	// RequireDigest forbids images without digest, i.e. tag-only substitutions from text tag sources
+	RequireDigest bool `json:"requireDigest,omitempty"`
+	// ForbiddenTags are tags which must not be used, image without tag and digest is treated as latest
+	ForbiddenTags []string `json:"forbiddenTags,omitempty"`
+	// RequiredProperties must be set on CycloneDX component of the tag source, any value is accepted if Value is empty
+	RequiredProperties []RequiredProperty `json:"requiredProperties,omitempty"`
+}
+
+// RequiredProperty is a CycloneDX property required by Policy
+type RequiredProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// Policy rules reported in violations
+const (
+	PolicyRuleAllowedRegistries  = "allowedRegistries"
+	PolicyRuleRequireDigest      = "requireDigest"
+	PolicyRuleForbiddenTags      = "forbiddenTags"
+	PolicyRuleRequiredProperties = "requiredProperties"
+)
+
+// PolicyViolation is an image of a file which violates policy rule, line is 1-based and refers to the input file
+type PolicyViolation struct {
+	Line    int    `json:"line"`
+	Image   string `json:"image"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyViolationError is returned when images of a file violate policy, output of the file is not written
+type PolicyViolationError struct {
+	File       string
+	Violations []PolicyViolation
+}
+
+func (e *PolicyViolationError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d image policy violations in '%s':", len(e.Violations), e.File)
+	for _, v := range e.Violations {
+		fmt.Fprintf(&sb, "\n\tline %d: %s", v.Line, v.Message)
+	}
+	return sb.String()
+}
+
+// ReadPolicyFile reads policy from yaml or json file
+func ReadPolicyFile(path string) (*Policy, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading policy file %s", path)
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict(content, &policy); err != nil {
+		return nil, errors.Wrapf(err, "error parsing policy file %s", path)
+	}
+	for _, rp := range policy.RequiredProperties {
+		if len(rp.Name) < 1 {
+			return nil, errors.Errorf("required property without name in policy file %s", path)
+		}
+	}
+	return &policy, nil
+}
+
+// checkImage returns violations of image, properties are those of tag source component and are only checked if
+// fromTagSource is set; values which are not image references, i.e. templates, are not checked
+func (p *Policy) checkImage(image string, properties map[string]string, fromTagSource bool) []PolicyViolation {
+	ref, err := reference.ParseNormalized(reference.TrimScheme(strings.TrimSpace(image)))
+	if err != nil {
+		return nil
+	}
+	var violations []PolicyViolation
+	violate := func(rule string, format string, a ...interface{}) {
+		violations = append(violations, PolicyViolation{Image: image, Rule: rule, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if len(p.AllowedRegistries) > 0 {
+		allowed := false
+		for _, registry := range p.AllowedRegistries {
+			prefix := normalizeRegistryPrefix(registry)
+			if ref.Name() == prefix || strings.HasPrefix(ref.Name(), prefix+"/") {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violate(PolicyRuleAllowedRegistries, "image %s is not from allowed registries %s", image, strings.Join(p.AllowedRegistries, ", "))
+		}
+	}
+	if p.RequireDigest && len(ref.Digest) == 0 {
+		violate(PolicyRuleRequireDigest, "image %s is not pinned by digest", image)
+	}
+	tag := ref.Tag
+	if len(tag) == 0 && len(ref.Digest) == 0 {
+		tag = "latest"
+	}
+	for _, forbidden := range p.ForbiddenTags {
+		if len(tag) > 0 && tag == forbidden {
+			violate(PolicyRuleForbiddenTags, "image %s uses forbidden tag %s", image, tag)
+		}
+	}
+	if !fromTagSource {
+		return violations
+	}
+	for _, rp := range p.RequiredProperties {
+		value, ok := properties[rp.Name]
+		if !ok {
+			violate(PolicyRuleRequiredProperties, "tag source component of image %s does not have property %s", image, rp.Name)
+		} else if len(rp.Value) > 0 && value != rp.Value {
+			violate(PolicyRuleRequiredProperties, "tag source component of image %s has property %s = %s, %s is required", image, rp.Name, value, rp.Value)
+		}
+	}
+	return violations
+}
+
+// checkPolicy checks replaced and unresolved images of the file against policy, records violations in file report
+// and returns them as error
+func (fp *fileParser) checkPolicy() error {
+	policy := fp.vars.Policy
+	if policy == nil {
+		return nil
+	}
+	var violations []PolicyViolation
+	for _, r := range fp.report.Replacements {
+		for _, v := range policy.checkImage(r.Replacement, fp.vars.componentProperties[r.SubstitutionKey], true) {
+			v.Line = r.Line
+			violations = append(violations, v)
+		}
+	}
+	for _, u := range fp.report.Unresolved {
+		for _, v := range policy.checkImage(u.Reference, nil, false) {
+			v.Line = u.Line
+			violations = append(violations, v)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	fp.report.Violations = violations
+	return &PolicyViolationError{File: fp.fileName, Violations: violations}
+}
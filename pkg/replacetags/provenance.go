@@ -0,0 +1,111 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+This function returns some extra meta data info as comments to be added to the top of the output
+that is created by the replacetags command.
+
+The first line notes the version of reliza-cli that ran the command to generate the outfile, as
+well as the date the file was generated.
+The second line contains info about where the replaced tags were sourced from, when several tag sources
+are merged all of them are listed in order together with conflict policy.
+*/
+func provenanceHeader(replaceTagsVars *ReplaceTagsVars) string {
+	var provenanceLine1 string
+	var provenanceLine2 string
+
+	apiKeyId := ""
+	if replaceTagsVars.Hub != nil {
+		apiKeyId = replaceTagsVars.Hub.ApiKeyId
+	}
+
+	// First line: current reliza-cli version and current datetime
+	currentDateTimeFormatted := time.Now().UTC().Format(time.RFC3339)
+	provenanceLine1 = "# Tags replaced with Reliza CLI version " + replaceTagsVars.CliVersion + " on " + currentDateTimeFormatted
+
+	sources := tagSources(replaceTagsVars)
+	if len(sources) == 1 {
+		description := sources[0].describe(apiKeyId)
+		if len(description) > 0 {
+			provenanceLine2 = "# According to " + description
+		} else {
+			// should have at least one of those things
+			provenanceLine2 = "missing replacetags input"
+		}
+	} else {
+		policy := replaceTagsVars.ConflictPolicy
+		if len(policy) < 1 {
+			policy = ConflictFirstWins
+		}
+		var descriptions []string
+		for _, ts := range sources {
+			descriptions = append(descriptions, ts.describe(apiKeyId))
+		}
+		provenanceLine2 = "# According to tag sources in order (" + policy + "): " + strings.Join(descriptions, "; ")
+	}
+
+	return provenanceLine1 + "\n" + provenanceLine2 + "\n"
+}
+
+// String describes tag source the same way as provenance header does
+func (ts TagSource) String() string {
+	return ts.describe("")
+}
+
+/*
+describe returns where tags come from, either:
+(tagsource file) or (environment) or (bundle+version)
+or (instance+revision) or (instanceuri+revision) , revision is optional, otherwise uses latest revision
+or (apiKeyId suffix, if using apiKeyId+apiKey pair from instance),
+empty string is returned if source is not set
+*/
+func (ts TagSource) describe(apiKeyId string) string {
+	revisionOf := func(instance string) string {
+		if len(ts.Revision) > 0 {
+			return "revision " + ts.Revision + " of the instance " + instance
+		}
+		// no revision specified, using latest
+		return "latest approved images for the instance " + instance
+	}
+	if ts.File != "" {
+		return "tag source file " + ts.File
+	} else if len(ts.Bundle) > 0 && len(ts.Version) > 0 {
+		return "bundle " + ts.Bundle + " version " + ts.Version
+	} else if len(ts.Bundle) > 0 && len(ts.Environment) > 0 {
+		return "latest version of bundle " + ts.Bundle + " approved in " + ts.Environment + " environment"
+	} else if len(ts.Bundle) > 0 {
+		return "latest version of bundle " + ts.Bundle
+	} else if len(ts.Environment) > 0 {
+		return "the latest approved images in  " + ts.Environment + " environment."
+	} else if len(ts.Instance) > 0 {
+		return revisionOf(ts.Instance)
+	} else if len(ts.InstanceURI) > 0 {
+		return revisionOf("at " + ts.InstanceURI)
+	} else if strings.HasPrefix(apiKeyId, "INSTANCE__") && len(apiKeyId) >= 37 {
+		return revisionOf(apiKeyId[10:37]) // remove first 10 chars
+	} else if strings.HasPrefix(apiKeyId, "CLUSTER__") && len(apiKeyId) >= 36 {
+		return revisionOf(apiKeyId[9:36]) // remove first 9 chars
+	}
+	return ""
+}
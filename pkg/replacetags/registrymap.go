@@ -0,0 +1,160 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+	"sigs.k8s.io/yaml"
+)
+
+// RegistryRule rewrites names of images found in tag sources, i.e. to pull them through internal mirror.
+// Upstream is a prefix of normalized image name matched on path component boundary, i.e. docker.io matches
+// docker.io/library/redis, Regex is matched against normalized image name instead and Mirror may then refer
+// to its groups as $1. Only one of Upstream and Regex is set
+type RegistryRule struct {
+	Upstream string `json:"upstream,omitempty"`
+	Regex    string `json:"regex,omitempty"`
+	Mirror   string `json:"mirror"`
+}
+
+// registryMapFile is the format of registry map file:
+//
+//	registries:
+//	  - upstream: docker.io
+//	    mirror: mirror.local/dockerhub
+//	  - regex: ^quay\.io/(.*)$
+//	    mirror: mirror.local/quay/$1
+type registryMapFile struct {
+	Registries []RegistryRule `json:"registries"`
+}
+
+const registryRegexPrefix = "regex:"
+
+// ParseRegistryMap parses registry map values in order, every value is either upstream=mirror,
+// regex:expression=mirror or a path to registry map yaml file
+func ParseRegistryMap(values []string) ([]RegistryRule, error) {
+	var rules []RegistryRule
+	for _, value := range values {
+		// image names never contain =, so the last one separates mirror even if regex has it
+		i := strings.LastIndex(value, "=")
+		if i < 0 {
+			fileRules, err := ReadRegistryMapFile(value)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, fileRules...)
+			continue
+		}
+		rule := RegistryRule{Upstream: value[:i], Mirror: value[i+1:]}
+		if strings.HasPrefix(rule.Upstream, registryRegexPrefix) {
+			rule.Regex = strings.TrimPrefix(rule.Upstream, registryRegexPrefix)
+			rule.Upstream = ""
+		}
+		rules = append(rules, rule)
+	}
+	if _, err := compileRegistryMap(rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ReadRegistryMapFile reads rules of registry map yaml file
+func ReadRegistryMapFile(path string) ([]RegistryRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading registry map file %s", path)
+	}
+	var mapFile registryMapFile
+	if err := yaml.UnmarshalStrict(content, &mapFile); err != nil {
+		return nil, errors.Wrapf(err, "error parsing registry map file %s", path)
+	}
+	return mapFile.Registries, nil
+}
+
+type registryMapper struct {
+	rules   []RegistryRule
+	regexes []*regexp.Regexp
+}
+
+func compileRegistryMap(rules []RegistryRule) (*registryMapper, error) {
+	mapper := &registryMapper{rules: rules, regexes: make([]*regexp.Regexp, len(rules))}
+	for i, rule := range rules {
+		if (len(rule.Upstream) > 0) == (len(rule.Regex) > 0) || len(rule.Mirror) < 1 {
+			return nil, errors.Errorf("registry map rule must have mirror and either upstream or regex, got %+v", rule)
+		}
+		if len(rule.Regex) > 0 {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid registry map regex %s", rule.Regex)
+			}
+			mapper.regexes[i] = re
+		}
+	}
+	return mapper, nil
+}
+
+// mapName returns mirrored name of normalized image name according to the first matching rule
+func (m *registryMapper) mapName(name string) (string, bool) {
+	for i, rule := range m.rules {
+		if re := m.regexes[i]; re != nil {
+			if re.MatchString(name) {
+				return re.ReplaceAllString(name, rule.Mirror), true
+			}
+			continue
+		}
+		upstream := normalizeRegistryPrefix(rule.Upstream)
+		if name == upstream || strings.HasPrefix(name, upstream+"/") {
+			return strings.TrimSuffix(rule.Mirror, "/") + strings.TrimPrefix(name, upstream), true
+		}
+	}
+	return "", false
+}
+
+// apply rewrites registry and image of substitution, tag and digest are kept
+func (m *registryMapper) apply(subst Substitution) (Substitution, bool, error) {
+	if len(subst.Registry) < 1 {
+		return subst, false, nil
+	}
+	mirrored, ok := m.mapName(GetMatchingKeyFromSubstitution(subst))
+	if !ok {
+		return subst, false, nil
+	}
+	ref, err := reference.ParseNormalized(mirrored)
+	if err != nil {
+		return subst, false, errors.Wrapf(err, "registry map produced invalid image name %s", mirrored)
+	}
+	return Substitution{Registry: ref.Domain, Image: ref.Path, Tag: subst.Tag, Digest: subst.Digest}, true, nil
+}
+
+// normalizeRegistryPrefix makes docker hub shortcuts of upstream comparable with normalized names,
+// i.e. library/redis is docker.io/library/redis, while docker.io and quay.io are kept as is
+func normalizeRegistryPrefix(upstream string) string {
+	upstream = strings.TrimSuffix(reference.TrimScheme(upstream), "/")
+	if first, _, _ := strings.Cut(upstream, "/"); strings.ContainsAny(first, ".:") || first == "localhost" {
+		if first == "index.docker.io" {
+			return reference.DefaultDomain + strings.TrimPrefix(upstream, first)
+		}
+		return upstream
+	}
+	return reference.DefaultDomain + "/" + upstream
+}
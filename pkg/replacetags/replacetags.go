@@ -0,0 +1,405 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+/*
+Package replacetags replaces image tags in k8s, helm or compose files with digested images
+resolved from a tag source - CycloneDX or text file, or Reliza Hub bundle, environment or instance.
+It also resolves $RELIZA{...} property and secret placeholders.
+
+All functions in this package return errors instead of terminating the process,
+so it can be embedded in other Go programs.
+*/
+package replacetags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+)
+
+type ReplaceTagsVars struct {
+	TagSourceFile           string
+	TypeVal                 string // type of tag source file: cyclonedx (default), spdx or text
+	Instance                string
+	Revision                string
+	InstanceURI             string
+	Bundle                  string
+	Version                 string
+	Environment             string
+	Namespace               string
+	Infile                  string
+	Indirectory             string
+	Outfile                 string
+	Outdirectory            string
+	DefinitionReferenceFile string // i.e. output of helm template command
+	ParseMode               string // "simple" || "extended" (default) || "strict" || "ast" || "compose"
+	Provenance              bool   // add provenance (metadata) to the beginning of output
+	ForDiff                 bool   // resolve secrets to their timestamps instead of sealed values
+	ResolveProps            bool   // resolve instance properties and secrets on Reliza Hub
+	BundleSpecificProps     bool   // use namespace and bundle for prop resolution
+	CliVersion              string // version of reliza-cli reported in provenance
+
+	// Hub is used to obtain tags from bundle, environment or instance and to resolve properties and secrets,
+	// may be nil if only tag source file is used and properties are not resolved
+	Hub *hub.Client
+	// PlainSecretResolver is used to unseal secrets referenced as $RELIZA{PLAINSECRET.key}, ctx is
+	// the one passed to ReplaceTags, so that waiting for the cluster is cancelled together with the run
+	PlainSecretResolver func(ctx context.Context, sealedSecret string, namespace string) (string, error)
+	// Log receives debug messages, may be nil
+	Log io.Writer
+	// Kustomization is a kustomization directory or file whose images transformer list is written instead of
+	// replacing tags in infile or indirectory, the file is updated in place unless outfile is set
+	Kustomization string
+	// EnvFile is used for variable interpolation in compose mode, defaults to .env next to infile
+	EnvFile string
+	// EmitEnv makes compose mode output env file with pinned variables instead of compose file
+	EmitEnv bool
+	// LookupEnv resolves $RELIZA{ENV.key} placeholders and takes precedence over EnvFile in compose mode,
+	// i.e. os.LookupEnv, may be nil
+	LookupEnv func(key string) (string, bool)
+	// Chart is a helm chart directory or packaged chart, its images are pinned in override values file which is
+	// written instead of replacing tags in infile or indirectory, see chart.go
+	Chart string
+	// ValueFiles are merged over values.yaml of Chart in order, relative paths are resolved against chart directory
+	ValueFiles []string
+	// JsonPaths select string values of JSON manifests which hold image references, i.e. containerDefinitions[*].image,
+	// if empty values of keys ending with image are used, see json.go
+	JsonPaths []string
+	// Include and Exclude are globs selecting files of Indirectory to parse, other files are copied as is, see filter.go
+	Include []string
+	Exclude []string
+	// Force allows writing to existing Outdirectory, files in it are overwritten
+	Force bool
+	// Workers is number of files of Indirectory processed concurrently, defaults to number of CPUs
+	Workers int
+	// TagSources are merged in order instead of using single tag source set by TagSourceFile, Bundle, Environment
+	// or Instance fields, see tagsource.go
+	TagSources []TagSource
+	// ConflictPolicy decides which tag source wins when they have the same image with different digests:
+	// first-wins (default), last-wins or error
+	ConflictPolicy string
+	// RegistryMap rewrites images of tag sources before they are written, i.e. to internal mirror, the first
+	// matching rule is used, see registrymap.go
+	RegistryMap []RegistryRule
+	// Report collects matched and unresolved images and resolved placeholders of every file, may be nil
+	Report *Report
+
+	// Policy is checked against images of every file, violations are recorded in report and fail the file, may be nil
+	Policy *Policy
+
+	// placeholders resolved once for all files of directory
+	resolvedSecretProps *hub.SecretPropsRHResp
+	// leave $RELIZA{...} placeholders as is, i.e. so that resolved secrets are not written to chart overrides
+	keepPlaceholders bool
+	// CycloneDX properties of tag source components by substitution key, used by Policy
+	componentProperties map[string]map[string]string
+}
+
+type Substitution struct {
+	Registry string
+	Image    string
+	Digest   string
+	Tag      string
+}
+
+type KeyValueSorted struct {
+	Key    string
+	Value  Substitution
+	Length int
+}
+
+// ReplaceTags scans tag source and replaces tags either in infile or in all files of indirectory.
+// If outfile is not set, resulting content of infile is returned instead of being written.
+func ReplaceTags(ctx context.Context, replaceTagsVars ReplaceTagsVars) (string, error) {
+	// v1 - takes inFile = inFile var, outFile = outfile, source txt file, definition reference file - i.e. result of helm template
+	// type - typeVal: options - text, cyclonedx, spdx
+
+	// 1st - scan tag source file and construct a map of generic tag to actual tag
+	tagSourceMap, componentProperties, err := scanTags(ctx, &replaceTagsVars)
+	if err != nil {
+		return "", err
+	}
+	replaceTagsVars.componentProperties = componentProperties
+
+	substitutionMap, err := constructSubstitutionMap(&replaceTagsVars, tagSourceMap)
+	if err != nil {
+		return "", err
+	}
+
+	if len(replaceTagsVars.Chart) > 0 {
+		if err := prepareChartVars(&replaceTagsVars); err != nil {
+			return "", err
+		}
+	}
+	if len(replaceTagsVars.Kustomization) > 0 {
+		if err := prepareKustomizationVars(&replaceTagsVars); err != nil {
+			return "", err
+		}
+	}
+
+	// Check if input is infile or inDirectory (operating on directory or file?)
+	if len(replaceTagsVars.Infile) > 0 && len(replaceTagsVars.Indirectory) == 0 {
+		return ReplaceTagsOnFile(ctx, &replaceTagsVars, substitutionMap)
+	} else if len(replaceTagsVars.Infile) == 0 && len(replaceTagsVars.Indirectory) > 0 {
+		return "", ReplaceTagsOnDirectory(ctx, &replaceTagsVars, substitutionMap)
+	}
+	// either infile and inDirectory provided (too many inputs), or neither provided
+	return "", ErrInvalidInput
+}
+
+func constructSubstitutionMap(replaceTagsVars *ReplaceTagsVars, tagSourceMap map[string]string) (map[string]Substitution, error) {
+	mapper, err := compileRegistryMap(replaceTagsVars.RegistryMap)
+	if err != nil {
+		return nil, err
+	}
+	// substitutions of all tag source images, mirrored ones are also added under mirrored name,
+	// so that files already referencing the mirror are matched as well
+	fullSubstitutionMap := map[string]Substitution{}
+	for tagSourceKey, tagSourceVal := range tagSourceMap {
+		subst := GetSubstitutionFromDigestedString(tagSourceVal)
+		mirrored, ok, err := mapper.apply(subst)
+		if err != nil {
+			return nil, err
+		}
+		fullSubstitutionMap[tagSourceKey] = mirrored
+		if ok {
+			mirroredKey := GetMatchingKeyFromSubstitution(mirrored)
+			replaceTagsVars.logf("Registry map: %s is replaced with %s\n", tagSourceKey, mirroredKey)
+			fullSubstitutionMap[mirroredKey] = mirrored
+			if props, ok := replaceTagsVars.componentProperties[tagSourceKey]; ok {
+				replaceTagsVars.componentProperties[mirroredKey] = props
+			}
+		}
+	}
+
+	// scan definition reference file and identify all used tags (scan by "image:" pattern)
+	// in kustomize mode definition references are used to find images, not to filter substitutions
+	if replaceTagsVars.DefinitionReferenceFile == "" || len(replaceTagsVars.Kustomization) > 0 {
+		return fullSubstitutionMap, nil
+	}
+	replaceTagsVars.logf("Scanning definition references...\n")
+	defScanMap, err := ScanDefinitionReferenceFile(replaceTagsVars.DefinitionReferenceFile)
+	if err != nil {
+		return nil, err
+	}
+	// combine 2 maps and come up with substitution map to apply to source (i.e. to source helm chart)
+	// traverse defScanMap, map to tag source substitutions and put to substitution map
+	substitutionMap := map[string]Substitution{}
+	for k := range defScanMap {
+		if subst, ok := fullSubstitutionMap[k]; ok {
+			substitutionMap[k] = subst
+		}
+	}
+	return substitutionMap, nil
+}
+
+// ReplaceTagsOnFile replaces tags in replaceTagsVars.Infile according to substitution map
+func ReplaceTagsOnFile(ctx context.Context, replaceTagsVars *ReplaceTagsVars, substitutionMap map[string]Substitution) (string, error) {
+	infile := replaceTagsVars.Infile
+	outfile := replaceTagsVars.Outfile
+
+	out, err := renderFile(ctx, replaceTagsVars, substitutionMap)
+	if err != nil {
+		return "", err
+	}
+
+	// outfile not specified, return output to the caller
+	if len(outfile) < 1 {
+		return out, nil
+	}
+
+	// keep mode of infile, i.e. scripts stay executable, chart and kustomization inputs are not regular files
+	inInfo, err := os.Stat(infile)
+	keepMode := err == nil && inInfo.Mode().IsRegular()
+	if outfile == infile {
+		os.Remove(infile)
+	}
+	if err := os.WriteFile(outfile, []byte(out), 0666); err != nil {
+		return "", errors.Wrapf(err, "error writing outfile: %s", outfile)
+	}
+	if keepMode {
+		// mode of existing file is not changed by WriteFile and new file mode is subject to umask
+		if err := os.Chmod(outfile, inInfo.Mode().Perm()); err != nil {
+			return "", errors.Wrapf(err, "error writing outfile: %s", outfile)
+		}
+	}
+	return "", nil
+}
+
+// renderFile returns content of replaceTagsVars.Infile with tags replaced, nothing is written
+func renderFile(ctx context.Context, replaceTagsVars *ReplaceTagsVars, substitutionMap map[string]Substitution) (string, error) {
+	infile := replaceTagsVars.Infile
+	if len(replaceTagsVars.Kustomization) > 0 {
+		return renderKustomization(replaceTagsVars, substitutionMap)
+	}
+	if len(replaceTagsVars.Chart) > 0 {
+		return renderChartValues(ctx, replaceTagsVars, substitutionMap)
+	}
+
+	fileInfo, err := os.Stat(infile)
+	if err != nil {
+		return "", err
+	} else if fileInfo.IsDir() {
+		return "", errors.Errorf("infile must be a path to a file, not a directory: %s", infile)
+	}
+	inContent, err := os.ReadFile(infile)
+	if err != nil {
+		return "", errors.Wrapf(err, "error opening infile: %s", infile)
+	}
+
+	// retrieve secrets and props from infile and resolve them on Reliza Hub
+	resolvedSp, err := replaceTagsVars.secretProps(ctx, infile, inContent)
+	if err != nil {
+		return "", err
+	}
+
+	// Parse infile and get slice of lines to be written to outfile/stdout
+	parsedLines, err := substituteCopyBasedOnMap(ctx, bytes.NewReader(inContent), infile, substitutionMap, replaceTagsVars, resolvedSp)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if !replaceTagsVars.ForDiff && replaceTagsVars.Provenance && !isJsonInput(infile, inContent) {
+		out.WriteString(provenanceHeader(replaceTagsVars))
+	}
+	for _, line := range parsedLines {
+		out.WriteString(line + "\n")
+	}
+	return out.String(), nil
+}
+
+// ReplaceTagsOnDirectory replaces tags in all files of replaceTagsVars.Indirectory recursively and
+// writes results to replaceTagsVars.Outdirectory, files not selected by filter (see filter.go) are copied as is
+func ReplaceTagsOnDirectory(ctx context.Context, replaceTagsVars *ReplaceTagsVars, substitutionMap map[string]Substitution) error {
+	// If parsing files from input directory, an output directory path should be provided, not an output file path.
+	if len(replaceTagsVars.Outfile) > 0 {
+		return ErrOutfileWithDirectory
+	}
+	// Check that outDirectory has value. Cannot write to stdout when parsing multiple files from a directory.
+	if len(replaceTagsVars.Outdirectory) == 0 {
+		return ErrNoOutDirectory
+	}
+	if _, err := os.Stat(replaceTagsVars.Outdirectory); err == nil && replaceTagsVars.Outdirectory != replaceTagsVars.Indirectory && !replaceTagsVars.Force {
+		return errors.Errorf("output directory already exists %s, use force to overwrite", replaceTagsVars.Outdirectory)
+	}
+	filter, err := newFileFilter(replaceTagsVars, replaceTagsVars.Indirectory)
+	if err != nil {
+		return err
+	}
+	jobs, err := collectDirectoryJobs(replaceTagsVars, replaceTagsVars.Indirectory, replaceTagsVars.Outdirectory, filter, true)
+	if err != nil {
+		return err
+	}
+	resolvedSp, err := resolveDirectorySecretProps(ctx, replaceTagsVars, jobs)
+	if err != nil {
+		return err
+	}
+	return runFileJobs(ctx, replaceTagsVars, jobs, resolvedSp, func(fileVars *ReplaceTagsVars, job fileJob) error {
+		fileVars.logf("curinfile = %s , curoutfile = %s\n", job.infile, job.outfile)
+		if !job.parse {
+			if job.infile == job.outfile {
+				return nil
+			}
+			return copyFileVerbatim(job.infile, job.outfile)
+		}
+		_, err := ReplaceTagsOnFile(ctx, fileVars, substitutionMap)
+		return err
+	})
+}
+
+// ScanDefinitionReferenceFile scans file by "image:" pattern and returns map of images without tags to full images
+func ScanDefinitionReferenceFile(definitionReferenceFile string) (map[string]string, error) {
+	defFile, err := os.Open(definitionReferenceFile)
+	if err != nil {
+		return nil, err
+	}
+	defer defFile.Close()
+	return scanDefinitionReferences(defFile)
+}
+
+// scanDefinitionReferences does the same as ScanDefinitionReferenceFile on content of reader, i.e. rendered chart
+func scanDefinitionReferences(in io.Reader) (map[string]string, error) {
+	// map to store definition images to their replacements -> will be applied on source files
+	defScanMap := map[string]string{}
+	err := scanDefinitionImages(in, func(image string) {
+		ref, err := reference.ParseNormalized(image)
+		if err != nil {
+			// not an image reference, i.e. unrendered template
+			return
+		}
+		defScanMap[ref.Name()] = image
+	})
+	return defScanMap, err
+}
+
+// scanDefinitionImages calls fn with every value of "image:" lines of reader, quotes are removed
+func scanDefinitionImages(in io.Reader, fn func(image string)) error {
+	defScanner := bufio.NewScanner(in)
+	// input files must be utf-8 !!!
+	for defScanner.Scan() {
+		line := defScanner.Text()
+		if i := strings.Index(strings.ToLower(line), "image: "); i > -1 {
+			// extract actual image and remove beginning and ending quotes if present
+			image := strings.TrimSpace(line[i+len("image: "):])
+			fn(strings.Trim(image, "\"'"))
+		}
+	}
+	return defScanner.Err()
+}
+
+// GetSubstitutionFromDigestedString parses image reference into normalized substitution, i.e.
+// redis:6 -> Registry docker.io, Image library/redis, Tag 6. If ds is not a valid reference,
+// substitution only has Image set to ds as is, so that it never matches normalized keys
+func GetSubstitutionFromDigestedString(ds string) Substitution {
+	// sample ds = taleodor/mafia-express:tag@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d
+	ref, err := reference.ParseNormalized(strings.TrimSpace(ds))
+	if err != nil {
+		return Substitution{Image: ds}
+	}
+	return Substitution{Registry: ref.Domain, Image: ref.Path, Tag: ref.Tag, Digest: ref.Digest}
+}
+
+func GetMatchingKeyFromSubstitution(subst Substitution) string {
+	matchingKeyImage := subst.Registry + "/" + subst.Image
+	return matchingKeyImage
+}
+
+func GetDigestedImageFromSubstitution(subst Substitution) string {
+	digestedImage := subst.Registry + "/" + subst.Image
+	if len(subst.Tag) > 0 {
+		digestedImage += ":" + subst.Tag
+	}
+	if len(subst.Digest) > 0 {
+		digestedImage += "@" + subst.Digest
+	}
+	return digestedImage
+}
+
+func (replaceTagsVars *ReplaceTagsVars) logf(format string, a ...interface{}) {
+	if replaceTagsVars.Log != nil {
+		fmt.Fprintf(replaceTagsVars.Log, format, a...)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Report records what replacetags did to every processed file, set ReplaceTagsVars.Report to collect it
+type Report struct {
+	Files []FileReport `json:"files"`
+
+	mu sync.Mutex
+}
+
+// FileReport lists replacements, unresolved images and placeholders of a single file, line numbers are 1-based
+// and refer to the input file
+type FileReport struct {
+	Infile       string             `json:"infile"`
+	Outfile      string             `json:"outfile,omitempty"`
+	Replacements []ReplacementEntry `json:"replacements"`
+	Unresolved   []UnresolvedImage  `json:"unresolved"`
+	Placeholders []PlaceholderEntry `json:"placeholders"`
+	Violations   []PolicyViolation  `json:"violations,omitempty"`
+}
+
+// ReplacementEntry is an image reference which was matched to a key of substitution map
+type ReplacementEntry struct {
+	Line            int    `json:"line"`
+	Original        string `json:"original"`
+	Replacement     string `json:"replacement"`
+	SubstitutionKey string `json:"substitutionKey"`
+}
+
+// UnresolvedImage is an image reference which did not match any key of substitution map and was left as is
+type UnresolvedImage struct {
+	Line      int    `json:"line"`
+	Reference string `json:"reference"`
+}
+
+// PlaceholderEntry is a resolved $RELIZA{...} placeholder, values are not recorded so that secrets do not leak into reports
+type PlaceholderEntry struct {
+	Line        int    `json:"line"`
+	Type        string `json:"type"`
+	Key         string `json:"key"`
+	UsedDefault bool   `json:"usedDefault"`
+}
+
+func (r *Report) addFile(fr FileReport) {
+	if fr.Replacements == nil {
+		fr.Replacements = []ReplacementEntry{}
+	}
+	if fr.Unresolved == nil {
+		fr.Unresolved = []UnresolvedImage{}
+	}
+	if fr.Placeholders == nil {
+		fr.Placeholders = []PlaceholderEntry{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Files = append(r.Files, fr)
+}
+
+// WriteFile writes report as indented json
+func (r *Report) WriteFile(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Files == nil {
+		r.Files = []FileReport{}
+	}
+	reportJson, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(reportJson, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "error writing report: %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+)
+
+// ErrEmptySpdxPackages is returned when SPDX document used as tag source has no packages
+var ErrEmptySpdxPackages = errors.New("SPDX document packages are empty")
+
+// imageFromPurl converts pkg:docker or pkg:oci package url to image reference, other purl types are not images
+func imageFromPurl(purl string) (string, bool) {
+	var purlType string
+	switch {
+	case strings.HasPrefix(purl, "pkg:docker/"):
+		purlType = "docker"
+	case strings.HasPrefix(purl, "pkg:oci/"):
+		purlType = "oci"
+	default:
+		return "", false
+	}
+	rest := strings.TrimPrefix(purl, "pkg:"+purlType+"/")
+	if i := strings.Index(rest, "#"); i > -1 {
+		rest = rest[:i]
+	}
+	var qualifiers url.Values
+	if i := strings.Index(rest, "?"); i > -1 {
+		qualifiers, _ = url.ParseQuery(rest[i+1:])
+		rest = rest[:i]
+	}
+	name, version := rest, ""
+	if i := strings.LastIndex(rest, "@"); i > -1 {
+		name, version = rest[:i], rest[i+1:]
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	if unescaped, err := url.PathUnescape(version); err == nil {
+		version = unescaped
+	}
+
+	// repository_url of docker purl is registry (with namespace), of oci purl - full repository including name
+	image := name
+	if repositoryURL := strings.TrimSuffix(reference.TrimScheme(qualifiers.Get("repository_url")), "/"); len(repositoryURL) > 0 {
+		if purlType == "oci" {
+			image = repositoryURL
+		} else {
+			image = repositoryURL + "/" + name
+		}
+	}
+	digested := strings.Contains(version, ":")
+	if tag := qualifiers.Get("tag"); len(tag) > 0 && (digested || len(version) == 0) {
+		image += ":" + tag
+	}
+	if digested {
+		image += "@" + version
+	} else if len(version) > 0 {
+		image += ":" + version
+	}
+	return image, true
+}
+
+// cycloneXMLComponent is a component of CycloneDX XML BOM, only fields used for tag source are read
+type cycloneXMLComponent struct {
+	Type    string `xml:"type,attr"`
+	Name    string `xml:"name"`
+	Version string `xml:"version"`
+	Purl    string `xml:"purl"`
+	Hashes  []struct {
+		Alg     string `xml:"alg,attr"`
+		Content string `xml:",chardata"`
+	} `xml:"hashes>hash"`
+	Properties []struct {
+		Name  string `xml:"name,attr"`
+		Value string `xml:",chardata"`
+	} `xml:"properties>property"`
+	Components []cycloneXMLComponent `xml:"components>component"`
+}
+
+type cycloneXMLBom struct {
+	XMLName    xml.Name `xml:"bom"`
+	Components *struct {
+		Component []cycloneXMLComponent `xml:"component"`
+	} `xml:"components"`
+}
+
+// toJSON converts component to the form of CycloneDX JSON, so both formats are handled the same way
+func (c cycloneXMLComponent) toJSON() map[string]interface{} {
+	bomc := map[string]interface{}{
+		"type":    c.Type,
+		"name":    strings.TrimSpace(c.Name),
+		"version": strings.TrimSpace(c.Version),
+	}
+	if purl := strings.TrimSpace(c.Purl); len(purl) > 0 {
+		bomc["purl"] = purl
+	}
+	var hashes, properties, components []interface{}
+	for _, h := range c.Hashes {
+		hashes = append(hashes, map[string]interface{}{"alg": h.Alg, "content": strings.TrimSpace(h.Content)})
+	}
+	for _, p := range c.Properties {
+		properties = append(properties, map[string]interface{}{"name": p.Name, "value": strings.TrimSpace(p.Value)})
+	}
+	for _, nested := range c.Components {
+		components = append(components, nested.toJSON())
+	}
+	if hashes != nil {
+		bomc["hashes"] = hashes
+	}
+	if properties != nil {
+		bomc["properties"] = properties
+	}
+	if components != nil {
+		bomc["components"] = components
+	}
+	return bomc
+}
+
+// extractComponentsFromCycloneXML adds container components of CycloneDX XML BOM to the tag source map
+func extractComponentsFromCycloneXML(content []byte, tagSourceMap map[string]string, componentProperties map[string]map[string]string) error {
+	var bom cycloneXMLBom
+	if err := xml.Unmarshal(content, &bom); err != nil {
+		return errors.Wrap(err, "error reading CycloneDX XML BOM")
+	}
+	if bom.Components == nil {
+		return ErrEmptyComponents
+	}
+	var components []interface{}
+	for _, c := range bom.Components.Component {
+		components = append(components, c.toJSON())
+	}
+	return extractCycloneBom(map[string]interface{}{"components": components}, tagSourceMap, componentProperties)
+}
+
+// spdxPackage holds fields of SPDX package used for tag source
+type spdxPackage struct {
+	Name             string
+	Version          string
+	DownloadLocation string
+	Purpose          string
+	Purls            []string
+}
+
+// readSpdxJSON reads packages of SPDX 2.x JSON document
+func readSpdxJSON(content []byte) ([]spdxPackage, error) {
+	var doc struct {
+		Packages *[]struct {
+			Name                  string `json:"name"`
+			VersionInfo           string `json:"versionInfo"`
+			DownloadLocation      string `json:"downloadLocation"`
+			PrimaryPackagePurpose string `json:"primaryPackagePurpose"`
+			ExternalRefs          []struct {
+				ReferenceType    string `json:"referenceType"`
+				ReferenceLocator string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Packages == nil {
+		return nil, ErrEmptySpdxPackages
+	}
+	var packages []spdxPackage
+	for _, p := range *doc.Packages {
+		pkg := spdxPackage{Name: p.Name, Version: p.VersionInfo, DownloadLocation: p.DownloadLocation, Purpose: p.PrimaryPackagePurpose}
+		for _, ref := range p.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				pkg.Purls = append(pkg.Purls, ref.ReferenceLocator)
+			}
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// readSpdxTagValue reads packages of SPDX 2.x tag-value document, every PackageName tag starts a new package
+func readSpdxTagValue(content []byte) ([]spdxPackage, error) {
+	var packages []spdxPackage
+	var pkg *spdxPackage
+	inText := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// multi-line values are enclosed in <text></text> and are never used for tag source
+		if inText {
+			inText = !strings.Contains(line, "</text>")
+			continue
+		}
+		tag, value, found := strings.Cut(line, ":")
+		if !found || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if strings.HasPrefix(value, "<text>") {
+			inText = !strings.Contains(value, "</text>")
+			continue
+		}
+		switch strings.TrimSpace(tag) {
+		case "PackageName":
+			packages = append(packages, spdxPackage{Name: value})
+			pkg = &packages[len(packages)-1]
+		case "FileName", "SnippetSPDXID":
+			// package information ends with the first file or snippet
+			pkg = nil
+		}
+		if pkg == nil {
+			continue
+		}
+		switch strings.TrimSpace(tag) {
+		case "PackageVersion":
+			pkg.Version = value
+		case "PackageDownloadLocation":
+			pkg.DownloadLocation = value
+		case "PrimaryPackagePurpose":
+			pkg.Purpose = value
+		case "ExternalRef":
+			// ExternalRef: PACKAGE-MANAGER purl pkg:docker/...
+			if fields := strings.Fields(value); len(fields) == 3 && fields[1] == "purl" {
+				pkg.Purls = append(pkg.Purls, fields[2])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(packages) < 1 {
+		return nil, ErrEmptySpdxPackages
+	}
+	return packages, nil
+}
+
+// extractSpdxPackages adds images of SPDX packages to the tag source map - from docker or oci purls, otherwise from
+// download location if it is a digested image reference or if package is a container
+func extractSpdxPackages(packages []spdxPackage, tagSourceMap map[string]string) {
+	for _, pkg := range packages {
+		resolvedImage := false
+		for _, purl := range pkg.Purls {
+			if image, ok := imageFromPurl(purl); ok {
+				parseImageNameIntoMap(image, tagSourceMap)
+				resolvedImage = true
+			}
+		}
+		if resolvedImage || pkg.DownloadLocation == "NONE" || pkg.DownloadLocation == "NOASSERTION" {
+			continue
+		}
+		location := reference.TrimScheme(strings.TrimPrefix(pkg.DownloadLocation, "docker://"))
+		if ref, err := reference.Parse(location); err == nil && (len(ref.Digest) > 0 || pkg.Purpose == "CONTAINER") {
+			parseImageNameIntoMap(location, tagSourceMap)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+)
+
+type SecretProps struct {
+	Secrets    map[string]bool `json:"secrets"`
+	Properties map[string]bool `json:"properties"`
+}
+
+// PropSecretParse is a parsed $RELIZA{...} placeholder, see placeholder.go for its grammar
+type PropSecretParse struct {
+	Type       string // PROPERTY, SECRET, PLAINSECRET or ENV
+	Key        string // key known to Reliza Hub or environment variable name
+	Default    string // default value of property or environment variable
+	HasDefault bool   // default is set, possibly to empty value
+	Filters    []PlaceholderFilter
+	Wholetext  string // Whole string to substitute including $RELIZA prefix and {}
+}
+
+func parseSecretsPropsFromInFile(in io.Reader, fileName string) (SecretProps, error) {
+	var sp SecretProps
+	sp.Secrets = map[string]bool{}
+	sp.Properties = map[string]bool{}
+
+	inScanner := bufio.NewScanner(in)
+	lineNumber := 0
+	for inScanner.Scan() {
+		line := inScanner.Text()
+		lineNumber++
+
+		// each piece we are interested in looks like `$RELIZA{PROPERTY.FQDN}`
+		pspArr, err := parseLineToSecrets(line)
+		if err != nil {
+			return sp, withPlaceholderLocation(err, fileName, lineNumber)
+		}
+		for _, psp := range pspArr {
+			if psp.Type == "PROPERTY" {
+				sp.Properties[psp.Key] = true
+			} else if psp.Type == "SECRET" || psp.Type == "PLAINSECRET" {
+				sp.Secrets[psp.Key] = true
+			}
+		}
+	}
+	return sp, inScanner.Err()
+}
+
+func resolveSecretPropsOnRelizaHub(ctx context.Context, replaceTagsVars *ReplaceTagsVars, sp SecretProps) (hub.SecretPropsRHResp, error) {
+	var respData hub.SecretPropsRHResp
+	// legacy behavior is to not resolve props
+	if !replaceTagsVars.ResolveProps {
+		return respData, nil
+	}
+	if replaceTagsVars.Hub == nil {
+		return respData, errors.New("reliza hub client is required to resolve properties and secrets")
+	}
+
+	var secretsInp []string
+	var propsInp []string
+	for sk := range sp.Secrets {
+		secretsInp = append(secretsInp, sk)
+	}
+	for sp := range sp.Properties {
+		propsInp = append(propsInp, sp)
+	}
+	sort.Strings(secretsInp)
+	sort.Strings(propsInp)
+
+	return replaceTagsVars.Hub.InstancePropsSecrets(ctx, hub.InstancePropsSecretsInput{
+		Instance:            replaceTagsVars.Instance,
+		InstanceURI:         replaceTagsVars.InstanceURI,
+		Revision:            replaceTagsVars.Revision,
+		Namespace:           replaceTagsVars.Namespace,
+		Bundle:              replaceTagsVars.Bundle,
+		BundleSpecificProps: replaceTagsVars.BundleSpecificProps,
+		Properties:          propsInp,
+		Secrets:             secretsInp,
+	})
+}
+
+// secretNamespace returns namespace secrets are sealed for, same as used for resolution on Reliza Hub
+func secretNamespace(namespace string) string {
+	if len(namespace) <= 1 {
+		return "default"
+	}
+	return namespace
+}
+
+// parseLineToSecrets returns placeholders of line in order of appearance
+func parseLineToSecrets(line string) ([]PropSecretParse, error) {
+	var psp []PropSecretParse
+	if !strings.Contains(line, placeholderPrefix) {
+		return psp, nil
+	}
+	segments, err := tokenizePlaceholders(line)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments {
+		if segment.placeholder != nil {
+			psp = append(psp, *segment.placeholder)
+		}
+	}
+	return psp, nil
+}
+
+// withPlaceholderLocation sets file and line of placeholder syntax error, other errors are returned as is
+func withPlaceholderLocation(err error, fileName string, line int) error {
+	var syntaxErr *PlaceholderSyntaxError
+	if errors.As(err, &syntaxErr) {
+		syntaxErr.File = fileName
+		syntaxErr.Line = line
+	}
+	return err
+}
@@ -0,0 +1,400 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package replacetags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/reference"
+)
+
+// ErrEmptyComponents is returned when CycloneDX BOM used as tag source has no components
+var ErrEmptyComponents = errors.New("CycloneDX BOM components are empty")
+
+// TagSource is a single source of tags - tag source file, bundle version, environment or instance revision
+type TagSource struct {
+	File     string // tag source file
+	FileType string // type of tag source file: cyclonedx (default), spdx or text
+	Bundle   string
+	Version  string
+	// Environment is a tag source by itself, or, together with Bundle, selects latest bundle version approved for it
+	Environment string
+	Instance    string
+	InstanceURI string
+	Revision    string
+	Namespace   string
+}
+
+// Conflict policies decide which digested image is used when several tag sources have the same image
+const (
+	ConflictFirstWins = "first-wins"
+	ConflictLastWins  = "last-wins"
+	ConflictError     = "error"
+)
+
+// TagConflictError is returned with ConflictError policy when tag sources disagree on an image
+type TagConflictError struct {
+	Image   string
+	Values  []string
+	Sources []string
+}
+
+func (e *TagConflictError) Error() string {
+	var parts []string
+	for i := range e.Values {
+		parts = append(parts, e.Values[i]+" from "+e.Sources[i])
+	}
+	return fmt.Sprintf("tag sources conflict on image %s: %s", e.Image, strings.Join(parts, " and "))
+}
+
+// Kinds of TagSourceArg, named after command line flags
+const (
+	TagSourceArgFile   = "tagsource"
+	TagSourceArgBundle = "bundle"
+	TagSourceArgEnv    = "env"
+)
+
+// TagSourceArg is a tag source flag in the order it was given on command line
+type TagSourceArg struct {
+	Kind  string
+	Value string
+}
+
+// OrderTagSources builds tag sources in the order of args. Versions are bundle versions, either one for all bundles
+// or one per bundle. Environment given together with bundles without version only selects latest bundle versions
+// approved in it. Instance is a tag source only when args have no source, otherwise it is used to resolve
+// properties and secrets, as before tag sources could be merged
+func OrderTagSources(args []TagSourceArg, versions []string, fileType string, instance TagSource) ([]TagSource, error) {
+	var bundles, envs []string
+	for _, arg := range args {
+		switch arg.Kind {
+		case TagSourceArgBundle:
+			bundles = append(bundles, arg.Value)
+		case TagSourceArgEnv:
+			envs = append(envs, arg.Value)
+		}
+	}
+	if len(versions) > 1 && len(versions) != len(bundles) {
+		return nil, errors.New("specify either one version for all bundles or one version per bundle")
+	}
+	envSelectsVersion := len(bundles) > 0 && len(versions) == 0
+	if envSelectsVersion && len(envs) != 1 {
+		return nil, errors.New("bundles without version require exactly one environment to select approved bundle versions")
+	}
+
+	var sources []TagSource
+	bundleIndex := 0
+	for _, arg := range args {
+		switch arg.Kind {
+		case TagSourceArgFile:
+			sources = append(sources, TagSource{File: arg.Value, FileType: fileType})
+		case TagSourceArgBundle:
+			ts := TagSource{Bundle: arg.Value}
+			if envSelectsVersion {
+				ts.Environment = envs[0]
+			} else if len(versions) > 1 {
+				ts.Version = versions[bundleIndex]
+			} else {
+				ts.Version = versions[0]
+			}
+			bundleIndex++
+			sources = append(sources, ts)
+		case TagSourceArgEnv:
+			if !envSelectsVersion {
+				sources = append(sources, TagSource{Environment: arg.Value})
+			}
+		default:
+			return nil, errors.Errorf("unknown tag source kind %s", arg.Kind)
+		}
+	}
+	if len(sources) == 0 {
+		sources = append(sources, instance)
+	}
+	return sources, nil
+}
+
+// tagSources returns TagSources if set, otherwise a single source from legacy fields - either tag source file,
+// bundle, environment or instance, in this order of preference
+func tagSources(replaceTagsVars *ReplaceTagsVars) []TagSource {
+	if len(replaceTagsVars.TagSources) > 0 {
+		return replaceTagsVars.TagSources
+	}
+	ts := TagSource{Namespace: replaceTagsVars.Namespace}
+	if replaceTagsVars.TagSourceFile != "" {
+		ts.File = replaceTagsVars.TagSourceFile
+		ts.FileType = replaceTagsVars.TypeVal
+	} else if len(replaceTagsVars.Bundle) > 0 {
+		ts.Bundle = replaceTagsVars.Bundle
+		ts.Environment = replaceTagsVars.Environment
+		ts.Version = replaceTagsVars.Version
+	} else if len(replaceTagsVars.Environment) > 0 {
+		ts.Environment = replaceTagsVars.Environment
+	} else {
+		ts.Instance = replaceTagsVars.Instance
+		ts.InstanceURI = replaceTagsVars.InstanceURI
+		ts.Revision = replaceTagsVars.Revision
+	}
+	return []TagSource{ts}
+}
+
+// ScanTags constructs map of images without tags to digested images from all tag sources, in order of
+// TagSources, conflicts are resolved according to ConflictPolicy (first-wins by default)
+func ScanTags(ctx context.Context, replaceTagsVars ReplaceTagsVars) (map[string]string, error) {
+	tagSourceMap, _, err := scanTags(ctx, &replaceTagsVars)
+	return tagSourceMap, err
+}
+
+// scanTags does the same as ScanTags and also returns CycloneDX properties of components used for every image
+func scanTags(ctx context.Context, replaceTagsVars *ReplaceTagsVars) (map[string]string, map[string]map[string]string, error) {
+	policy := replaceTagsVars.ConflictPolicy
+	if len(policy) < 1 {
+		policy = ConflictFirstWins
+	}
+	if policy != ConflictFirstWins && policy != ConflictLastWins && policy != ConflictError {
+		return nil, nil, errors.Errorf("unsupported conflict policy %s, must be either %s, %s or %s", policy, ConflictFirstWins, ConflictLastWins, ConflictError)
+	}
+
+	tagSourceMap := map[string]string{}
+	componentProperties := map[string]map[string]string{}
+	origins := map[string]string{}
+	for _, ts := range tagSources(replaceTagsVars) {
+		sourceProperties := map[string]map[string]string{}
+		sourceMap, err := scanTagSource(ctx, replaceTagsVars, ts, sourceProperties)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, v := range sourceMap {
+			existing, exists := tagSourceMap[k]
+			if exists && existing != v {
+				switch policy {
+				case ConflictFirstWins:
+					replaceTagsVars.logf("Tag source %s: %s ignored, %s from %s is used\n", ts, v, existing, origins[k])
+					continue
+				case ConflictError:
+					return nil, nil, &TagConflictError{Image: k, Values: []string{existing, v}, Sources: []string{origins[k], ts.String()}}
+				}
+				replaceTagsVars.logf("Tag source %s: %s overrides %s from %s\n", ts, v, existing, origins[k])
+			} else if exists {
+				continue
+			}
+			tagSourceMap[k] = v
+			componentProperties[k] = sourceProperties[k]
+			origins[k] = ts.String()
+		}
+	}
+	return tagSourceMap, componentProperties, nil
+}
+
+func scanTagSource(ctx context.Context, replaceTagsVars *ReplaceTagsVars, ts TagSource, componentProperties map[string]map[string]string) (map[string]string, error) {
+	var cycloneBytes []byte
+	var err error
+	if ts.File != "" {
+		return scanTagFile(ts.File, ts.FileType, componentProperties)
+	} else if replaceTagsVars.Hub == nil {
+		return nil, ErrNoTagSource
+	} else if len(ts.Bundle) > 0 {
+		cycloneBytes, err = replaceTagsVars.Hub.BundleVersionCycloneDx(ctx, ts.Bundle, ts.Environment, ts.Version)
+	} else if len(ts.Environment) > 0 {
+		cycloneBytes, err = replaceTagsVars.Hub.EnvironmentCycloneDx(ctx, ts.Environment)
+	} else if len(ts.Instance) > 0 || len(ts.InstanceURI) > 0 || replaceTagsVars.Hub.IsInstanceKey() {
+		cycloneBytes, err = replaceTagsVars.Hub.InstanceRevisionCycloneDx(ctx, ts.Instance, ts.Revision, ts.InstanceURI, ts.Namespace)
+	} else {
+		return nil, ErrNoTagSource
+	}
+	if err != nil {
+		return nil, err
+	}
+	tagSourceMap := map[string]string{}
+	var bomJSON map[string]interface{}
+	json.Unmarshal(cycloneBytes, &bomJSON)
+	if err := extractCycloneBom(bomJSON, tagSourceMap, componentProperties); err != nil {
+		return nil, err
+	}
+	return tagSourceMap, nil
+}
+
+// ScanTagFile constructs tag source map from cyclonedx (JSON or XML), spdx (JSON or tag-value) or text file
+func ScanTagFile(tagSourceFile string, typeVal string) (map[string]string, error) {
+	return scanTagFile(tagSourceFile, typeVal, nil)
+}
+
+// scanTagFile does the same as ScanTagFile and records properties of CycloneDX components in componentProperties if it is not nil
+func scanTagFile(tagSourceFile string, typeVal string, componentProperties map[string]map[string]string) (map[string]string, error) {
+	content, err := os.ReadFile(tagSourceFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening tagSourceFile = %s", tagSourceFile)
+	}
+
+	tagSourceMap := map[string]string{}
+	trimmed := bytes.TrimSpace(content)
+
+	switch typeVal {
+	case "cyclonedx", "":
+		if bytes.HasPrefix(trimmed, []byte("<")) {
+			err = extractComponentsFromCycloneXML(trimmed, tagSourceMap, componentProperties)
+			break
+		}
+		var bomJSON map[string]interface{}
+		if err := json.Unmarshal(trimmed, &bomJSON); err != nil {
+			return nil, errors.Wrapf(err, "error reading tagSourceFile = %s", tagSourceFile)
+		}
+		err = extractCycloneBom(bomJSON, tagSourceMap, componentProperties)
+	case "spdx":
+		var packages []spdxPackage
+		if bytes.HasPrefix(trimmed, []byte("{")) {
+			packages, err = readSpdxJSON(trimmed)
+		} else {
+			packages, err = readSpdxTagValue(trimmed)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading tagSourceFile = %s", tagSourceFile)
+		}
+		extractSpdxPackages(packages, tagSourceMap)
+	case "text":
+		tagScanner := bufio.NewScanner(bytes.NewReader(content))
+		for tagScanner.Scan() {
+			line := tagScanner.Text()
+			parseImageNameIntoMap(line, tagSourceMap)
+		}
+		err = tagScanner.Err()
+	default:
+		return nil, errors.Errorf("unsupported tag source type %s, must be either cyclonedx, spdx or text", typeVal)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tagSourceMap, nil
+}
+
+// ExtractComponentsFromCycloneJSON adds container components of CycloneDX BOM to the tag source map,
+// including components nested in other components
+func ExtractComponentsFromCycloneJSON(bomJSON map[string]interface{}, tagSourceMap map[string]string) error {
+	return extractCycloneBom(bomJSON, tagSourceMap, nil)
+}
+
+func extractCycloneBom(bomJSON map[string]interface{}, tagSourceMap map[string]string, componentProperties map[string]map[string]string) error {
+	bomComponents, ok := bomJSON["components"].([]interface{})
+	if !ok {
+		return ErrEmptyComponents
+	}
+	extractCycloneComponents(bomComponents, tagSourceMap, componentProperties)
+	return nil
+}
+
+func extractCycloneComponents(bomComponents []interface{}, tagSourceMap map[string]string, componentProperties map[string]map[string]string) {
+	for _, bomcEntry := range bomComponents {
+		bomc, ok := bomcEntry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// containers may be nested in application or other components
+		if nested, ok := bomc["components"].([]interface{}); ok {
+			extractCycloneComponents(nested, tagSourceMap, componentProperties)
+		}
+		// check that type is container
+		if bomc["type"] != "container" {
+			continue
+		}
+
+		resolvedImage := false
+
+		// name is a mandatory field in cyclonedx spec
+		contName, _ := bomc["name"].(string)
+		// version field may contain the tag
+		contVersion, _ := bomc["version"].(string)
+		// Check if there's a containerSafeVersion property - use that instead for Docker tags
+		contProperties := map[string]string{}
+		if properties, ok := bomc["properties"].([]interface{}); ok {
+			for _, prop := range properties {
+				if propMap, ok := prop.(map[string]interface{}); ok {
+					propName, _ := propMap["name"].(string)
+					propValue, _ := propMap["value"].(string)
+					if _, seen := contProperties[propName]; !seen {
+						contProperties[propName] = propValue
+					}
+				}
+			}
+		}
+		if safeVersion, ok := contProperties["reliza:containerSafeVersion"]; ok {
+			contVersion = safeVersion
+		}
+
+		imageName := ""
+		// 1st try to parse purl if present
+		if purl, ok := bomc["purl"].(string); ok {
+			// sample purl pkg:docker/test-cont@sha256:testsha256hash?repository_url=123.dkr.ecr.us-east-1.amazonaws.com
+			if purlImageName, ok := imageFromPurl(purl); ok {
+				imageName = purlImageName
+				resolvedImage = true
+			}
+		}
+		if hashes, ok := bomc["hashes"].([]interface{}); !resolvedImage && ok {
+			// if purl is not set - use name and hash if present, but only if hashes contain SHA-256 algorithm
+			for _, hashEntry := range hashes {
+				hashMap, _ := hashEntry.(map[string]interface{})
+				alg, _ := hashMap["alg"].(string)
+				content, _ := hashMap["content"].(string)
+				if strings.Compare(alg, "SHA-256") == 0 {
+					// take name and attach hash, include version/tag if present
+					fullImageName := stripImageHashTag(contName)
+					if len(contVersion) > 0 {
+						fullImageName += ":" + contVersion
+					}
+					fullImageName += "@sha256:" + content
+					imageName = fullImageName
+					resolvedImage = true
+					break
+				}
+			}
+		}
+		if !resolvedImage {
+			// if both purl and hashes are not set - use only name and treat it same as text file case
+			imageName = contName
+		}
+		parseImageNameIntoMap(imageName, tagSourceMap)
+		if componentProperties != nil {
+			componentProperties[stripImageHashTag(imageName)] = contProperties
+		}
+	}
+}
+
+/**
+* This adds value into tag source map based on image name
+ */
+func parseImageNameIntoMap(imageName string, tagSourceMap map[string]string) {
+	strippedImageName := stripImageHashTag(imageName)
+	tagSourceMap[strippedImageName] = imageName
+}
+
+// stripImageHashTag returns normalized name of the image without tag and digest, i.e. docker.io/library/redis for redis:6,
+// invalid references are returned without scheme as is
+func stripImageHashTag(imageName string) string {
+	imageName = reference.TrimScheme(strings.TrimSpace(imageName))
+	ref, err := reference.ParseNormalized(imageName)
+	if err != nil {
+		return imageName
+	}
+	return ref.Name()
+}
@@ -0,0 +1,240 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+/*
+Package sealedsecret works with Bitnami sealed secrets. Secrets sealed by Reliza Hub
+for an instance are unsealed either by the sealed-secrets controller of the cluster,
+which is accessed through the Kubernetes API, or locally with the private key of the
+controller.
+*/
+package sealedsecret
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	DefaultTimeout      = 60 * time.Second
+	DefaultPollInterval = time.Second
+	// SecretKey is the key of encrypted data in SealedSecret and of data in the unsealed Secret
+	SecretKey = "secret"
+	// NamespaceWideAnnotation marks SealedSecret which may be unsealed under any name in its namespace
+	NamespaceWideAnnotation = "sealedsecrets.bitnami.com/namespace-wide"
+	// ClusterWideAnnotation marks SealedSecret which may be unsealed under any name in any namespace
+	ClusterWideAnnotation = "sealedsecrets.bitnami.com/cluster-wide"
+)
+
+// SealedSecretResource is the group version resource of SealedSecret custom resource
+var SealedSecretResource = schema.GroupVersionResource{Group: "bitnami.com", Version: "v1alpha1", Resource: "sealedsecrets"}
+
+// ErrUnsealTimeout is returned when the controller does not produce the Secret in time
+var ErrUnsealTimeout = errors.New("timed out waiting for sealed secrets controller to unseal secret")
+
+// Resolver unseals secrets by creating a temporary SealedSecret in the cluster and reading
+// the Secret produced from it by the sealed-secrets controller.
+type Resolver struct {
+	Client  kubernetes.Interface
+	Dynamic dynamic.Interface
+
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// Option configures optional Resolver settings.
+type Option func(*Resolver)
+
+// WithTimeout sets how long to wait for the controller to unseal a secret, 0 means DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(r *Resolver) {
+		if timeout > 0 {
+			r.timeout = timeout
+		}
+	}
+}
+
+// WithPollInterval sets how often the unsealed Secret is looked up while waiting.
+func WithPollInterval(interval time.Duration) Option {
+	return func(r *Resolver) {
+		if interval > 0 {
+			r.pollInterval = interval
+		}
+	}
+}
+
+// NewResolver returns Resolver using given clients, which may be fake clients in tests.
+func NewResolver(client kubernetes.Interface, dynamicClient dynamic.Interface, opts ...Option) *Resolver {
+	r := &Resolver{
+		Client:       client,
+		Dynamic:      dynamicClient,
+		timeout:      DefaultTimeout,
+		pollInterval: DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// NewResolverFromConfig returns Resolver connected to the cluster of kubeconfig file. When kubeconfig
+// is empty, in-cluster config is used if running inside a pod, otherwise KUBECONFIG or ~/.kube/config.
+func NewResolverFromConfig(kubeconfig string, opts ...Option) (*Resolver, error) {
+	config, err := restConfig(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating kubernetes client")
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating kubernetes client")
+	}
+	return NewResolver(client, dynamicClient, opts...), nil
+}
+
+func restConfig(kubeconfig string) (*rest.Config, error) {
+	if len(kubeconfig) == 0 && len(os.Getenv("KUBERNETES_SERVICE_HOST")) > 0 {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading in-cluster kubernetes config")
+		}
+		return config, nil
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{}).ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading kubeconfig")
+	}
+	return config, nil
+}
+
+// EnsureNamespace creates namespace unless it already exists.
+func (r *Resolver) EnsureNamespace(ctx context.Context, namespace string) error {
+	_, err := r.Client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "error getting namespace %s", namespace)
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	_, err = r.Client.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "error creating namespace %s", namespace)
+	}
+	return nil
+}
+
+// Unseal returns plain value of namespace-wide sealed secret. Temporary SealedSecret and the Secret
+// unsealed from it are always deleted, including when unsealing fails or times out.
+func (r *Resolver) Unseal(ctx context.Context, sealedSecret string, namespace string) (string, error) {
+	name := "plainsecret-" + uuid.New().String()
+	sealed := NewSealedSecret(name, namespace, sealedSecret)
+	_, err := r.Dynamic.Resource(SealedSecretResource).Namespace(namespace).Create(ctx, sealed, metav1.CreateOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "error creating sealed secret %s/%s", namespace, name)
+	}
+	defer r.cleanup(name, namespace)
+
+	var secret *corev1.Secret
+	err = wait.PollUntilContextTimeout(ctx, r.pollInterval, r.timeout, true, func(ctx context.Context) (bool, error) {
+		s, err := r.Client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		secret = s
+		return true, nil
+	})
+	if ctx.Err() != nil {
+		return "", errors.Wrapf(ctx.Err(), "waiting for secret %s/%s", namespace, name)
+	}
+	if wait.Interrupted(err) {
+		return "", errors.Wrapf(ErrUnsealTimeout, "secret %s/%s", namespace, name)
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "error getting secret %s/%s", namespace, name)
+	}
+	data, ok := secret.Data[SecretKey]
+	if !ok {
+		return "", errors.Errorf("secret %s/%s has no %s key", namespace, name, SecretKey)
+	}
+	return string(data), nil
+}
+
+// cleanup deletes temporary SealedSecret and its Secret, the latter is normally garbage collected
+// by owner reference, but is deleted explicitly so that plain value does not stay in the cluster.
+// Fresh context is used so that cleanup happens even when ctx of Unseal is already cancelled.
+func (r *Resolver) cleanup(name string, namespace string) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	r.Dynamic.Resource(SealedSecretResource).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	r.Client.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// NewSealedSecret returns namespace-wide SealedSecret with encrypted value under SecretKey,
+// labelled the same way as other resources managed by Reliza CD.
+func NewSealedSecret(name string, namespace string, encrypted string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "bitnami.com/v1alpha1",
+		"kind":       "SealedSecret",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+			"annotations": map[string]interface{}{
+				NamespaceWideAnnotation: "true",
+			},
+			"labels": cdLabels(name),
+		},
+		"spec": map[string]interface{}{
+			"encryptedData": map[string]interface{}{
+				SecretKey: encrypted,
+			},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"labels": cdLabels(name),
+				},
+			},
+		},
+	}}
+}
+
+func cdLabels(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"reliza.io/type": "cdresource",
+		"reliza.io/name": name,
+	}
+}
@@ -0,0 +1,271 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package sealedsecret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ScopeStrict values may only be unsealed under the same name and namespace
+	ScopeStrict = "strict"
+	// ScopeNamespaceWide values may be unsealed under any name in the same namespace
+	ScopeNamespaceWide = "namespace-wide"
+	// ScopeClusterWide values may be unsealed under any name in any namespace
+	ScopeClusterWide = "cluster-wide"
+
+	sessionKeyBytes = 32
+)
+
+// ErrDecrypt is returned when a value can not be decrypted with any of the sealing keys
+var ErrDecrypt = errors.New("sealed value can not be decrypted with sealing key")
+
+// ScopeLabel returns label which binds sealed value to its scope, it is used as OAEP label
+// of the session key, so a value sealed for one scope can not be unsealed in another.
+func ScopeLabel(scope string, namespace string, name string) ([]byte, error) {
+	switch scope {
+	case ScopeStrict:
+		if len(namespace) == 0 || len(name) == 0 {
+			return nil, errors.New("strict scope requires both namespace and name")
+		}
+		return []byte(namespace + "/" + name), nil
+	case ScopeNamespaceWide:
+		if len(namespace) == 0 {
+			return nil, errors.New("namespace-wide scope requires namespace")
+		}
+		return []byte(namespace), nil
+	case ScopeClusterWide:
+		return []byte{}, nil
+	}
+	return nil, errors.Errorf("unknown scope %s, must be strict, namespace-wide or cluster-wide", scope)
+}
+
+// HybridEncrypt encrypts plaintext the way sealed-secrets does: random AES-256 session key is encrypted with
+// RSA-OAEP (SHA-256) using label and prefixed with its 2-byte big-endian length, followed by plaintext encrypted
+// with AES-GCM. Session key is never reused, so the nonce is all zeros.
+func HybridEncrypt(rnd io.Reader, pubKey *rsa.PublicKey, plaintext []byte, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sessionKeyBytes)
+	if _, err := io.ReadFull(rnd, sessionKey); err != nil {
+		return nil, errors.Wrap(err, "error generating session key")
+	}
+	aed, err := newSessionCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+	rsaCiphertext, err := rsa.EncryptOAEP(sha256.New(), rnd, pubKey, sessionKey, label)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encrypting session key")
+	}
+	ciphertext := make([]byte, 2, 2+len(rsaCiphertext)+len(plaintext)+aed.Overhead())
+	binary.BigEndian.PutUint16(ciphertext, uint16(len(rsaCiphertext)))
+	ciphertext = append(ciphertext, rsaCiphertext...)
+	zeroNonce := make([]byte, aed.NonceSize())
+	return aed.Seal(ciphertext, zeroNonce, plaintext, nil), nil
+}
+
+// HybridDecrypt is the inverse of HybridEncrypt, every key is tried in turn since controller rotates sealing keys
+// and keeps the old ones for unsealing.
+func HybridDecrypt(keys []*rsa.PrivateKey, ciphertext []byte, label []byte) ([]byte, error) {
+	if len(ciphertext) < 2 {
+		return nil, errors.New("sealed value is too short")
+	}
+	rsaLen := int(binary.BigEndian.Uint16(ciphertext))
+	if len(ciphertext) < 2+rsaLen {
+		return nil, errors.New("sealed value is too short")
+	}
+	rsaCiphertext := ciphertext[2 : 2+rsaLen]
+	aesCiphertext := ciphertext[2+rsaLen:]
+	for _, key := range keys {
+		sessionKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, rsaCiphertext, label)
+		if err != nil {
+			continue
+		}
+		aed, err := newSessionCipher(sessionKey)
+		if err != nil {
+			return nil, err
+		}
+		zeroNonce := make([]byte, aed.NonceSize())
+		plaintext, err := aed.Open(nil, zeroNonce, aesCiphertext, nil)
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+		return plaintext, nil
+	}
+	return nil, ErrDecrypt
+}
+
+func newSessionCipher(sessionKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating session cipher")
+	}
+	aed, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating session cipher")
+	}
+	return aed, nil
+}
+
+// ParsePrivateKeys returns RSA keys of all PKCS#1 and PKCS#8 PEM blocks of data, i.e. of tls.key of
+// the sealed-secrets controller key secrets concatenated together.
+func ParsePrivateKeys(data []byte) ([]*rsa.PrivateKey, error) {
+	var keys []*rsa.PrivateKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing sealing key")
+			}
+			keys = append(keys, key)
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing sealing key")
+			}
+			key, ok := parsed.(*rsa.PrivateKey)
+			if !ok {
+				return nil, errors.New("sealing key is not an RSA key")
+			}
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RSA private key found in sealing key PEM")
+	}
+	return keys, nil
+}
+
+// LocalResolver unseals secrets offline with private keys of the sealed-secrets controller.
+type LocalResolver struct {
+	Keys []*rsa.PrivateKey
+}
+
+// NewLocalResolverFromFile returns LocalResolver with keys of PEM file.
+func NewLocalResolverFromFile(keyFile string) (*LocalResolver, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading sealing key %s", keyFile)
+	}
+	keys, err := ParsePrivateKeys(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading sealing key %s", keyFile)
+	}
+	return &LocalResolver{Keys: keys}, nil
+}
+
+// Unseal returns plain value of base64 encoded sealed value. Reliza Hub values are sealed
+// namespace-wide, values sealed cluster-wide are accepted as well, the same way the controller
+// would accept them in a namespace-wide SealedSecret annotated as cluster-wide.
+func (r *LocalResolver) Unseal(sealedSecret string, namespace string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(sealedSecret)
+	if err != nil {
+		return "", errors.Wrap(err, "sealed value is not base64 encoded")
+	}
+	for _, scope := range []string{ScopeNamespaceWide, ScopeClusterWide} {
+		label, err := ScopeLabel(scope, namespace, "")
+		if err != nil {
+			return "", err
+		}
+		plaintext, err := HybridDecrypt(r.Keys, ciphertext, label)
+		if err == nil {
+			return string(plaintext), nil
+		}
+		if !errors.Is(err, ErrDecrypt) {
+			return "", err
+		}
+	}
+	return "", errors.Wrapf(ErrDecrypt, "namespace %s", namespace)
+}
+
+// ParsePublicKey returns RSA public key of the first CERTIFICATE or PUBLIC KEY PEM block of data,
+// such as the certificate of sealed-secrets controller fetched with kubeseal --fetch-cert.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no certificate found in sealing cert PEM")
+		}
+		var parsed interface{}
+		switch block.Type {
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing sealing cert")
+			}
+			parsed = cert.PublicKey
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing sealing cert")
+			}
+			parsed = key
+		default:
+			continue
+		}
+		pubKey, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("sealing cert does not have an RSA public key")
+		}
+		return pubKey, nil
+	}
+}
+
+// ReadPublicKeyFile returns RSA public key of PEM certificate file.
+func ReadPublicKeyFile(certFile string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading sealing cert %s", certFile)
+	}
+	pubKey, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading sealing cert %s", certFile)
+	}
+	return pubKey, nil
+}
+
+// Seal returns base64 encoded value sealed with public key for scope, name is only used for strict scope.
+func Seal(pubKey *rsa.PublicKey, plaintext []byte, scope string, namespace string, name string) (string, error) {
+	label, err := ScopeLabel(scope, namespace, name)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := HybridEncrypt(rand.Reader, pubKey, plaintext, label)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext), nil
+}
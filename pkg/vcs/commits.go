@@ -0,0 +1,79 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+// Package vcs collects commit metadata either from git log dumps supplied by the user
+// or directly from a local git repository.
+package vcs
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CommitSeparator separates fields of a single commit in git log dumps,
+// i.e. git log --date=iso-strict --pretty='%H|||%ad|||%s|||%an|||%ae'
+const CommitSeparator = "|||"
+
+// Commit holds metadata of a single commit as expected by Reliza Hub
+type Commit struct {
+	Commit        string `json:"commit"`
+	DateActual    string `json:"dateActual"`
+	CommitMessage string `json:"commitMessage"`
+	CommitAuthor  string `json:"commitAuthor,omitempty"`
+	CommitEmail   string `json:"commitEmail,omitempty"`
+}
+
+// DecodeCommits decodes base64-encoded git log dump and parses commits from it
+func DecodeCommits(encoded string) ([]Commit, error) {
+	plainCommits, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "commits must be base64-encoded")
+	}
+	return ParseCommits(string(plainCommits))
+}
+
+// ParseCommits parses git log dump where every line is formatted as
+// hash|||date|||subject with optional |||author|||email suffix. Empty lines are skipped.
+func ParseCommits(log string) ([]Commit, error) {
+	var commits []Commit
+	for i, line := range strings.Split(log, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		commitParts := strings.Split(line, CommitSeparator)
+		if len(commitParts) < 3 {
+			return nil, errors.Errorf("malformed commit on line %d, expected at least hash, date and subject separated by %s: %s", i+1, CommitSeparator, line)
+		}
+		c := Commit{Commit: commitParts[0], DateActual: commitParts[1]}
+		if len(commitParts) >= 5 {
+			// subject may itself contain separator, author and email are always the last parts
+			c.CommitMessage = strings.Join(commitParts[2:len(commitParts)-2], CommitSeparator)
+			c.CommitAuthor = commitParts[len(commitParts)-2]
+			c.CommitEmail = commitParts[len(commitParts)-1]
+		} else {
+			c.CommitMessage = strings.Join(commitParts[2:], CommitSeparator)
+		}
+		if len(c.Commit) == 0 {
+			return nil, errors.Errorf("malformed commit on line %d, commit hash is empty", i+1)
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
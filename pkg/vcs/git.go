@@ -0,0 +1,163 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GitApp is the git binary used to read local repositories
+var GitApp = "git"
+
+// field and record separators used when reading git log, unlike ||| these never appear in commit subjects
+const (
+	fieldSep  = "\x1f"
+	recordSep = "\x1e"
+)
+
+// Repo is a local git repository
+type Repo struct {
+	Dir string
+}
+
+// OpenRepo verifies that dir is inside of a git work tree
+func OpenRepo(ctx context.Context, dir string) (*Repo, error) {
+	r := &Repo{Dir: dir}
+	if _, err := r.git(ctx, "rev-parse", "--git-dir"); err != nil {
+		return nil, errors.Wrapf(err, "%s is not a git repository", dir)
+	}
+	return r, nil
+}
+
+func (r *Repo) git(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, GitApp, args...)
+	cmd.Dir = r.Dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Errorf("git %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// Commits returns commits of the revision range, i.e. LAST..HEAD, newest first
+func (r *Repo) Commits(ctx context.Context, revisionRange string) ([]Commit, error) {
+	if err := checkRevision(revisionRange); err != nil {
+		return nil, err
+	}
+	return r.log(ctx, revisionRange)
+}
+
+// checkRevision rejects user supplied revisions which git would parse as options, i.e. --output=file
+func checkRevision(revision string) error {
+	if strings.HasPrefix(revision, "-") {
+		return errors.Errorf("invalid revision %q, must not start with -", revision)
+	}
+	return nil
+}
+
+// HeadCommit returns the commit currently checked out
+func (r *Repo) HeadCommit(ctx context.Context) (Commit, error) {
+	commits, err := r.log(ctx, "-1", "HEAD")
+	if err != nil {
+		return Commit{}, err
+	}
+	if len(commits) == 0 {
+		return Commit{}, errors.New("repository has no commits")
+	}
+	return commits[0], nil
+}
+
+// HasCommit checks whether commit is present in the local repository
+func (r *Repo) HasCommit(ctx context.Context, commit string) bool {
+	if checkRevision(commit) != nil {
+		return false
+	}
+	_, err := r.git(ctx, "cat-file", "-e", commit+"^{commit}")
+	return err == nil
+}
+
+func (r *Repo) log(ctx context.Context, args ...string) ([]Commit, error) {
+	gitArgs := append([]string{"log", "--date=iso-strict",
+		"--pretty=format:%H" + fieldSep + "%ad" + fieldSep + "%s" + fieldSep + "%an" + fieldSep + "%ae" + recordSep}, args...)
+	out, err := r.git(ctx, gitArgs...)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, record := range strings.Split(out, recordSep) {
+		record = strings.TrimLeft(record, "\n")
+		if len(record) == 0 {
+			continue
+		}
+		parts := strings.Split(record, fieldSep)
+		if len(parts) != 5 {
+			return nil, errors.Errorf("unexpected git log output: %q", record)
+		}
+		commits = append(commits, Commit{Commit: parts[0], DateActual: parts[1], CommitMessage: parts[2],
+			CommitAuthor: parts[3], CommitEmail: parts[4]})
+	}
+	return commits, nil
+}
+
+// TagsAt returns tags pointing at the revision
+func (r *Repo) TagsAt(ctx context.Context, revision string) ([]string, error) {
+	if err := checkRevision(revision); err != nil {
+		return nil, err
+	}
+	out, err := r.git(ctx, "tag", "--points-at", revision)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
+
+// RemoteURI returns uri of origin remote in the form used by Reliza Hub, i.e. github.com/relizaio/reliza-cli.
+// Empty string is returned if remote is not configured.
+func (r *Repo) RemoteURI(ctx context.Context) string {
+	out, err := r.git(ctx, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return ""
+	}
+	return NormalizeRemoteURI(strings.TrimSpace(out))
+}
+
+var scpLikeRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// NormalizeRemoteURI strips scheme, credentials and .git suffix from git remote url
+func NormalizeRemoteURI(remote string) string {
+	uri := remote
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+		if at := strings.Index(uri, "@"); at >= 0 && at < strings.Index(uri+"/", "/") {
+			uri = uri[at+1:]
+		}
+	} else if m := scpLikeRemote.FindStringSubmatch(uri); m != nil {
+		// git@github.com:relizaio/reliza-cli.git
+		uri = m[1] + "/" + m[2]
+	}
+	uri = strings.TrimSuffix(uri, "/")
+	return strings.TrimSuffix(uri, ".git")
+}
@@ -0,0 +1,8 @@
+{{- define "mafia.fullname" -}}
+{{- printf "%s-%s" .Release.Name .Chart.Name | trunc 63 | trimSuffix "-" -}}
+{{- end -}}
+
+{{/* image reference from repository, tag and digest, tag defaults to app version */}}
+{{- define "mafia.image" -}}
+{{- .image.repository }}:{{ .image.tag | default .appVersion }}{{ with .image.digest }}@{{ . }}{{ end -}}
+{{- end -}}
@@ -0,0 +1,140 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/ci"
+)
+
+func envFromMap(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestDetectCiProviders(t *testing.T) {
+	cases := []struct {
+		env      map[string]string
+		expected ci.Info
+	}{
+		{
+			env: map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "abc", "GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_REPOSITORY": "relizaio/reliza-cli", "GITHUB_RUN_ID": "42", "GITHUB_REF": "refs/pull/7/merge",
+				"GITHUB_REF_NAME": "7/merge", "GITHUB_HEAD_REF": "feature", "GITHUB_BASE_REF": "main"},
+			expected: ci.Info{Provider: ci.GitHub, Branch: "feature", VcsUri: "github.com/relizaio/reliza-cli", VcsType: "git",
+				BuildId: "42", BuildUri: "https://github.com/relizaio/reliza-cli/actions/runs/42", CiMeta: "GitHub Actions",
+				PullRequestNumber: "7", TargetBranch: "main"},
+		},
+		{
+			env: map[string]string{"GITLAB_CI": "true", "CI_COMMIT_SHA": "abc", "CI_COMMIT_TITLE": "fix", "CI_COMMIT_REF_NAME": "main",
+				"CI_COMMIT_BRANCH": "main", "CI_PROJECT_URL": "https://gitlab.com/relizaio/reliza-cli", "CI_JOB_ID": "5",
+				"CI_JOB_URL": "https://gitlab.com/relizaio/reliza-cli/-/jobs/5", "CI_JOB_STARTED_AT": "2024-07-06T10:00:00Z"},
+			expected: ci.Info{Provider: ci.GitLab, Branch: "main", Commit: "abc", CommitMessage: "fix", VcsUri: "gitlab.com/relizaio/reliza-cli",
+				VcsType: "git", BuildId: "5", BuildUri: "https://gitlab.com/relizaio/reliza-cli/-/jobs/5", CiMeta: "GitLab CI",
+				DateStart: "2024-07-06T10:00:00Z"},
+		},
+		{
+			env: map[string]string{"JENKINS_URL": "https://jenkins.local/", "GIT_COMMIT": "abc", "GIT_BRANCH": "origin/main",
+				"GIT_URL": "git@github.com:relizaio/reliza-cli.git", "BUILD_NUMBER": "3", "BUILD_URL": "https://jenkins.local/job/cli/3/"},
+			expected: ci.Info{Provider: ci.Jenkins, Branch: "main", Commit: "abc", VcsUri: "github.com/relizaio/reliza-cli", VcsType: "git",
+				BuildId: "3", BuildUri: "https://jenkins.local/job/cli/3/", CiMeta: "Jenkins"},
+		},
+		{
+			env: map[string]string{"TF_BUILD": "True", "BUILD_SOURCEVERSION": "abc", "BUILD_SOURCEBRANCH": "refs/tags/v1.0.0",
+				"BUILD_REPOSITORY_URI": "https://dev.azure.com/reliza/cli/_git/cli", "BUILD_BUILDID": "9",
+				"SYSTEM_COLLECTIONURI": "https://dev.azure.com/reliza/", "SYSTEM_TEAMPROJECT": "cli"},
+			expected: ci.Info{Provider: ci.Azure, VcsTag: "v1.0.0", Commit: "abc", VcsUri: "dev.azure.com/reliza/cli/_git/cli", VcsType: "git",
+				BuildId: "9", BuildUri: "https://dev.azure.com/reliza/cli/_build/results?buildId=9", CiMeta: "Azure Pipelines"},
+		},
+		{
+			env: map[string]string{"BITBUCKET_BUILD_NUMBER": "11", "BITBUCKET_COMMIT": "abc", "BITBUCKET_BRANCH": "feature",
+				"BITBUCKET_GIT_HTTP_ORIGIN": "http://bitbucket.org/relizaio/reliza-cli", "BITBUCKET_REPO_FULL_NAME": "relizaio/reliza-cli",
+				"BITBUCKET_PR_ID": "4", "BITBUCKET_PR_DESTINATION_BRANCH": "main"},
+			expected: ci.Info{Provider: ci.Bitbucket, Branch: "feature", Commit: "abc", VcsUri: "bitbucket.org/relizaio/reliza-cli", VcsType: "git",
+				BuildId: "11", BuildUri: "https://bitbucket.org/relizaio/reliza-cli/pipelines/results/11", CiMeta: "Bitbucket Pipelines",
+				PullRequestNumber: "4", TargetBranch: "main"},
+		},
+		{
+			env: map[string]string{"CIRCLECI": "true", "CIRCLE_SHA1": "abc", "CIRCLE_BRANCH": "main",
+				"CIRCLE_REPOSITORY_URL": "git@github.com:relizaio/reliza-cli.git", "CIRCLE_BUILD_NUM": "8",
+				"CIRCLE_BUILD_URL": "https://circleci.com/gh/relizaio/reliza-cli/8", "CIRCLE_PULL_REQUEST": "https://github.com/relizaio/reliza-cli/pull/12"},
+			expected: ci.Info{Provider: ci.CircleCI, Branch: "main", Commit: "abc", VcsUri: "github.com/relizaio/reliza-cli", VcsType: "git",
+				BuildId: "8", BuildUri: "https://circleci.com/gh/relizaio/reliza-cli/8", CiMeta: "CircleCI", PullRequestNumber: "12"},
+		},
+	}
+	for _, c := range cases {
+		info, ok := ci.Detect(envFromMap(c.env))
+		if !ok {
+			t.Fatalf("provider %s not detected", c.expected.Provider)
+		}
+		if info != c.expected {
+			t.Fatalf("provider %s: expected %+v, actual %+v", c.expected.Provider, c.expected, info)
+		}
+	}
+}
+
+func TestCiInfoWithoutCommit(t *testing.T) {
+	env := envFromMap(map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "mergesha", "GITHUB_SERVER_URL": "https://github.com",
+		"GITHUB_REPOSITORY": "relizaio/reliza-cli", "GITHUB_REF": "refs/heads/feature", "GITHUB_REF_NAME": "feature"})
+	info, ok := ci.Detect(env)
+	if !ok || info.Commit != "mergesha" {
+		t.Fatalf("unexpected info = %+v", info)
+	}
+	info = info.WithoutCommit()
+	if info.Commit != "" || info.CommitMessage != "" {
+		t.Errorf("expected commit to be dropped when commit list is supplied, got %+v", info)
+	}
+	if info.Branch != "feature" || info.VcsUri != "github.com/relizaio/reliza-cli" {
+		t.Errorf("expected other metadata to be kept, got %+v", info)
+	}
+}
+
+func TestGithubPullRequestCommit(t *testing.T) {
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	os.WriteFile(eventPath, []byte(`{"number": 7, "pull_request": {"head": {"ref": "feature", "sha": "headsha"}, "merge_commit_sha": "mergesha"}}`), 0644)
+	env := map[string]string{"GITHUB_ACTIONS": "true", "GITHUB_SHA": "mergesha", "GITHUB_REF": "refs/pull/7/merge",
+		"GITHUB_HEAD_REF": "feature", "GITHUB_BASE_REF": "main", "GITHUB_EVENT_PATH": eventPath}
+	info, _ := ci.Detect(envFromMap(env))
+	if info.Commit != "headsha" || info.PullRequestNumber != "7" {
+		t.Fatalf("expected head commit of pull request, got %+v", info)
+	}
+
+	// synthetic merge commit must never be used as source code commit
+	env["GITHUB_EVENT_PATH"] = filepath.Join(t.TempDir(), "missing.json")
+	if info, _ := ci.Detect(envFromMap(env)); info.Commit != "" {
+		t.Fatalf("expected commit to be unset without event payload, got %+v", info)
+	}
+}
+
+func TestCiProviderOverride(t *testing.T) {
+	if _, ok := ci.Detect(envFromMap(map[string]string{})); ok {
+		t.Fatalf("no provider must be detected in empty environment")
+	}
+	env := envFromMap(map[string]string{"GIT_COMMIT": "abc", "BRANCH_NAME": "main"})
+	info, err := ci.FromProvider("Jenkins", env)
+	if err != nil || info.Provider != ci.Jenkins || info.Commit != "abc" || info.Branch != "main" {
+		t.Fatalf("unexpected info = %+v, err = %v", info, err)
+	}
+	if _, err := ci.FromProvider("travis", env); err == nil {
+		t.Fatalf("expected unsupported provider error")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func composeVars(infile string) replacetags.ReplaceTagsVars {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = infile
+	replaceTagsVars.ParseMode = "compose"
+	return replaceTagsVars
+}
+
+func TestReplaceTagsCompose(t *testing.T) {
+	for _, tc := range []struct {
+		emitEnv  bool
+		expected string
+	}{
+		{emitEnv: false, expected: "compose/expected_docker-compose.yaml"},
+		{emitEnv: true, expected: "compose/expected.env"},
+	} {
+		replaceTagsVars := composeVars("compose/docker-compose.yaml")
+		replaceTagsVars.EmitEnv = tc.emitEnv
+		replaced, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+		if err != nil {
+			t.Fatalf("replace tags failed: %v", err)
+		}
+		expected, err := os.ReadFile(tc.expected)
+		if err != nil {
+			t.Fatalf("failed reading expected file")
+		}
+		if replaced != string(expected) {
+			t.Fatalf("%s does not match, actual = %s", tc.expected, replaced)
+		}
+	}
+}
+
+func TestReplaceTagsComposeEnvPrecedence(t *testing.T) {
+	// environment takes precedence over .env, image pointing to another registry is left as is
+	replaceTagsVars := composeVars("compose/docker-compose.yaml")
+	replaceTagsVars.EmitEnv = true
+	replaceTagsVars.Report = &replacetags.Report{}
+	replaceTagsVars.LookupEnv = func(key string) (string, bool) {
+		if key == "REGISTRY" {
+			return "registry.example.com", true
+		}
+		return "", false
+	}
+	replaced, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	if !strings.Contains(replaced, "export BACKEND_TAG=latest\n") {
+		t.Fatalf("backend tag must not be pinned, actual = %s", replaced)
+	}
+	unresolved := replaceTagsVars.Report.Files[0].Unresolved
+	if len(unresolved) != 2 || unresolved[0].Reference != "registry.example.com/taleodor/mafia-express:latest" || unresolved[0].Line != 9 {
+		t.Fatalf("unexpected unresolved images %+v", unresolved)
+	}
+}
+
+func TestReplaceTagsComposeRequiredVariable(t *testing.T) {
+	infile := filepath.Join(t.TempDir(), "compose.yaml")
+	content := "services:\n  app:\n    image: taleodor/mafia-vue:${TAG:?TAG must be set}\n"
+	if err := os.WriteFile(infile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := replacetags.ReplaceTags(context.Background(), composeVars(infile))
+	if err == nil || !strings.Contains(err.Error(), "TAG must be set") || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected required variable error, got %v", err)
+	}
+}
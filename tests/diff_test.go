@@ -0,0 +1,294 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/diff"
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	expected := `--- a/f
++++ b/f
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if actual := diff.Unified("a/f", "b/f", a, b); actual != expected {
+		t.Fatalf("unexpected diff:\n%s", actual)
+	}
+	if actual := diff.Unified("a/f", "b/f", a, a); actual != "" {
+		t.Fatalf("expected empty diff for equal input, got:\n%s", actual)
+	}
+	if actual := diff.Unified("/dev/null", "b/f", "", "x\n"); actual != "--- /dev/null\n+++ b/f\n@@ -0,0 +1 @@\n+x\n" {
+		t.Fatalf("unexpected diff for new file:\n%s", actual)
+	}
+}
+
+// applyUnified applies unified diff to a, checking that context and deleted lines match, and counts changed lines
+func applyUnified(t *testing.T, a string, unified string) (string, int) {
+	aLines := diff.SplitLines(a)
+	var out []string
+	pos, changes := 0, 0
+	for _, line := range diff.SplitLines(unified) {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+		case strings.HasPrefix(line, "@@"):
+			var aStart, aCount int
+			if _, err := fmt.Sscanf(strings.TrimPrefix(line, "@@ -"), "%d,%d", &aStart, &aCount); err != nil {
+				aCount = 1
+			}
+			if aCount > 0 || aStart > 0 {
+				if aCount == 0 {
+					aStart++
+				}
+				aStart--
+			}
+			out = append(out, aLines[pos:aStart]...)
+			pos = aStart
+		case strings.HasPrefix(line, "+"):
+			out = append(out, line[1:])
+			changes++
+		default:
+			if aLines[pos] != line[1:] {
+				t.Fatalf("diff line %q does not match %q", line, aLines[pos])
+			}
+			if line[0] == ' ' {
+				out = append(out, line[1:])
+			} else {
+				changes++
+			}
+			pos++
+		}
+	}
+	out = append(out, aLines[pos:]...)
+	return strings.Join(out, "\n"), changes
+}
+
+func lcsLength(a []string, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else if prev[j+1] > cur[j] {
+				cur[j+1] = prev[j+1]
+			} else {
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestUnifiedDiffIsMinimal(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		lines := make([]string, rnd.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + rnd.Intn(4)))
+		}
+		return strings.Join(lines, "\n")
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomText(), randomText()
+		applied, changes := applyUnified(t, a, diff.Unified("a", "b", a, b))
+		if applied != b {
+			t.Fatalf("diff of %q and %q does not produce b, got %q", a, b, applied)
+		}
+		aLines, bLines := diff.SplitLines(a), diff.SplitLines(b)
+		if minimal := len(aLines) + len(bLines) - 2*lcsLength(aLines, bLines); changes != minimal {
+			t.Fatalf("diff of %q and %q has %d changed lines, expected %d", a, b, changes, minimal)
+		}
+	}
+}
+
+func TestUnifiedDiffOfLargeFiles(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&a, "a%d\n", i)
+		fmt.Fprintf(&b, "b%d\n", i)
+	}
+	if out := diff.Unified("a", "b", a.String(), b.String()); strings.Count(out, "\n-a") != 20000 {
+		t.Fatalf("expected all lines to be replaced")
+	}
+	if out := diff.Unified("/dev/null", "b", "", b.String()); strings.Count(out, "\n+b") != 20000 {
+		t.Fatalf("expected all lines to be added")
+	}
+}
+
+// BenchmarkUnifiedDiffOfLargeFiles diffs large files with scattered changes, which need the most bisect steps
+func BenchmarkUnifiedDiffOfLargeFiles(b *testing.B) {
+	var before, after strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&before, "line %d\n", i)
+		if i%7 == 0 {
+			fmt.Fprintf(&after, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&after, "line %d\n", i)
+		}
+	}
+	for i := 0; i < b.N; i++ {
+		diff.Unified("a", "b", before.String(), after.String())
+	}
+}
+
+func TestPlanReplaceTags(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = "values_mafia.yaml"
+	replaceTagsVars.Provenance = true
+
+	changes, err := replacetags.PlanReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if len(changes) != 1 || !changes[0].Changed() {
+		t.Fatalf("expected single changed file, got %+v", changes)
+	}
+	changeDiff := changes[0].Diff()
+	if !strings.Contains(changeDiff, "-  image: taleodor/mafia-express:latest\n") ||
+		!strings.Contains(changeDiff, "+  image: docker.io/taleodor/mafia-express:21.08.3@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d\n") {
+		t.Fatalf("unexpected diff:\n%s", changeDiff)
+	}
+
+	// outfile already up to date, only provenance timestamp would differ
+	outfile := filepath.Join(t.TempDir(), "values.yaml")
+	replaceTagsVars.Outfile = outfile
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	changes, err = replacetags.PlanReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if changes[0].Changed() || changes[0].Diff() != "" {
+		t.Fatalf("expected no change, got diff:\n%s", changes[0].Diff())
+	}
+	written, _ := os.ReadFile(outfile)
+	if changes[0].Before != string(written) {
+		t.Fatalf("plan must not modify outfile")
+	}
+}
+
+func TestPlanReplaceTagsDirectory(t *testing.T) {
+	indir := t.TempDir()
+	for _, f := range []string{"values_mafia.yaml", "expected_values_mafia.yaml"} {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(indir, f), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.ParseMode = "ast"
+	replaceTagsVars.Indirectory = indir
+	replaceTagsVars.Outdirectory = indir
+
+	changes, err := replacetags.PlanReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	changed := map[string]bool{}
+	for _, c := range changes {
+		changed[filepath.Base(c.Outfile)] = c.Changed()
+	}
+	if len(changed) != 2 || !changed["values_mafia.yaml"] || changed["expected_values_mafia.yaml"] {
+		t.Fatalf("unexpected changes %v", changed)
+	}
+}
+
+func TestPlanReplaceTagsExistingAndNewOutdirectory(t *testing.T) {
+	indir := t.TempDir()
+	for _, f := range []string{"values_mafia.yaml", "expected_values_mafia.yaml"} {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(indir, f), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.ParseMode = "ast"
+	replaceTagsVars.Indirectory = indir
+	replaceTagsVars.Outdirectory = filepath.Join(t.TempDir(), "out")
+
+	// new outdirectory, files are compared with input, unchanged ones are not reported as added
+	changes, err := replacetags.PlanReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	for _, c := range changes {
+		expectChange := filepath.Base(c.Infile) == "values_mafia.yaml"
+		if c.Changed() != expectChange || c.Exists {
+			t.Errorf("unexpected change of %s: %+v", c.Infile, c)
+		}
+		if expectChange && !strings.HasPrefix(c.Diff(), "--- a/"+filepath.ToSlash(c.Infile)+"\n") {
+			t.Errorf("expected diff against input, got:\n%s", c.Diff())
+		}
+	}
+
+	// existing outdirectory does not require force for planning, its files are compared with
+	replaceTagsVars.Force = true
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	replaceTagsVars.Force = false
+	changes, err = replacetags.PlanReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("plan of existing outdirectory failed: %v", err)
+	}
+	for _, c := range changes {
+		if !c.Exists || c.Changed() {
+			t.Errorf("expected up to date %s, got diff:\n%s", c.Outfile, c.Diff())
+		}
+	}
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err == nil {
+		t.Error("expected writing to existing outdirectory without force to fail")
+	}
+}
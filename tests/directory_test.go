@@ -0,0 +1,230 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/hub"
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestReplaceTagsOnDirectoryFilter(t *testing.T) {
+	indir := t.TempDir()
+	outdir := filepath.Join(t.TempDir(), "out")
+	image := "image: taleodor/mafia-express:latest\n"
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"values.yaml":       {image, 0644},
+		"sub/deploy.yaml":   {image, 0644},
+		"sub/notes.txt":     {image, 0644},
+		"README.md":         {image, 0644},
+		"hooks/pre.sh":      {image, 0755},
+		".git/config":       {image, 0644},
+		"charts/redis.tgz":  {"\x1f\x8b\x08\x00" + image, 0644},
+		".relizaignore":     {"# docs are not manifests\n*.md\n.git/\n", 0644},
+		"values-extra.yaml": {image, 0600},
+	}
+	for name, f := range files {
+		path := filepath.Join(indir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Indirectory = indir
+	replaceTagsVars.Outdirectory = outdir
+	replaceTagsVars.Include = []string{"*.yaml", "*.sh", "*.tgz"}
+	replaceTagsVars.Exclude = []string{"hooks/"}
+
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	parsed := map[string]bool{"values.yaml": true, "sub/deploy.yaml": true, "values-extra.yaml": true}
+	skipped := map[string]bool{".git/config": true, ".relizaignore": true}
+	for name, f := range files {
+		out, err := os.ReadFile(filepath.Join(outdir, name))
+		if skipped[name] {
+			if !os.IsNotExist(err) {
+				t.Fatalf("%s must not be written to output directory", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s is not written to output directory: %v", name, err)
+		}
+		replaced := strings.Contains(string(out), "@sha256:")
+		if replaced != parsed[name] {
+			t.Fatalf("%s: expected parsed = %v, got content %q", name, parsed[name], out)
+		}
+		if !parsed[name] && string(out) != f.content {
+			t.Fatalf("%s is not copied verbatim", name)
+		}
+		fileInfo, _ := os.Stat(filepath.Join(outdir, name))
+		if fileInfo.Mode().Perm() != f.mode {
+			t.Fatalf("%s: expected mode %v, got %v", name, f.mode, fileInfo.Mode().Perm())
+		}
+	}
+
+	// second run needs force since output directory exists now
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err == nil {
+		t.Fatalf("expected error on existing output directory")
+	}
+	replaceTagsVars.Force = true
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags with force failed: %v", err)
+	}
+}
+
+func TestReplaceTagsOnFileKeepsMode(t *testing.T) {
+	dir := t.TempDir()
+	infile := filepath.Join(dir, "deploy.sh")
+	os.WriteFile(infile, []byte("docker run taleodor/mafia-express:latest\n"), 0755)
+	os.Chmod(infile, 0755)
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = infile
+	replaceTagsVars.Outfile = filepath.Join(dir, "pinned.sh")
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	replaceTagsVars.Outfile = infile
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags in place failed: %v", err)
+	}
+	for _, name := range []string{"pinned.sh", "deploy.sh"} {
+		fileInfo, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fileInfo.Mode().Perm() != 0755 {
+			t.Fatalf("%s: expected mode 0755, got %v", name, fileInfo.Mode().Perm())
+		}
+	}
+}
+
+// writeDirectoryFiles creates count files in subdirectories of dir with given content
+func writeDirectoryFiles(t *testing.T, dir string, count int, content func(i int) string) {
+	for i := 0; i < count; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("app%02d", i/10), fmt.Sprintf("values%03d.yaml", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content(i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReplaceTagsOnDirectoryParallel(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/manual/v1/fetchCsrf" {
+			http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "testsession"})
+			w.Write([]byte(`{"token":"testtoken"}`))
+			return
+		}
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"data":{"getInstancePropSecrets":{"properties":[{"key":"FQDN","value":"mafia.example.com"}],"secrets":[]}}}`))
+	}))
+	defer server.Close()
+
+	indir := t.TempDir()
+	writeDirectoryFiles(t, indir, 60, func(i int) string {
+		return "host: $RELIZA{PROPERTY.FQDN}\nimage: taleodor/mafia-express:latest\n"
+	})
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Indirectory = indir
+	replaceTagsVars.Outdirectory = filepath.Join(t.TempDir(), "out")
+	replaceTagsVars.ResolveProps = true
+	replaceTagsVars.Instance = "instance"
+	replaceTagsVars.Hub = hub.NewClient(server.URL, "id", "key")
+	replaceTagsVars.Workers = 8
+	replaceTagsVars.Report = &replacetags.Report{}
+
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected single Reliza Hub call for all files, got %d", calls)
+	}
+	files := replaceTagsVars.Report.Files
+	if len(files) != 60 {
+		t.Fatalf("expected report of 60 files, got %d", len(files))
+	}
+	for i, fr := range files {
+		expectedInfile := filepath.Join(indir, fmt.Sprintf("app%02d", i/10), fmt.Sprintf("values%03d.yaml", i))
+		if fr.Infile != expectedInfile {
+			t.Fatalf("report is not in walk order, expected %s at %d, got %s", expectedInfile, i, fr.Infile)
+		}
+		out, _ := os.ReadFile(fr.Outfile)
+		if !strings.Contains(string(out), "host: mafia.example.com") || !strings.Contains(string(out), "@sha256:") {
+			t.Fatalf("%s is not replaced: %s", fr.Outfile, out)
+		}
+	}
+}
+
+func TestReplaceTagsOnDirectoryAggregatesErrors(t *testing.T) {
+	indir := t.TempDir()
+	writeDirectoryFiles(t, indir, 5, func(i int) string {
+		if i%2 == 1 {
+			return "host: $RELIZA{PROPERTY.MISSING}\n"
+		}
+		return "image: taleodor/mafia-express:latest\n"
+	})
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Indirectory = indir
+	replaceTagsVars.Outdirectory = filepath.Join(t.TempDir(), "out")
+	replaceTagsVars.Workers = 3
+
+	_, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	var dirErr *replacetags.DirectoryError
+	if !errors.As(err, &dirErr) || len(dirErr.Errors) != 2 {
+		t.Fatalf("expected 2 aggregated errors, got %v", err)
+	}
+	var propErr *replacetags.MissingPropertyError
+	if !errors.As(dirErr.Errors[0], &propErr) || !strings.HasSuffix(propErr.File, "values001.yaml") {
+		t.Fatalf("expected missing property error of values001.yaml first, got %v", dirErr.Errors[0])
+	}
+}
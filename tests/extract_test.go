@@ -0,0 +1,101 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestExtractTagSourceRoundTrip(t *testing.T) {
+	expected, err := replacetags.ScanTagFile("mafia_tag_source_cdx.json", "cyclonedx")
+	if err != nil {
+		t.Fatalf("failed scanning tag source: %v", err)
+	}
+	// plain image keys and bitnami blocks pinned by replacetags must give back the tag source
+	for _, infile := range []string{"expected_values_mafia.yaml", "expected_values_mafia_bitnami_style.yaml"} {
+		var replaceTagsVars replacetags.ReplaceTagsVars
+		replaceTagsVars.Infile = infile
+		replaceTagsVars.Outfile = filepath.Join(t.TempDir(), "bom.json")
+
+		if _, err := replacetags.ExtractTagSource(context.Background(), replaceTagsVars); err != nil {
+			t.Fatalf("%s: extract failed: %v", infile, err)
+		}
+		extracted, err := replacetags.ScanTagFile(replaceTagsVars.Outfile, "cyclonedx")
+		if err != nil {
+			t.Fatalf("%s: extracted bom is not a valid tag source: %v", infile, err)
+		}
+		if len(extracted) != len(expected) {
+			t.Fatalf("%s: expected %d images, got %v", infile, len(expected), extracted)
+		}
+		for k, v := range expected {
+			if replacetags.GetDigestedImageFromSubstitution(replacetags.GetSubstitutionFromDigestedString(extracted[k])) != v {
+				t.Fatalf("%s: expected %s for %s, got %s", infile, v, k, extracted[k])
+			}
+		}
+	}
+}
+
+func TestExtractTagSourceDirectory(t *testing.T) {
+	digestA := strings.Repeat("a", 64)
+	dir := t.TempDir()
+	files := map[string]string{
+		"app/deployment.yaml": "spec:\n  containers:\n    - image: ghcr.io/org/app:1.0@sha256:" + digestA + "\n    - image: \"{{ .Values.image }}\"\n",
+		"app/values.yaml":     "images:\n  - quay.io/org/sidecar:2.1\nimage:\n  repository: $RELIZA{PROPERTY.REPO}\n",
+		"task.json":           `{"containerDefinitions": [{"image": "nginx:1.25"}]}`,
+		"skip/ignored.yaml":   "image: busybox:1.36\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.Indirectory = dir
+	replaceTagsVars.Exclude = []string{"skip/"}
+	replaceTagsVars.Outfile = filepath.Join(t.TempDir(), "bom.json")
+	if _, err := replacetags.ExtractTagSource(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	extracted, err := replacetags.ScanTagFile(replaceTagsVars.Outfile, "cyclonedx")
+	if err != nil {
+		t.Fatalf("extracted bom is not a valid tag source: %v", err)
+	}
+	expected := map[string]string{
+		"ghcr.io/org/app":         "ghcr.io/org/app:1.0@sha256:" + digestA,
+		"quay.io/org/sidecar":     "quay.io/org/sidecar:2.1",
+		"docker.io/library/nginx": "library/nginx:1.25",
+	}
+	if len(extracted) != len(expected) {
+		t.Fatalf("expected %d images, got %v", len(expected), extracted)
+	}
+	for k, v := range expected {
+		if extracted[k] != v {
+			t.Fatalf("expected %s for %s, got %s", v, k, extracted[k])
+		}
+	}
+}
@@ -0,0 +1,175 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/helm"
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestHelmRender(t *testing.T) {
+	chart, err := helm.LoadChart("chart/mafia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := helm.Render(chart, nil, helm.ReleaseOptions{Name: "test", Namespace: "games"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if _, ok := rendered["mafia/charts/redis/templates/statefulset.yaml"]; ok {
+		t.Fatalf("redis is disabled by default and must not be rendered")
+	}
+	deployment := rendered["mafia/templates/deployment.yaml"]
+	for _, expected := range []string{"name: test-mafia", "namespace: games", "replicas: 1",
+		`image: "taleodor/mafia-express:21.08.3"`, "image: taleodor/mafia-vue:latest"} {
+		if !strings.Contains(deployment, expected) {
+			t.Fatalf("rendered deployment does not contain %s:\n%s", expected, deployment)
+		}
+	}
+
+	values, err := helm.MergeValues([]string{"values-prod.yaml"}, "chart/mafia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err = helm.Render(chart, values, helm.ReleaseOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(rendered["mafia/charts/redis/templates/statefulset.yaml"], "image: docker.io/library/redis:6.2.4") {
+		t.Fatalf("redis subchart is not rendered with its defaults:\n%s", helm.Manifests(rendered))
+	}
+}
+
+func TestHelmRenderFunctions(t *testing.T) {
+	dir := t.TempDir()
+	chartYaml := "apiVersion: v2\nname: funcs\nversion: 1.0.0\n"
+	valuesYaml := "name: Mafia Game\nlist: [b, a, b]\nnested:\n  key: value\n"
+	template := `name: {{ .Values.name | lower | replace " " "-" | quote }}
+missing: {{ .Values.missing | default "fallback" }}
+sorted: {{ .Values.list | uniq | sortAlpha | join "," }}
+dig: {{ dig "nested" "key" "none" .Values }}
+kube: {{ semverCompare ">=1.19.0-0" .Capabilities.KubeVersion.Version }}
+old: {{ semverCompare "~1.18.0 || <1.10" "1.19.2" }}
+b64: {{ "reliza" | b64enc | b64dec }}
+yaml:
+{{- toYaml .Values.nested | nindent 2 }}
+tpl: {{ tpl "{{ .Values.name }}" . }}
+`
+	files := map[string]string{"Chart.yaml": chartYaml, "values.yaml": valuesYaml, "templates/cm.yaml": template}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chart, err := helm.LoadChart(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rendered, err := helm.Render(chart, nil, helm.ReleaseOptions{})
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	expected := `name: "mafia-game"
+missing: fallback
+sorted: a,b
+dig: value
+kube: true
+old: false
+b64: reliza
+yaml:
+  key: value
+tpl: Mafia Game
+`
+	if actual := rendered["funcs/templates/cm.yaml"]; actual != expected {
+		t.Fatalf("rendered template does not equal expected, actual = %s", actual)
+	}
+}
+
+func TestReplaceTagsChart(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Chart = "chart/mafia"
+	replaceTagsVars.ValueFiles = []string{"values-prod.yaml"}
+	replaceTagsVars.Report = &replacetags.Report{}
+
+	actual, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected, err := os.ReadFile("chart/expected_values_override.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected override values")
+	}
+	if actual != string(expected) {
+		t.Fatalf("override values do not equal expected, actual = %s", actual)
+	}
+
+	// busybox is not in tag source, so it stays unpinned in rendered deployment
+	var unresolved []string
+	for _, fr := range replaceTagsVars.Report.Files {
+		if fr.Infile == "mafia/templates/deployment.yaml" {
+			for _, u := range fr.Unresolved {
+				unresolved = append(unresolved, u.Reference)
+			}
+		}
+	}
+	if len(unresolved) != 1 || unresolved[0] != "busybox:1.36" {
+		t.Fatalf("expected busybox:1.36 to be unresolved, got %v", unresolved)
+	}
+
+	replaceTagsVars.Report = nil
+	replaceTagsVars.ParseMode = "strict"
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err == nil {
+		t.Fatalf("expected strict mode to fail on unpinned busybox image")
+	}
+}
+
+func TestReplaceTagsChartKeepsPlaceholders(t *testing.T) {
+	secretValues := filepath.Join(t.TempDir(), "values-secret.yaml")
+	os.WriteFile(secretValues, []byte("token: $RELIZA{ENV.MAFIA_TOKEN}\n"), 0644)
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Chart = "chart/mafia"
+	replaceTagsVars.ValueFiles = []string{"values-prod.yaml", secretValues}
+	replaceTagsVars.LookupEnv = placeholderEnv
+
+	actual, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected, err := os.ReadFile("chart/expected_values_override.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected override values")
+	}
+	// resolved secret must not be written to overrides, unchanged placeholder is not written either
+	if actual != string(expected) {
+		t.Fatalf("override values do not equal expected, actual = %s", actual)
+	}
+}
@@ -0,0 +1,153 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/machinebox/graphql"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+)
+
+func newTestHubServer(failures int32, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/manual/v1/fetchCsrf" {
+			http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "testsession"})
+			w.Write([]byte(`{"token":"testtoken"}`))
+			return
+		}
+		if atomic.AddInt32(calls, 1) <= failures {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("X-CSRF-Token") != "testtoken" || r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"data":{"isApprovalNeeded":true}}`))
+	}))
+}
+
+func TestHubClientRetriesOn5xx(t *testing.T) {
+	var calls int32
+	server := newTestHubServer(2, &calls)
+	defer server.Close()
+
+	client := hub.NewClient(server.URL, "id", "key", hub.WithRetries(2), hub.WithBackoff(time.Millisecond))
+	resp, err := client.Query(context.Background(), graphql.NewRequest(`query { isApprovalNeeded }`), "isApprovalNeeded")
+	if err != nil {
+		t.Fatalf("expected request to succeed after retries, got %v", err)
+	}
+	if resp != "true" || calls != 3 {
+		t.Fatalf("unexpected response = %s after %d calls", resp, calls)
+	}
+}
+
+func TestHubClientGivesUpAfterRetries(t *testing.T) {
+	var calls int32
+	server := newTestHubServer(10, &calls)
+	defer server.Close()
+
+	client := hub.NewClient(server.URL, "id", "key", hub.WithRetries(1), hub.WithBackoff(time.Millisecond))
+	_, err := client.Query(context.Background(), graphql.NewRequest(`query { isApprovalNeeded }`), "isApprovalNeeded")
+	if err == nil || calls != 2 {
+		t.Fatalf("expected failure after 2 calls, got err = %v after %d calls", err, calls)
+	}
+}
+
+func TestHubClientDoesNotRetryMutationOn5xx(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/manual/v1/fetchCsrf" {
+			http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "testsession"})
+			w.Write([]byte(`{"token":"testtoken"}`))
+			return
+		}
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := hub.NewClient(server.URL, "id", "key", hub.WithRetries(3), hub.WithBackoff(time.Millisecond))
+	req := graphql.NewRequest(`
+		mutation ($releaseInputProg: ReleaseInputProg) {
+			addReleaseProg(release:$releaseInputProg) { uuid }
+		}`)
+	if _, err := client.Query(context.Background(), req, "addReleaseProg"); err == nil {
+		t.Fatalf("expected mutation to fail")
+	}
+	if calls != 1 {
+		t.Fatalf("expected mutation to be sent once, got %d calls", calls)
+	}
+}
+
+func TestHubClientReturnsSessionError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/manual/v1/fetchCsrf" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		atomic.AddInt32(&calls, 1)
+		w.Write([]byte(`{"data":{"isApprovalNeeded":true}}`))
+	}))
+	defer server.Close()
+
+	client := hub.NewClient(server.URL, "id", "key")
+	_, err := client.Query(context.Background(), graphql.NewRequest(`query { isApprovalNeeded }`), "isApprovalNeeded")
+	if err == nil || !strings.Contains(err.Error(), "session") {
+		t.Fatalf("expected session error, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no GraphQL request without session, got %d calls", calls)
+	}
+}
+
+func TestHubClientTimeoutAndCancel(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := hub.NewClient(server.URL, "", "", hub.WithTimeout(20*time.Millisecond), hub.WithRetries(1), hub.WithBackoff(time.Millisecond))
+	start := time.Now()
+	if _, err := client.Query(context.Background(), graphql.NewRequest(`query { test }`), "test"); err == nil {
+		t.Fatalf("expected timeout error")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("timeout was not honored")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client = hub.NewClient(server.URL, "", "")
+	if _, err := client.Query(ctx, graphql.NewRequest(`query { test }`), "test"); err != context.Canceled {
+		t.Fatalf("expected context canceled error, got %v", err)
+	}
+}
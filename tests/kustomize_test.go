@@ -0,0 +1,117 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestReplaceTagsKustomization(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"kustomization.yaml", "deployment.yaml"} {
+		content, err := os.ReadFile(filepath.Join("kustomize", f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, f), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.DefinitionReferenceFile = "kustomize_build.yaml"
+	replaceTagsVars.Kustomization = dir
+
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	actual, _ := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+	expected, err := os.ReadFile("kustomize/expected_kustomization.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected kustomization")
+	}
+	if string(actual) != string(expected) {
+		t.Fatalf("kustomization does not equal expected, actual = %s", actual)
+	}
+
+	// running again must not change anything
+	changes, err := replacetags.PlanReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("plan failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Changed() {
+		t.Fatalf("expected unchanged kustomization, got diff:\n%s", changes[0].Diff())
+	}
+}
+
+func TestReplaceTagsKustomizationListedResources(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"overlay/kustomization.yaml": "resources:\n  - deployment.yaml\n  - ../base\n",
+		"overlay/deployment.yaml":    "containers:\n  - image: redis\n",
+		"overlay/unlisted.yaml":      "containers:\n  - image: taleodor/mafia-vue:latest\n",
+		"overlay/other/app.yaml":     "containers:\n  - image: taleodor/mafia-vue:latest\n",
+		"base/kustomization.yaml":    "resources:\n  - redis.yaml\n",
+		"base/redis.yaml":            "containers:\n  - image: docker.io/library/redis:6.0\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Kustomization = filepath.Join(dir, "overlay")
+
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	actual, _ := os.ReadFile(filepath.Join(dir, "overlay", "kustomization.yaml"))
+	expected := "resources:\n" +
+		"  - deployment.yaml\n" +
+		"  - ../base\n" +
+		"images:\n" +
+		"  - name: docker.io/library/redis\n" +
+		"    newTag: 6.2.4\n" +
+		"    digest: sha256:7e2c6181ad5c425443b56c7c73a9cd6df24a122345847d1ea9bb86a5afc76325\n" +
+		"  - name: redis\n" +
+		"    newTag: 6.2.4\n" +
+		"    digest: sha256:7e2c6181ad5c425443b56c7c73a9cd6df24a122345847d1ea9bb86a5afc76325\n"
+	if string(actual) != expected {
+		t.Fatalf("unexpected kustomization %s", actual)
+	}
+}
+
+func TestReplaceTagsKustomizationNotFound(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Kustomization = t.TempDir()
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.Is(err, replacetags.ErrNoKustomization) {
+		t.Fatalf("expected ErrNoKustomization, got %v", err)
+	}
+}
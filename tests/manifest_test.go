@@ -0,0 +1,127 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/manifest"
+)
+
+func TestReadReleaseManifest(t *testing.T) {
+	m, err := manifest.ReadFile("release_manifest.yaml")
+	if err != nil {
+		t.Fatalf("failed reading manifest: %v", err)
+	}
+	body, err := m.ReleaseBody()
+	if err != nil {
+		t.Fatalf("failed constructing release body: %v", err)
+	}
+	if body["branch"] != "main" || body["version"] != "1.2.3" || body["status"] != "COMPLETED" {
+		t.Fatalf("unexpected release fields = %v", body)
+	}
+	artifacts := body["artifacts"].([]map[string]interface{})
+	if len(artifacts) != 2 {
+		t.Fatalf("expected 2 artifacts, got %d", len(artifacts))
+	}
+	if artifacts[0]["packageType"] != "DOCKER" || artifacts[0]["buildId"] != "42" {
+		t.Fatalf("unexpected artifact = %v", artifacts[0])
+	}
+	boms := artifacts[0]["bomInputs"].([]map[string]interface{})
+	if len(boms) != 1 || boms[0]["bomType"] != "CONTAINER" || boms[0]["rawBom"] == nil {
+		t.Fatalf("bom is not resolved relative to manifest = %v", boms)
+	}
+	if _, ok := artifacts[1]["digests"]; ok {
+		t.Fatalf("unset digests must not be sent")
+	}
+	if len(body["commits"].([]manifest.Commit)) != 1 {
+		t.Fatalf("unexpected commits = %v", body["commits"])
+	}
+}
+
+func TestInvalidReleaseManifest(t *testing.T) {
+	cases := map[string]string{
+		"unknown field":       `{"branch": "main", "artifacts": [{"identifier": "a", "artbuildid": "1"}]}`,
+		"missing identifier":  "artifacts:\n  - buildId: \"1\"\n",
+		"wrong bom type":      "artifacts:\n  - identifier: a\n    boms:\n      - type: sbom\n        path: bom.json\n",
+		"missing commit":      "sourceCodeEntry:\n  uri: github.com/relizaio/reliza-cli\n",
+		"unsupported version": "apiVersion: v2\n",
+		"missing tag value":   "artifacts:\n  - identifier: a\n    tags:\n      - key: env\n",
+		"wrong status":        "status: draft\n",
+	}
+	for name, content := range cases {
+		if _, err := manifest.Parse([]byte(content)); err == nil {
+			t.Fatalf("expected %s error", name)
+		}
+	}
+}
+
+// manifest built in code is validated against the same embedded schema as parsed manifests
+func TestValidateReleaseManifestAgainstSchema(t *testing.T) {
+	m := manifest.Manifest{ApiVersion: "v1", Status: "completed",
+		Artifacts: []manifest.Artifact{{Identifier: "a", Tags: []manifest.Tag{{Key: "env", Value: "prod"}}}}}
+	if err := m.Validate(); err != nil {
+		t.Fatalf("valid manifest rejected: %v", err)
+	}
+	m.Artifacts[0].Tags[0].Key = ""
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for empty tag key")
+	}
+	m.Artifacts[0].Tags = nil
+	m.SourceCodeEntry = &manifest.SourceCodeEntry{Uri: "github.com/relizaio/reliza-cli"}
+	if err := m.Validate(); err == nil {
+		t.Fatalf("expected error for missing commit")
+	}
+}
+
+// every field of manifest types must be published in the schema
+func TestReleaseManifestSchemaInSync(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(manifest.Schema(), &schema); err != nil {
+		t.Fatalf("schema is not valid json: %v", err)
+	}
+	checkSchemaProperties(t, "manifest", reflect.TypeOf(manifest.Manifest{}), schema)
+}
+
+func checkSchemaProperties(t *testing.T, path string, typ reflect.Type, schema map[string]interface{}) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" {
+			continue
+		}
+		prop, ok := properties[name].(map[string]interface{})
+		if !ok {
+			t.Fatalf("field %s.%s is missing in schema", path, name)
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Ptr || fieldType.Kind() == reflect.Slice {
+			fieldType = fieldType.Elem()
+			if items, ok := prop["items"].(map[string]interface{}); ok {
+				prop = items
+			}
+		}
+		if fieldType.Kind() == reflect.Struct {
+			checkSchemaProperties(t, path+"."+name, fieldType, prop)
+		}
+	}
+}
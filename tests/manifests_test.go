@@ -0,0 +1,98 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestReplaceTagsJsonManifest(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = "manifests/ecs-task-definition.json"
+	replaceTagsVars.Provenance = true
+
+	actual, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected, err := os.ReadFile("manifests/expected_ecs-task-definition.json")
+	if err != nil {
+		t.Fatalf("failed reading expected task definition")
+	}
+	// provenance header is never added to json
+	if actual != string(expected) {
+		t.Fatalf("task definition does not equal expected, actual = %s", actual)
+	}
+
+	replaceTagsVars.JsonPaths = []string{"containerDefinitions[0].image"}
+	actual, err = replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	if !strings.Contains(actual, "mafia-express:21.08.3@sha256:") || !strings.Contains(actual, `"image": "redis:6.2.4"`) {
+		t.Fatalf("only first container must be replaced, actual = %s", actual)
+	}
+}
+
+func TestReplaceTagsJsonManifestInvalid(t *testing.T) {
+	infile := t.TempDir() + "/broken.json"
+	if err := os.WriteFile(infile, []byte(`{"image": "redis:6.2.4",}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = infile
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err == nil {
+		t.Fatalf("expected error on invalid json")
+	}
+}
+
+func TestReplaceTagsMultiDocument(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = "manifests/multidoc.yaml"
+
+	actual, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected, err := os.ReadFile("manifests/expected_multidoc.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected multi document manifest")
+	}
+	if actual != string(expected) {
+		t.Fatalf("multi document manifest does not equal expected, actual = %s", actual)
+	}
+
+	// busybox in images list is not in tag source
+	replaceTagsVars.ParseMode = "strict"
+	_, err = replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	var strictErr *replacetags.StrictModeError
+	if !errors.As(err, &strictErr) || !strings.Contains(strictErr.Line, "busybox:1.36") {
+		t.Fatalf("expected strict mode error on busybox, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/output"
+)
+
+const sampleRelease = `{"version":"1.2.3","uuid":"0d3c","artifactDetails":[{"identifier":"taleodor/mafia-express","digests":["sha256:7205","sha1:1234"],"buildId":42},{"identifier":"taleodor/mafia-vue","digests":["sha256:aaaa"],"buildId":43}]}`
+
+func TestOutputQuerySelectors(t *testing.T) {
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{".artifactDetails[0].digests[0]", "sha256:7205"},
+		{".artifactDetails[-1].identifier", "taleodor/mafia-vue"},
+		{`.artifactDetails[1]["buildId"]`, "43"},
+		{"version", "1.2.3"},
+		{".artifactDetails[*].identifier", `["taleodor/mafia-express","taleodor/mafia-vue"]`},
+		{".missing", "null"},
+		{"{{ .version }}-{{ (index .artifactDetails 0).buildId }}", "1.2.3-42"},
+	}
+	for _, c := range cases {
+		out, err := output.Printer{Format: output.FormatJSON, Query: c.query}.Render([]byte(sampleRelease))
+		if err != nil {
+			t.Fatalf("query %s failed: %v", c.query, err)
+		}
+		if out != c.expected {
+			t.Fatalf("query %s: expected %s, actual %s", c.query, c.expected, out)
+		}
+	}
+}
+
+func TestOutputQueryErrors(t *testing.T) {
+	for _, query := range []string{".artifactDetails[5]", ".version[0]", ".artifactDetails[0", ".artifactDetails.identifier"} {
+		if _, err := (output.Printer{Query: query}).Render([]byte(sampleRelease)); err == nil {
+			t.Fatalf("expected error for query %s", query)
+		}
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	out, err := output.Printer{Format: output.FormatYAML, Query: ".artifactDetails[0]"}.Render([]byte(sampleRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedYaml := "buildId: 42\ndigests:\n- sha256:7205\n- sha1:1234\nidentifier: taleodor/mafia-express"
+	if out != expectedYaml {
+		t.Fatalf("unexpected yaml output = %s", out)
+	}
+
+	out, err = output.Printer{Format: output.FormatTable, Query: ".artifactDetails"}.Render([]byte(sampleRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTable := "BUILDID  DIGESTS                      IDENTIFIER\n" +
+		"42       [\"sha256:7205\",\"sha1:1234\"]  taleodor/mafia-express\n" +
+		"43       [\"sha256:aaaa\"]              taleodor/mafia-vue"
+	if out != expectedTable {
+		t.Fatalf("unexpected table output = %s", out)
+	}
+
+	out, err = output.Printer{Format: output.FormatJSONPretty, Query: ".artifactDetails[1].digests"}.Render([]byte(sampleRelease))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "[\n  \"sha256:aaaa\"\n]" {
+		t.Fatalf("unexpected pretty json output = %s", out)
+	}
+
+	if err := (output.Printer{Format: "xml"}).Validate(); err == nil {
+		t.Fatalf("expected unsupported format error")
+	}
+}
@@ -0,0 +1,156 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func placeholderEnv(key string) (string, bool) {
+	env := map[string]string{
+		"MAFIA_TOKEN":   "s3cr3t",
+		"MAFIA_ENCODED": "aGVsbG8=",
+		"MAFIA_CONFIG":  "a: 1\nb: 2",
+	}
+	value, ok := env[key]
+	return value, ok
+}
+
+func TestReplaceTagsPlaceholders(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = "placeholders_values.yaml"
+	replaceTagsVars.LookupEnv = placeholderEnv
+	replaceTagsVars.Report = &replacetags.Report{}
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected, err := os.ReadFile("expected_placeholders_values.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected values file")
+	}
+	if out != string(expected) {
+		t.Fatalf("resolved placeholders do not equal expected, actual = %s", out)
+	}
+	placeholders := replaceTagsVars.Report.Files[0].Placeholders
+	if len(placeholders) != 6 || placeholders[2].Type != "ENV" || placeholders[2].Key != "MAFIA_TOKEN" || !placeholders[4].UsedDefault {
+		t.Fatalf("unexpected placeholders in report %+v", placeholders)
+	}
+}
+
+func TestReplaceTagsPlaceholderPassThroughAndDefaults(t *testing.T) {
+	content := "# values use $RELIZA{...} placeholders, i.e. $RELIZA{FOO.x}\n" +
+		"dir: $RELIZA{PROPERTY.dir:C:\\tmp\\new}\n" +
+		"first: $RELIZA{PROPERTY.host:one}\n" +
+		"second: $RELIZA{PROPERTY.host:two}\n" +
+		"third: $RELIZA{ENV.MAFIA_HOST:three} $RELIZA{ENV.MAFIA_HOST:four}\n"
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = filepath.Join(t.TempDir(), "values.yaml")
+	replaceTagsVars.LookupEnv = placeholderEnv
+	os.WriteFile(replaceTagsVars.Infile, []byte(content), 0644)
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected := "# values use $RELIZA{...} placeholders, i.e. $RELIZA{FOO.x}\n" +
+		"dir: C:\\tmp\\new\n" +
+		"first: one\n" +
+		"second: two\n" +
+		"third: three four\n"
+	if out != expected {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+func TestReplaceTagsAstMultiLinePlaceholder(t *testing.T) {
+	content := "config: |\n" +
+		"$RELIZA{ENV.MAFIA_CONFIG|indent 4}\n" +
+		"image: taleodor/mafia-express:latest\n"
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = filepath.Join(t.TempDir(), "values.yaml")
+	replaceTagsVars.ParseMode = "ast"
+	replaceTagsVars.LookupEnv = placeholderEnv
+	replaceTagsVars.Report = &replacetags.Report{}
+	os.WriteFile(replaceTagsVars.Infile, []byte(content), 0644)
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected := "config: |\n" +
+		"    a: 1\n" +
+		"    b: 2\n" +
+		"image: docker.io/taleodor/mafia-express:21.08.3@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d\n"
+	if out != expected {
+		t.Fatalf("unexpected output %q", out)
+	}
+	replacements := replaceTagsVars.Report.Files[0].Replacements
+	if len(replacements) != 1 || replacements[0].Line != 3 {
+		t.Fatalf("unexpected replacements in report %+v", replacements)
+	}
+}
+
+func TestReplaceTagsPlaceholderErrors(t *testing.T) {
+	cases := []struct {
+		content string
+		line    int
+		column  int
+	}{
+		{"a: 1\nb: $RELIZA{PROPERTY.x\n", 2, 22},
+		{"a: $RELIZA{PROPERTY.}\n", 1, 21},
+		{"a: $RELIZA{PROPERTY.x:\"unterminated}\n", 1, 37},
+		{"a: $RELIZA{SECRET.x|nope}\n", 1, 25},
+		{"a: $RELIZA{ENV.x|indent}\n", 1, 24},
+	}
+	for _, c := range cases {
+		var replaceTagsVars replacetags.ReplaceTagsVars
+		replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+		replaceTagsVars.Infile = filepath.Join(t.TempDir(), "values.yaml")
+		os.WriteFile(replaceTagsVars.Infile, []byte(c.content), 0644)
+
+		_, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+		var syntaxErr *replacetags.PlaceholderSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("%q: expected PlaceholderSyntaxError, got %v", c.content, err)
+		}
+		if syntaxErr.File != replaceTagsVars.Infile || syntaxErr.Line != c.line || syntaxErr.Column != c.column {
+			t.Fatalf("%q: unexpected location of error: %v", c.content, err)
+		}
+	}
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = filepath.Join(t.TempDir(), "values.yaml")
+	os.WriteFile(replaceTagsVars.Infile, []byte("a: $RELIZA{ENV.MAFIA_MISSING}\n"), 0644)
+	replaceTagsVars.LookupEnv = placeholderEnv
+	var envErr *replacetags.MissingEnvError
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.As(err, &envErr) || envErr.Key != "MAFIA_MISSING" {
+		t.Fatalf("expected MissingEnvError, got %v", err)
+	}
+}
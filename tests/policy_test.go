@@ -0,0 +1,89 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestReplaceTagsPolicy(t *testing.T) {
+	policy, err := replacetags.ReadPolicyFile("policy.yaml")
+	if err != nil {
+		t.Fatalf("failed reading policy: %v", err)
+	}
+	for _, parseMode := range []string{"extended", "ast"} {
+		var replaceTagsVars replacetags.ReplaceTagsVars
+		replaceTagsVars.TagSourceFile = "policy_tag_source_cdx.json"
+		replaceTagsVars.Infile = "values_policy.yaml"
+		replaceTagsVars.ParseMode = parseMode
+		replaceTagsVars.Policy = policy
+		replaceTagsVars.Report = &replacetags.Report{}
+
+		_, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+		var policyErr *replacetags.PolicyViolationError
+		if !errors.As(err, &policyErr) {
+			t.Fatalf("%s: expected policy violation, got %v", parseMode, err)
+		}
+		expected := []struct {
+			line int
+			rule string
+		}{
+			{4, replacetags.PolicyRuleRequiredProperties},
+			{6, replacetags.PolicyRuleAllowedRegistries},
+			{6, replacetags.PolicyRuleRequireDigest},
+			{6, replacetags.PolicyRuleForbiddenTags},
+		}
+		if len(policyErr.Violations) != len(expected) {
+			t.Fatalf("%s: expected %d violations, got %+v", parseMode, len(expected), policyErr.Violations)
+		}
+		for i, e := range expected {
+			if v := policyErr.Violations[i]; v.Line != e.line || v.Rule != e.rule {
+				t.Fatalf("%s: expected %s violation on line %d, got %+v", parseMode, e.rule, e.line, v)
+			}
+		}
+		if files := replaceTagsVars.Report.Files; len(files) != 1 || len(files[0].Violations) != len(expected) {
+			t.Fatalf("%s: violations are not reported: %+v", parseMode, files)
+		}
+	}
+}
+
+func TestReplaceTagsPolicySatisfied(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "policy_tag_source_cdx.json"
+	replaceTagsVars.Infile = "values_policy.yaml"
+	replaceTagsVars.ParseMode = "ast"
+	replaceTagsVars.Policy = &replacetags.Policy{RequireDigest: true, AllowedRegistries: []string{"taleodor", "quay.io"},
+		RequiredProperties: []replacetags.RequiredProperty{{Name: "reliza:approved"}}}
+
+	_, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	var policyErr *replacetags.PolicyViolationError
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 1 || policyErr.Violations[0].Image != "quay.io/foo/redis:latest" {
+		t.Fatalf("expected only unresolved redis to violate digest rule, got %v", err)
+	}
+
+	replaceTagsVars.Infile = "values_mafia.yaml"
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Policy = &replacetags.Policy{RequireDigest: true, ForbiddenTags: []string{"latest"}}
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err != nil {
+		t.Fatalf("policy must be satisfied: %v", err)
+	}
+}
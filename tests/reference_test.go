@@ -0,0 +1,191 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/reference"
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+const testDigest = "sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
+
+func TestParseNormalizedReference(t *testing.T) {
+	tests := []struct {
+		input  string
+		domain string
+		path   string
+		tag    string
+		digest string
+		err    error
+	}{
+		{input: "redis", domain: "docker.io", path: "library/redis"},
+		{input: "redis:6.2.4", domain: "docker.io", path: "library/redis", tag: "6.2.4"},
+		{input: "library/redis", domain: "docker.io", path: "library/redis"},
+		{input: "docker.io/redis", domain: "docker.io", path: "library/redis"},
+		{input: "index.docker.io/redis", domain: "docker.io", path: "library/redis"},
+		{input: "taleodor/mafia-express:tag@" + testDigest, domain: "docker.io", path: "taleodor/mafia-express", tag: "tag", digest: testDigest},
+		{input: "taleodor/mafia-express@" + testDigest, domain: "docker.io", path: "taleodor/mafia-express", digest: testDigest},
+		{input: "localhost/app", domain: "localhost", path: "app"},
+		{input: "localhost:5000/app", domain: "localhost:5000", path: "app"},
+		{input: "localhost:5000/app:1.0", domain: "localhost:5000", path: "app", tag: "1.0"},
+		{input: "localhost:5000/team/app:1.0@" + testDigest, domain: "localhost:5000", path: "team/app", tag: "1.0", digest: testDigest},
+		{input: "12345.dkr.ecr.us-east-1.amazonaws.com/mafia-express:tag", domain: "12345.dkr.ecr.us-east-1.amazonaws.com", path: "mafia-express", tag: "tag"},
+		{input: "Registry.Example.com/app", domain: "registry.example.com", path: "app"},
+		{input: "MYREGISTRY/app", domain: "myregistry", path: "app"},
+		{input: "[::1]/app", domain: "[::1]", path: "app"},
+		{input: "[2001:db8::1]:5000/org/app:v1", domain: "[2001:db8::1]:5000", path: "org/app", tag: "v1"},
+		{input: "192.168.1.10:5000/app", domain: "192.168.1.10:5000", path: "app"},
+		{input: "ghcr.io/org/sub/app_name__x.y-z:1.0_rc-1", domain: "ghcr.io", path: "org/sub/app_name__x.y-z", tag: "1.0_rc-1"},
+		{input: "quay.io/org/app@sha512:" + strings.Repeat("ab", 64), domain: "quay.io", path: "org/app", digest: "sha512:" + strings.Repeat("ab", 64)},
+		{input: "", err: reference.ErrNameEmpty},
+		{input: "docker.io/", err: reference.ErrNameEmpty},
+		{input: "docker.io/Org/app", err: reference.ErrNameContainsUppercase},
+		{input: "org/App", err: reference.ErrNameContainsUppercase},
+		{input: "app:", err: reference.ErrTagInvalidFormat},
+		{input: "app:-bad", err: reference.ErrTagInvalidFormat},
+		{input: "app:" + strings.Repeat("a", 129), err: reference.ErrTagInvalidFormat},
+		{input: "app@sha256:abc", err: reference.ErrDigestInvalidLength},
+		{input: "app@sha256:" + strings.ToUpper(testDigest[7:]), err: reference.ErrDigestInvalidFormat},
+		{input: "app@md5:d41d8cd98f00b204e9800998ecf8427e", err: reference.ErrDigestUnsupported},
+		{input: "app@sha256", err: reference.ErrDigestInvalidFormat},
+		{input: "app@", err: reference.ErrDigestInvalidFormat},
+		{input: "-bad.example.com/app", err: reference.ErrDomainInvalidFormat},
+		{input: "localhost:port/app", err: reference.ErrDomainInvalidFormat},
+		{input: "[::1/app", err: reference.ErrDomainInvalidFormat},
+		{input: "org//app", err: reference.ErrReferenceInvalidFormat},
+		{input: "org/app-", err: reference.ErrReferenceInvalidFormat},
+		{input: "{{ .Values.image }}", err: reference.ErrReferenceInvalidFormat},
+		{input: "a/" + strings.Repeat("b", 255), err: reference.ErrNameTooLong},
+	}
+	for _, tc := range tests {
+		ref, err := reference.ParseNormalized(tc.input)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("%q: expected error %v, got %v (%+v)", tc.input, tc.err, err, ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.input, err)
+			continue
+		}
+		if ref.Domain != tc.domain || ref.Path != tc.path || ref.Tag != tc.tag || ref.Digest != tc.digest {
+			t.Errorf("%q: unexpected result %+v", tc.input, ref)
+		}
+	}
+}
+
+func TestParseReferenceKeepsFamiliarForm(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		familiar string
+	}{
+		{input: "redis:6", name: "redis", familiar: "redis"},
+		{input: "taleodor/mafia-vue", name: "taleodor/mafia-vue", familiar: "taleodor/mafia-vue"},
+		{input: "localhost:5000/app:1", name: "localhost:5000/app", familiar: "localhost:5000/app"},
+	}
+	for _, tc := range tests {
+		ref, err := reference.Parse(tc.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", tc.input, err)
+		}
+		if ref.Name() != tc.name {
+			t.Errorf("%q: expected name %s, got %s", tc.input, tc.name, ref.Name())
+		}
+		normalized, _ := reference.ParseNormalized(tc.input)
+		if normalized.FamiliarName() != tc.familiar {
+			t.Errorf("%q: expected familiar name %s, got %s", tc.input, tc.familiar, normalized.FamiliarName())
+		}
+		if reparsed, _ := reference.Parse(normalized.String()); reparsed != normalized {
+			t.Errorf("%q: normalized form %s does not round trip", tc.input, normalized.String())
+		}
+	}
+}
+
+func TestGetSubstitutionFromDigestedStringRegistryPort(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected replacetags.Substitution
+	}{
+		{input: "localhost:5000/app", expected: replacetags.Substitution{Registry: "localhost:5000", Image: "app"}},
+		{input: "localhost/app:1@" + testDigest, expected: replacetags.Substitution{Registry: "localhost", Image: "app", Tag: "1", Digest: testDigest}},
+		{input: "redis", expected: replacetags.Substitution{Registry: "docker.io", Image: "library/redis"}},
+		{input: "not a reference", expected: replacetags.Substitution{Image: "not a reference"}},
+	}
+	for _, tc := range tests {
+		if subst := replacetags.GetSubstitutionFromDigestedString(tc.input); subst != tc.expected {
+			t.Errorf("%q: unexpected substitution %+v", tc.input, subst)
+		}
+	}
+}
+
+func TestScanTagsNormalizesKeys(t *testing.T) {
+	tagSourceMap := map[string]string{}
+	bom := map[string]interface{}{"components": []interface{}{
+		map[string]interface{}{"type": "container", "name": "localhost:5000/app", "version": "1.0",
+			"hashes": []interface{}{map[string]interface{}{"alg": "SHA-256", "content": testDigest[7:]}}},
+		map[string]interface{}{"type": "container", "name": "redis", "purl": "pkg:docker/redis@" + testDigest},
+	}}
+	if err := replacetags.ExtractComponentsFromCycloneJSON(bom, tagSourceMap); err != nil {
+		t.Fatal(err)
+	}
+	if tagSourceMap["localhost:5000/app"] != "localhost:5000/app:1.0@"+testDigest {
+		t.Errorf("unexpected tag source map %v", tagSourceMap)
+	}
+	if tagSourceMap["docker.io/library/redis"] != "redis@"+testDigest {
+		t.Errorf("unexpected tag source map %v", tagSourceMap)
+	}
+}
+
+func TestReplaceTagsMatchesNormalizedReferences(t *testing.T) {
+	dir := t.TempDir()
+	bom := `{"bomFormat": "CycloneDX", "specVersion": "1.4", "components": [
+		{"type": "container", "name": "localhost:5000/app", "version": "1.0", "hashes": [{"alg": "SHA-256", "content": "` + testDigest[7:] + `"}]},
+		{"type": "container", "name": "redis", "purl": "pkg:docker/redis@` + testDigest + `"}]}`
+	os.WriteFile(filepath.Join(dir, "bom.json"), []byte(bom), 0644)
+	content := "image: redis:6\n" +
+		"pinned: \"docker.io/redis@sha256:" + strings.Repeat("1", 64) + "\" # keep quotes\n" +
+		"args: [--image=localhost:5000/app:0.9, --port=5000]\n" +
+		"other: localhost:5000/application:0.9\n" +
+		"description: uses redis for caching\n"
+	os.WriteFile(filepath.Join(dir, "values.yaml"), []byte(content), 0644)
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = filepath.Join(dir, "bom.json")
+	replaceTagsVars.Infile = filepath.Join(dir, "values.yaml")
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected := "image: docker.io/library/redis@" + testDigest + "\n" +
+		"pinned: \"docker.io/library/redis@" + testDigest + "\" # keep quotes\n" +
+		"args: [--image=localhost:5000/app:1.0@" + testDigest + ", --port=5000]\n" +
+		"other: localhost:5000/application:0.9\n" +
+		"description: uses redis for caching\n"
+	if out != expected {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
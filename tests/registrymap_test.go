@@ -0,0 +1,81 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestParseRegistryMap(t *testing.T) {
+	rules, err := replacetags.ParseRegistryMap([]string{"quay.io=mirror.local/quay", `regex:^ghcr\.io/(a=b)?(.*)$=mirror.local/ghcr/$2`, "registry_map.yaml"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	expected := []replacetags.RegistryRule{
+		{Upstream: "quay.io", Mirror: "mirror.local/quay"},
+		{Regex: `^ghcr\.io/(a=b)?(.*)$`, Mirror: "mirror.local/ghcr/$2"},
+		{Regex: `^docker\.io/taleodor/(.*)$`, Mirror: "registry.corp.local/mirror/taleodor/$1"},
+		{Upstream: "docker.io", Mirror: "registry.corp.local/dockerhub"},
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("expected %d rules, got %+v", len(expected), rules)
+	}
+	for i := range expected {
+		if rules[i] != expected[i] {
+			t.Fatalf("rule %d: expected %+v, got %+v", i, expected[i], rules[i])
+		}
+	}
+
+	if _, err := replacetags.ParseRegistryMap([]string{"regex:(=mirror.local"}); err == nil {
+		t.Fatalf("invalid regex must fail")
+	}
+}
+
+func TestReplaceTagsRegistryMap(t *testing.T) {
+	rules, err := replacetags.ParseRegistryMap([]string{"registry_map.yaml"})
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = "values_mafia_mirror.yaml"
+	replaceTagsVars.RegistryMap = rules
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected := []string{
+		// input already referencing the mirror is matched too
+		"image: registry.corp.local/mirror/taleodor/mafia-express:21.08.3@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d",
+		"image: registry.corp.local/mirror/taleodor/mafia-vue:21.08.10@sha256:daa1335d480d9786f9dc1383727ea67b8c141f07ac464cc65afd11db7cff7153",
+		"image: registry.corp.local/dockerhub/library/redis:6.2.4@sha256:7e2c6181ad5c425443b56c7c73a9cd6df24a122345847d1ea9bb86a5afc76325",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line) {
+			t.Fatalf("expected %s in output:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "docker.io/") {
+		t.Fatalf("upstream registry is written:\n%s", out)
+	}
+}
@@ -18,19 +18,27 @@ WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN
 package tests
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 
-	"github.com/relizaio/reliza-cli/cmd"
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
 )
 
 func TestReplaceTags(t *testing.T) {
-	var replaceTagsVars cmd.ReplaceTagsVars
+	var replaceTagsVars replacetags.ReplaceTagsVars
 	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
 	replaceTagsVars.TypeVal = "cyclonedx"
 	replaceTagsVars.Infile = "values_mafia.yaml"
 
-	replacedTags := cmd.ReplaceTags(replaceTagsVars)
+	replacedTags, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
 	expectedReplacement, err := os.ReadFile("expected_values_mafia.yaml")
 	if err != nil {
 		t.Fatalf("failed reading expected values file")
@@ -42,40 +50,43 @@ func TestReplaceTags(t *testing.T) {
 
 func TestGetSubstitutionFromDigestedString1(t *testing.T) {
 	digestedImage := "taleodor/mafia-express:tag@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
-	subst := cmd.GetSubstitutionFromDigestedString(digestedImage)
+	subst := replacetags.GetSubstitutionFromDigestedString(digestedImage)
 	if subst.Digest != "sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d" || subst.Tag != "tag" || subst.Image != "taleodor/mafia-express" || subst.Registry != "docker.io" {
-		t.Fatalf("Substitution parse failed = %s", cmd.GetDigestedImageFromSubstitution(subst))
+		t.Fatalf("Substitution parse failed = %s", replacetags.GetDigestedImageFromSubstitution(subst))
 	}
 }
 
 func TestGetSubstitutionFromDigestedString2(t *testing.T) {
 	digestedImage := "12345.dkr.ecr.us-east-1.amazonaws.com/mafia-express:tag@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
-	subst := cmd.GetSubstitutionFromDigestedString(digestedImage)
+	subst := replacetags.GetSubstitutionFromDigestedString(digestedImage)
 	if subst.Digest != "sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d" || subst.Tag != "tag" || subst.Image != "mafia-express" || subst.Registry != "12345.dkr.ecr.us-east-1.amazonaws.com" {
-		t.Fatalf("Substitution parse failed = %s", cmd.GetDigestedImageFromSubstitution(subst))
+		t.Fatalf("Substitution parse failed = %s", replacetags.GetDigestedImageFromSubstitution(subst))
 	}
 }
 
 func TestDigestedStringFromSubstitution(t *testing.T) {
-	var subst cmd.Substitution
+	var subst replacetags.Substitution
 	subst.Registry = "12345.dkr.ecr.us-east-1.amazonaws.com"
 	subst.Image = "taleodor/mafia-express"
 	subst.Tag = "tag"
 	subst.Digest = "sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
 	expDigestedImage := "12345.dkr.ecr.us-east-1.amazonaws.com/taleodor/mafia-express:tag@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
-	actualDigestedImage := cmd.GetDigestedImageFromSubstitution(subst)
+	actualDigestedImage := replacetags.GetDigestedImageFromSubstitution(subst)
 	if expDigestedImage != actualDigestedImage {
 		t.Fatalf("Images mismatch, actual image = %s", actualDigestedImage)
 	}
 }
 
 func TestReplaceTagsBitnamiStyle(t *testing.T) {
-	var replaceTagsVars cmd.ReplaceTagsVars
+	var replaceTagsVars replacetags.ReplaceTagsVars
 	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
 	replaceTagsVars.TypeVal = "cyclonedx"
 	replaceTagsVars.Infile = "values_mafia_bitnami_style.yaml"
 
-	replacedTags := cmd.ReplaceTags(replaceTagsVars)
+	replacedTags, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
 	expectedReplacement, err := os.ReadFile("expected_values_mafia_bitnami_style.yaml")
 
 	// actualOutFile, _ := os.Create("actual_bitnami_out.yaml")
@@ -91,20 +102,20 @@ func TestReplaceTagsBitnamiStyle(t *testing.T) {
 
 func TestIsInBitnamiParse(t *testing.T) {
 	testLine1 := "    pullPolicy: IfNotPresent"
-	inParse11 := cmd.IsInBitnamiParse(testLine1, 4)
+	inParse11 := replacetags.IsInBitnamiParse(testLine1, 4)
 
 	if !inParse11 {
 		t.Fatalf("Failed in bitnami parse check, should be true with 4 whitespace prefix")
 	}
 
-	inParse12 := cmd.IsInBitnamiParse(testLine1, 5)
+	inParse12 := replacetags.IsInBitnamiParse(testLine1, 5)
 
 	if inParse12 {
 		t.Fatalf("Failed in bitnami parse check, should be false with 5 whitespace prefix")
 	}
 
 	testLine2 := "    registry: docker.io"
-	inParse21 := cmd.IsInBitnamiParse(testLine2, 4)
+	inParse21 := replacetags.IsInBitnamiParse(testLine2, 4)
 
 	if !inParse21 {
 		t.Fatalf("Failed in bitnami parse check, should be true with 4 whitespace prefix")
@@ -112,12 +123,15 @@ func TestIsInBitnamiParse(t *testing.T) {
 }
 
 func TestReplaceTagsBitnamiStyleMerged(t *testing.T) {
-	var replaceTagsVars cmd.ReplaceTagsVars
+	var replaceTagsVars replacetags.ReplaceTagsVars
 	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
 	replaceTagsVars.TypeVal = "cyclonedx"
 	replaceTagsVars.Infile = "values_mafia_bitnami_merged_style.yaml"
 
-	replacedTags := cmd.ReplaceTags(replaceTagsVars)
+	replacedTags, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
 	expectedReplacement, err := os.ReadFile("expected_values_mafia_bitnami_merged_style.yaml")
 
 	// actualOutFile, _ := os.Create("actual_bitnami_out.yaml")
@@ -130,3 +144,196 @@ func TestReplaceTagsBitnamiStyleMerged(t *testing.T) {
 		t.Fatalf("replaced tags do not equal expected, actual = %s", replacedTags)
 	}
 }
+
+func TestReplaceTagsReturnsTypedErrors(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.Is(err, replacetags.ErrInvalidInput) {
+		t.Fatalf("expected ErrInvalidInput, got %v", err)
+	}
+
+	replaceTagsVars.Infile = "values_mafia.yaml"
+	replaceTagsVars.ParseMode = "unknown"
+	var parseModeErr *replacetags.InvalidParseModeError
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.As(err, &parseModeErr) {
+		t.Fatalf("expected InvalidParseModeError, got %v", err)
+	}
+
+	replaceTagsVars.ParseMode = "strict"
+	replaceTagsVars.Infile = filepath.Join(t.TempDir(), "strict.yaml")
+	os.WriteFile(replaceTagsVars.Infile, []byte("image: unknown/image:latest\n"), 0644)
+	var strictErr *replacetags.StrictModeError
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.As(err, &strictErr) {
+		t.Fatalf("expected StrictModeError, got %v", err)
+	}
+
+	replaceTagsVars.ParseMode = ""
+	replaceTagsVars.Infile = "harbor_values.yaml"
+	var secretErr *replacetags.MissingSecretError
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.As(err, &secretErr) {
+		t.Fatalf("expected MissingSecretError, got %v", err)
+	}
+
+	replaceTagsVars.Infile = filepath.Join(t.TempDir(), "values.yaml")
+	os.WriteFile(replaceTagsVars.Infile, []byte("password: $RELIZA{PROPERTY.db_password}\n"), 0644)
+	var propErr *replacetags.MissingPropertyError
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); !errors.As(err, &propErr) || propErr.Key != "db_password" {
+		t.Fatalf("expected MissingPropertyError for db_password, got %v", err)
+	}
+}
+
+func TestReplaceTagsAstMode(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = "values_mafia_ast.yaml"
+	replaceTagsVars.ParseMode = "ast"
+
+	replacedTags, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expectedReplacement, err := os.ReadFile("expected_values_mafia_ast.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected values file")
+	}
+	if replacedTags != string(expectedReplacement) {
+		t.Fatalf("replaced tags do not equal expected, actual = %s", replacedTags)
+	}
+}
+
+func TestReplaceTagsAstModeBitnamiStyle(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = "values_mafia_bitnami_merged_style.yaml"
+	replaceTagsVars.ParseMode = "ast"
+
+	replacedTags, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expectedReplacement, err := os.ReadFile("expected_values_mafia_bitnami_merged_style.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected values file")
+	}
+	// ast mode keeps original quoting of the empty digest values
+	expected := strings.ReplaceAll(string(expectedReplacement), "digest: sha256:", "digest: \"sha256:")
+	expected = regexp.MustCompile(`(digest: "sha256:[0-9a-f]+)`).ReplaceAllString(expected, `$1"`)
+	if replacedTags != expected {
+		t.Fatalf("replaced tags do not equal expected, actual = %s", replacedTags)
+	}
+}
+
+func TestReplaceTagsAstModeAnchorsAndTags(t *testing.T) {
+	digested := "docker.io/taleodor/mafia-express:21.08.3@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
+	content := "backend:\n" +
+		"  image: &express taleodor/mafia-express:latest\n" +
+		"worker:\n" +
+		"  image: *express\n" +
+		"tagged:\n" +
+		"  image: !!str taleodor/mafia-express:latest\n" +
+		"both:\n" +
+		"  image: !!str &other \"taleodor/mafia-express:latest\"\n" +
+		"multiline:\n" +
+		"  image: taleodor/mafia-express\n" +
+		"    :latest\n"
+	infile := filepath.Join(t.TempDir(), "values.yaml")
+	os.WriteFile(infile, []byte(content), 0644)
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = infile
+	replaceTagsVars.ParseMode = "ast"
+	replaceTagsVars.Report = &replacetags.Report{}
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected := "backend:\n" +
+		"  image: &express " + digested + "\n" +
+		"worker:\n" +
+		"  image: *express\n" +
+		"tagged:\n" +
+		"  image: !!str " + digested + "\n" +
+		"both:\n" +
+		"  image: !!str &other \"" + digested + "\"\n" +
+		"multiline:\n" +
+		"  image: taleodor/mafia-express\n" +
+		"    :latest\n"
+	if out != expected {
+		t.Fatalf("unexpected output %q", out)
+	}
+	// multi-line scalar can not be rewritten, so it is reported as unresolved instead of replaced
+	fr := replaceTagsVars.Report.Files[0]
+	if len(fr.Replacements) != 3 || len(fr.Unresolved) != 1 || fr.Unresolved[0].Line != 10 {
+		t.Fatalf("unexpected report %+v", fr)
+	}
+}
+
+func TestReplaceTagsAstModeInvalidYaml(t *testing.T) {
+	infile := filepath.Join(t.TempDir(), "broken.yaml")
+	if err := os.WriteFile(infile, []byte("image: [unclosed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.TypeVal = "cyclonedx"
+	replaceTagsVars.Infile = infile
+	replaceTagsVars.ParseMode = "ast"
+	if _, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars); err == nil || !strings.Contains(err.Error(), infile) {
+		t.Fatalf("expected yaml error referencing %s, got %v", infile, err)
+	}
+}
+
+func TestReplaceTagsReport(t *testing.T) {
+	infile := filepath.Join(t.TempDir(), "values.yaml")
+	content := `other:
+  image:
+    registry: quay.io
+    repository: foo/bar
+    tag: latest
+    digest: ""
+backend:
+  image: taleodor/mafia-express:latest
+  host: $RELIZA{PROPERTY.host:mafia.example.com}
+`
+	if err := os.WriteFile(infile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, parseMode := range []string{"extended", "ast"} {
+		var replaceTagsVars replacetags.ReplaceTagsVars
+		replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+		replaceTagsVars.TypeVal = "cyclonedx"
+		replaceTagsVars.Infile = infile
+		replaceTagsVars.ParseMode = parseMode
+		replaceTagsVars.Report = &replacetags.Report{}
+
+		replacedTags, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+		if err != nil {
+			t.Fatalf("%s: replace tags failed: %v", parseMode, err)
+		}
+		if !strings.Contains(replacedTags, "repository: foo/bar\n") {
+			t.Fatalf("%s: unresolved bitnami block must be left as is, actual = %s", parseMode, replacedTags)
+		}
+		if len(replaceTagsVars.Report.Files) != 1 {
+			t.Fatalf("%s: expected report for single file, got %+v", parseMode, replaceTagsVars.Report.Files)
+		}
+		fileReport := replaceTagsVars.Report.Files[0]
+		expectedReplacement := replacetags.ReplacementEntry{Line: 8, Original: "taleodor/mafia-express:latest",
+			Replacement:     "docker.io/taleodor/mafia-express:21.08.3@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d",
+			SubstitutionKey: "docker.io/taleodor/mafia-express"}
+		if len(fileReport.Replacements) != 1 || fileReport.Replacements[0] != expectedReplacement {
+			t.Fatalf("%s: unexpected replacements %+v", parseMode, fileReport.Replacements)
+		}
+		if len(fileReport.Unresolved) != 1 || fileReport.Unresolved[0].Reference != "quay.io/foo/bar:latest" {
+			t.Fatalf("%s: unexpected unresolved images %+v", parseMode, fileReport.Unresolved)
+		}
+		expectedPlaceholder := replacetags.PlaceholderEntry{Line: 9, Type: "PROPERTY", Key: "host", UsedDefault: true}
+		if len(fileReport.Placeholders) != 1 || fileReport.Placeholders[0] != expectedPlaceholder {
+			t.Fatalf("%s: unexpected placeholders %+v", parseMode, fileReport.Placeholders)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/relizaio/reliza-cli/pkg/hub"
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+	"github.com/relizaio/reliza-cli/pkg/sealedsecret"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// newFakeCluster returns clients where creating SealedSecret produces Secret with value of its encrypted data,
+// as sealed secrets controller would do, unless unseal is false
+func newFakeCluster(unseal bool) (*fake.Clientset, *dynamicfake.FakeDynamicClient) {
+	client := fake.NewSimpleClientset()
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{sealedsecret.SealedSecretResource: "SealedSecretList"})
+	dynamicClient.PrependReactor("create", "sealedsecrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if !unseal {
+			return false, nil, nil
+		}
+		sealed := action.(k8stesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		encrypted, _, _ := unstructured.NestedString(sealed.Object, "spec", "encryptedData", sealedsecret.SecretKey)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: sealed.GetName(), Namespace: sealed.GetNamespace()},
+			Data:       map[string][]byte{sealedsecret.SecretKey: []byte("plain-" + encrypted)},
+		}
+		_, err := client.CoreV1().Secrets(sealed.GetNamespace()).Create(context.Background(), secret, metav1.CreateOptions{})
+		return false, nil, err
+	})
+	return client, dynamicClient
+}
+
+func assertCleanedUp(t *testing.T, client *fake.Clientset, dynamicClient *dynamicfake.FakeDynamicClient, namespace string) {
+	sealed, err := dynamicClient.Resource(sealedsecret.SealedSecretResource).Namespace(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sealed.Items) != 0 {
+		t.Errorf("expected temporary sealed secrets to be deleted, found %d", len(sealed.Items))
+	}
+	secrets, err := client.CoreV1().Secrets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets.Items) != 0 {
+		t.Errorf("expected unsealed secrets to be deleted, found %d", len(secrets.Items))
+	}
+}
+
+func TestSealedSecretUnseal(t *testing.T) {
+	client, dynamicClient := newFakeCluster(true)
+	resolver := sealedsecret.NewResolver(client, dynamicClient, sealedsecret.WithPollInterval(10*time.Millisecond))
+	if err := resolver.EnsureNamespace(context.Background(), "mafia"); err != nil {
+		t.Fatal(err)
+	}
+	// second call must tolerate existing namespace
+	if err := resolver.EnsureNamespace(context.Background(), "mafia"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(context.Background(), "mafia", metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected namespace to be created: %v", err)
+	}
+	plain, err := resolver.Unseal(context.Background(), "AgBy3i4OJSWK", "mafia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain != "plain-AgBy3i4OJSWK" {
+		t.Errorf("unexpected plain secret %q", plain)
+	}
+	assertCleanedUp(t, client, dynamicClient, "mafia")
+}
+
+func TestSealedSecretUnsealTimeout(t *testing.T) {
+	client, dynamicClient := newFakeCluster(false)
+	resolver := sealedsecret.NewResolver(client, dynamicClient, sealedsecret.WithTimeout(50*time.Millisecond),
+		sealedsecret.WithPollInterval(10*time.Millisecond))
+	_, err := resolver.Unseal(context.Background(), "AgBy3i4OJSWK", "mafia")
+	if !errors.Is(err, sealedsecret.ErrUnsealTimeout) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	assertCleanedUp(t, client, dynamicClient, "mafia")
+}
+
+func TestSealedSecretUnsealCancelled(t *testing.T) {
+	client, dynamicClient := newFakeCluster(false)
+	resolver := sealedsecret.NewResolver(client, dynamicClient, sealedsecret.WithPollInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := resolver.Unseal(ctx, "AgBy3i4OJSWK", "mafia"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancelled unseal to fail with context error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("unseal did not stop on cancellation")
+	}
+	assertCleanedUp(t, client, dynamicClient, "mafia")
+}
+
+// writeSealingKey writes PEM of new sealing key to temporary file, PKCS#8 as the controller does
+func writeSealingKey(t *testing.T) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "sealing.key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key, keyFile
+}
+
+func sealForTest(t *testing.T, key *rsa.PrivateKey, plain string, scope string, namespace string, name string) string {
+	label, err := sealedsecret.ScopeLabel(scope, namespace, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := sealedsecret.HybridEncrypt(rand.Reader, &key.PublicKey, []byte(plain), label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+func TestLocalUnsealScopes(t *testing.T) {
+	key, keyFile := writeSealingKey(t)
+	resolver, err := sealedsecret.NewLocalResolverFromFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := resolver.Unseal(sealForTest(t, key, "s3cret", sealedsecret.ScopeNamespaceWide, "mafia", ""), "mafia")
+	if err != nil || plain != "s3cret" {
+		t.Fatalf("expected namespace-wide value to be unsealed, got %q, %v", plain, err)
+	}
+	plain, err = resolver.Unseal(sealForTest(t, key, "s3cret", sealedsecret.ScopeClusterWide, "", ""), "mafia")
+	if err != nil || plain != "s3cret" {
+		t.Fatalf("expected cluster-wide value to be unsealed, got %q, %v", plain, err)
+	}
+
+	_, err = resolver.Unseal(sealForTest(t, key, "s3cret", sealedsecret.ScopeNamespaceWide, "other", ""), "mafia")
+	if !errors.Is(err, sealedsecret.ErrDecrypt) {
+		t.Errorf("expected value sealed for other namespace to fail, got %v", err)
+	}
+	_, err = resolver.Unseal(sealForTest(t, key, "s3cret", sealedsecret.ScopeStrict, "mafia", "app"), "mafia")
+	if !errors.Is(err, sealedsecret.ErrDecrypt) {
+		t.Errorf("expected value sealed with strict scope to fail, got %v", err)
+	}
+
+	otherKey, _ := writeSealingKey(t)
+	_, err = resolver.Unseal(sealForTest(t, otherKey, "s3cret", sealedsecret.ScopeNamespaceWide, "mafia", ""), "mafia")
+	if !errors.Is(err, sealedsecret.ErrDecrypt) {
+		t.Errorf("expected value sealed with other key to fail, got %v", err)
+	}
+}
+
+func TestReplaceTagsPlainSecretWithSealingKey(t *testing.T) {
+	key, keyFile := writeSealingKey(t)
+	sealed := sealForTest(t, key, "p@ss", sealedsecret.ScopeNamespaceWide, "mafia", "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/manual/v1/fetchCsrf" {
+			http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "testsession"})
+			w.Write([]byte(`{"token":"testtoken"}`))
+			return
+		}
+		secret, _ := json.Marshal(sealed)
+		w.Write([]byte(`{"data":{"getInstancePropSecrets":{"properties":[],"secrets":[{"key":"DB_PASSWORD","value":` +
+			string(secret) + `,"lastUpdated":1}]}}}`))
+	}))
+	defer server.Close()
+
+	infile := filepath.Join(t.TempDir(), "values.yaml")
+	if err := os.WriteFile(infile, []byte("password: $RELIZA{PLAINSECRET.DB_PASSWORD}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := sealedsecret.NewLocalResolverFromFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSourceFile = "mafia_tag_source_cdx.json"
+	replaceTagsVars.Infile = infile
+	replaceTagsVars.Namespace = "mafia"
+	replaceTagsVars.ResolveProps = true
+	replaceTagsVars.Instance = "instance"
+	replaceTagsVars.Hub = hub.NewClient(server.URL, "id", "key")
+	replaceTagsVars.PlainSecretResolver = func(ctx context.Context, sealedSecret string, namespace string) (string, error) {
+		return resolver.Unseal(sealedSecret, namespace)
+	}
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	if !strings.Contains(out, "password: p@ss") {
+		t.Errorf("expected plain secret to be resolved locally, got %s", out)
+	}
+}
+
+func TestSealWithCert(t *testing.T) {
+	key, keyFile := writeSealingKey(t)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "sealed-secret"},
+		NotBefore: time.Now(), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := sealedsecret.ReadPublicKeyFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := sealedsecret.NewLocalResolverFromFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scope := range []string{sealedsecret.ScopeNamespaceWide, sealedsecret.ScopeClusterWide} {
+		sealed, err := sealedsecret.Seal(pubKey, []byte("s3cret"), scope, "mafia", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		plain, err := resolver.Unseal(sealed, "mafia")
+		if err != nil || plain != "s3cret" {
+			t.Errorf("expected %s value to be unsealed, got %q, %v", scope, plain, err)
+		}
+	}
+
+	sealed, err := sealedsecret.Seal(pubKey, []byte("s3cret"), sealedsecret.ScopeStrict, "mafia", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _ := base64.StdEncoding.DecodeString(sealed)
+	plain, err := sealedsecret.HybridDecrypt(resolver.Keys, ciphertext, []byte("mafia/app"))
+	if err != nil || string(plain) != "s3cret" {
+		t.Errorf("expected strict value to be unsealed for its name, got %q, %v", plain, err)
+	}
+
+	if _, err := sealedsecret.Seal(pubKey, []byte("s3cret"), sealedsecret.ScopeStrict, "mafia", ""); err == nil {
+		t.Error("expected strict scope without name to fail")
+	}
+	if _, err := sealedsecret.Seal(pubKey, []byte("s3cret"), "global", "mafia", ""); err == nil {
+		t.Error("expected unknown scope to fail")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+The MIT License (MIT)
+
+Copyright (c) 2020 - 2022 Reliza Incorporated (Reliza (tm), https://reliza.io)
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"),
+to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense,
+and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY,
+WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+*/
+
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/relizaio/reliza-cli/pkg/replacetags"
+)
+
+func TestScanTagsMultipleSources(t *testing.T) {
+	cdx := replacetags.TagSource{File: "mafia_tag_source_cdx.json", FileType: "cyclonedx"}
+	override := replacetags.TagSource{File: "override_tags.txt", FileType: "text"}
+	express := "docker.io/taleodor/mafia-express"
+
+	cases := []struct {
+		policy   string
+		sources  []replacetags.TagSource
+		expected string
+	}{
+		{"", []replacetags.TagSource{override, cdx}, "taleodor/mafia-express:21.09.1@sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+		{replacetags.ConflictFirstWins, []replacetags.TagSource{cdx, override}, "docker.io/taleodor/mafia-express:21.08.3@sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"},
+		{replacetags.ConflictLastWins, []replacetags.TagSource{cdx, override}, "taleodor/mafia-express:21.09.1@sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+	}
+	for _, c := range cases {
+		tagSourceMap, err := replacetags.ScanTags(context.Background(), replacetags.ReplaceTagsVars{TagSources: c.sources, ConflictPolicy: c.policy})
+		if err != nil {
+			t.Fatalf("%s: scan failed: %v", c.policy, err)
+		}
+		if tagSourceMap[express] != c.expected {
+			t.Fatalf("%s: expected %s, got %s", c.policy, c.expected, tagSourceMap[express])
+		}
+		// images present in only one of sources are always merged
+		if !strings.HasPrefix(tagSourceMap["docker.io/library/busybox"], "busybox:1.36@") || len(tagSourceMap["docker.io/library/redis"]) == 0 {
+			t.Fatalf("%s: sources are not merged: %v", c.policy, tagSourceMap)
+		}
+	}
+
+	_, err := replacetags.ScanTags(context.Background(), replacetags.ReplaceTagsVars{TagSources: []replacetags.TagSource{cdx, override},
+		ConflictPolicy: replacetags.ConflictError})
+	var conflictErr *replacetags.TagConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.Image != express {
+		t.Fatalf("expected conflict on %s, got %v", express, err)
+	}
+}
+
+func TestReplaceTagsProvenanceListsAllSources(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	replaceTagsVars.TagSources = []replacetags.TagSource{{File: "override_tags.txt", FileType: "text"}, {File: "mafia_tag_source_cdx.json"}}
+	replaceTagsVars.Infile = "values_mafia.yaml"
+	replaceTagsVars.Provenance = true
+
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	lines := strings.Split(out, "\n")
+	expected := "# According to tag sources in order (first-wins): tag source file override_tags.txt; tag source file mafia_tag_source_cdx.json"
+	if lines[1] != expected {
+		t.Fatalf("unexpected provenance: %s", lines[1])
+	}
+	if !strings.Contains(out, "21.09.1@sha256:1111") {
+		t.Fatalf("override tag source is not applied:\n%s", out)
+	}
+}
+
+func TestOrderTagSources(t *testing.T) {
+	instance := replacetags.TagSource{Instance: "1234", Revision: "5"}
+	file := replacetags.TagSourceArg{Kind: replacetags.TagSourceArgFile, Value: "mafia_tag_source_cdx.json"}
+	bundle := replacetags.TagSourceArg{Kind: replacetags.TagSourceArgBundle, Value: "mafia"}
+	env := replacetags.TagSourceArg{Kind: replacetags.TagSourceArgEnv, Value: "STAGING"}
+
+	cases := []struct {
+		args     []replacetags.TagSourceArg
+		versions []string
+		expected []replacetags.TagSource
+	}{
+		// instance next to other sources only resolves props and secrets
+		{[]replacetags.TagSourceArg{file}, nil, []replacetags.TagSource{{File: file.Value, FileType: "cyclonedx"}}},
+		{nil, nil, []replacetags.TagSource{instance}},
+		{[]replacetags.TagSourceArg{env, file}, nil, []replacetags.TagSource{{Environment: "STAGING"}, {File: file.Value, FileType: "cyclonedx"}}},
+		// environment selects version of bundle without version and is not merged by itself
+		{[]replacetags.TagSourceArg{env, bundle}, nil, []replacetags.TagSource{{Bundle: "mafia", Environment: "STAGING"}}},
+		{[]replacetags.TagSourceArg{env, bundle}, []string{"1.0"}, []replacetags.TagSource{{Environment: "STAGING"}, {Bundle: "mafia", Version: "1.0"}}},
+	}
+	for _, c := range cases {
+		sources, err := replacetags.OrderTagSources(c.args, c.versions, "cyclonedx", instance)
+		if err != nil {
+			t.Fatalf("%v: unexpected error %v", c.args, err)
+		}
+		if len(sources) != len(c.expected) {
+			t.Fatalf("%v: expected %v, got %v", c.args, c.expected, sources)
+		}
+		for i := range sources {
+			if sources[i] != c.expected[i] {
+				t.Fatalf("%v: expected %v, got %v", c.args, c.expected, sources)
+			}
+		}
+	}
+	if _, err := replacetags.OrderTagSources([]replacetags.TagSourceArg{bundle}, nil, "", instance); err == nil {
+		t.Fatalf("expected error on bundle without version and environment")
+	}
+}
+
+func TestReplaceTagsFileSourceWithInstance(t *testing.T) {
+	var replaceTagsVars replacetags.ReplaceTagsVars
+	sources, err := replacetags.OrderTagSources([]replacetags.TagSourceArg{{Kind: replacetags.TagSourceArgFile, Value: "mafia_tag_source_cdx.json"}},
+		nil, "cyclonedx", replacetags.TagSource{Instance: "1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaceTagsVars.TagSources = sources
+	replaceTagsVars.Instance = "1234"
+	replaceTagsVars.Infile = "values_mafia.yaml"
+
+	// no hub is set, so instance bom must not be fetched
+	out, err := replacetags.ReplaceTags(context.Background(), replaceTagsVars)
+	if err != nil {
+		t.Fatalf("replace tags failed: %v", err)
+	}
+	expected, err := os.ReadFile("expected_values_mafia.yaml")
+	if err != nil {
+		t.Fatalf("failed reading expected values file")
+	}
+	if out != string(expected) {
+		t.Fatalf("output does not equal expected, actual = %s", out)
+	}
+}
+
+func TestScanTagFileSbomFormats(t *testing.T) {
+	expressDigest := "sha256:7205756e730e3c614f30509bdb33770f5816897abb49aa8308364fec1864882d"
+	vue := "ghcr.io/taleodor/mafia-vue:21.08.10@sha256:daa1335d480d9786f9dc1383727ea67b8c141f07ac464cc65afd11db7cff7153"
+	redis := "docker.io/library/redis:6.2.4@sha256:7e2c6181ad5c425443b56c7c73a9cd6df24a122345847d1ea9bb86a5afc76325"
+
+	cases := []struct {
+		file     string
+		typeVal  string
+		expected map[string]string
+	}{
+		{"tag_source_spdx.json", "spdx", map[string]string{
+			"docker.io/taleodor/mafia-express": "taleodor/mafia-express:21.08.3@" + expressDigest,
+			"ghcr.io/taleodor/mafia-vue":       vue,
+			"docker.io/library/redis":          redis,
+		}},
+		{"tag_source_spdx.spdx", "spdx", map[string]string{
+			"docker.io/taleodor/mafia-express": "taleodor/mafia-express:21.08.3@" + expressDigest,
+			"ghcr.io/taleodor/mafia-vue":       vue,
+			"docker.io/library/redis":          redis,
+		}},
+		{"tag_source_cdx.xml", "cyclonedx", map[string]string{
+			"docker.io/taleodor/mafia-express": "docker.io/taleodor/mafia-express:21.08.3@" + expressDigest,
+			"ghcr.io/taleodor/mafia-vue":       vue,
+			"docker.io/library/redis":          redis,
+		}},
+	}
+	for _, c := range cases {
+		tagSourceMap, err := replacetags.ScanTagFile(c.file, c.typeVal)
+		if err != nil {
+			t.Fatalf("%s: scan failed: %v", c.file, err)
+		}
+		if len(tagSourceMap) != len(c.expected) {
+			t.Fatalf("%s: expected %d images, got %v", c.file, len(c.expected), tagSourceMap)
+		}
+		for k, v := range c.expected {
+			if tagSourceMap[k] != v {
+				t.Fatalf("%s: expected %s for %s, got %s", c.file, v, k, tagSourceMap[k])
+			}
+		}
+	}
+}